@@ -0,0 +1,76 @@
+package api
+
+import (
+	"net/http"
+
+	"github.com/felipedavid/chatting/service"
+)
+
+type requestCodeRequest struct {
+	PhoneNumber string `json:"phone_number"`
+}
+
+type refreshRequest struct {
+	RefreshToken string `json:"refresh_token"`
+}
+
+func (s *Server) requestVerificationCode(w http.ResponseWriter, r *http.Request) {
+	var req requestCodeRequest
+	if err := readJSON(w, r, &req); err != nil {
+		s.badRequest(w, err)
+		return
+	}
+
+	if err := s.services.VerificationService.RequestCode(r.Context(), req.PhoneNumber); err != nil {
+		s.serviceError(w, r, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusAccepted)
+}
+
+func (s *Server) login(w http.ResponseWriter, r *http.Request) {
+	var req service.LoginRequest
+	if err := readJSON(w, r, &req); err != nil {
+		s.badRequest(w, err)
+		return
+	}
+
+	session, err := s.services.SessionService.Login(r.Context(), req)
+	if err != nil {
+		s.serviceError(w, r, err)
+		return
+	}
+
+	status := http.StatusOK
+	if session.Created {
+		status = http.StatusCreated
+	}
+
+	s.writeJSON(w, status, session)
+}
+
+func (s *Server) refreshSession(w http.ResponseWriter, r *http.Request) {
+	var req refreshRequest
+	if err := readJSON(w, r, &req); err != nil {
+		s.badRequest(w, err)
+		return
+	}
+
+	tokens, err := s.services.SessionService.Refresh(r.Context(), req.RefreshToken)
+	if err != nil {
+		s.serviceError(w, r, err)
+		return
+	}
+
+	s.writeJSON(w, http.StatusOK, tokens)
+}
+
+func (s *Server) logout(w http.ResponseWriter, r *http.Request) {
+	if err := s.services.SessionService.Logout(r.Context()); err != nil {
+		s.serviceError(w, r, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
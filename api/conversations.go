@@ -0,0 +1,154 @@
+package api
+
+import (
+	"net/http"
+
+	"github.com/felipedavid/chatting/service"
+	"github.com/jackc/pgx/v5/pgtype"
+)
+
+type addParticipantRequest struct {
+	UserID pgtype.UUID `json:"user_id"`
+	Role   string      `json:"role"`
+}
+
+func (s *Server) listUserConversations(w http.ResponseWriter, r *http.Request) {
+	conversations, err := s.services.ConversationService.ListUserConversations(r.Context())
+	if err != nil {
+		s.serviceError(w, r, err)
+		return
+	}
+
+	s.writeJSON(w, http.StatusOK, conversations)
+}
+
+func (s *Server) listUnreadCounts(w http.ResponseWriter, r *http.Request) {
+	counts, err := s.services.ConversationService.GetUnreadCounts(r.Context())
+	if err != nil {
+		s.serviceError(w, r, err)
+		return
+	}
+
+	s.writeJSON(w, http.StatusOK, counts)
+}
+
+func (s *Server) createConversation(w http.ResponseWriter, r *http.Request) {
+	var req service.CreateConversationRequest
+	if err := readJSON(w, r, &req); err != nil {
+		s.badRequest(w, err)
+		return
+	}
+
+	conversation, err := s.services.ConversationService.CreateConversation(r.Context(), req)
+	if err != nil {
+		s.serviceError(w, r, err)
+		return
+	}
+
+	s.writeJSON(w, http.StatusCreated, conversation)
+}
+
+func (s *Server) getConversation(w http.ResponseWriter, r *http.Request) {
+	conversationID, err := pathUUID(r, "conversationID")
+	if err != nil {
+		s.badRequest(w, err)
+		return
+	}
+
+	conversation, err := s.services.ConversationService.GetConversation(r.Context(), conversationID)
+	if err != nil {
+		s.serviceError(w, r, err)
+		return
+	}
+
+	s.writeJSON(w, http.StatusOK, conversation)
+}
+
+func (s *Server) getConversationWithCreator(w http.ResponseWriter, r *http.Request) {
+	conversationID, err := pathUUID(r, "conversationID")
+	if err != nil {
+		s.badRequest(w, err)
+		return
+	}
+
+	conversation, err := s.services.ConversationService.GetConversationWithCreator(r.Context(), conversationID)
+	if err != nil {
+		s.serviceError(w, r, err)
+		return
+	}
+
+	s.writeJSON(w, http.StatusOK, conversation)
+}
+
+func (s *Server) deleteConversation(w http.ResponseWriter, r *http.Request) {
+	conversationID, err := pathUUID(r, "conversationID")
+	if err != nil {
+		s.badRequest(w, err)
+		return
+	}
+
+	if err := s.services.ConversationService.DeleteConversation(r.Context(), conversationID); err != nil {
+		s.serviceError(w, r, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (s *Server) listParticipants(w http.ResponseWriter, r *http.Request) {
+	conversationID, err := pathUUID(r, "conversationID")
+	if err != nil {
+		s.badRequest(w, err)
+		return
+	}
+
+	participants, err := s.services.ConversationService.GetConversationParticipants(r.Context(), conversationID)
+	if err != nil {
+		s.serviceError(w, r, err)
+		return
+	}
+
+	s.writeJSON(w, http.StatusOK, participants)
+}
+
+func (s *Server) addParticipant(w http.ResponseWriter, r *http.Request) {
+	conversationID, err := pathUUID(r, "conversationID")
+	if err != nil {
+		s.badRequest(w, err)
+		return
+	}
+
+	var req addParticipantRequest
+	if err := readJSON(w, r, &req); err != nil {
+		s.badRequest(w, err)
+		return
+	}
+
+	if err := s.services.ConversationService.AddParticipant(r.Context(), conversationID, req.UserID, req.Role); err != nil {
+		s.serviceError(w, r, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (s *Server) removeParticipant(w http.ResponseWriter, r *http.Request) {
+	conversationID, err := pathUUID(r, "conversationID")
+	if err != nil {
+		s.badRequest(w, err)
+		return
+	}
+
+	userID, err := pathUUID(r, "userID")
+	if err != nil {
+		s.badRequest(w, err)
+		return
+	}
+
+	if err := s.services.ConversationService.RemoveParticipant(r.Context(), conversationID, userID); err != nil {
+		s.serviceError(w, r, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -0,0 +1,119 @@
+package api
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/felipedavid/chatting/service"
+)
+
+type muteConversationRequest struct {
+	// Until is when the mute ends. Without it the conversation stays muted
+	// until it is unmuted.
+	Until *time.Time `json:"until"`
+}
+
+func (s *Server) listInbox(w http.ResponseWriter, r *http.Request) {
+	limit, err := queryInt32(r, "limit", 50)
+	if err != nil {
+		s.badRequest(w, err)
+		return
+	}
+
+	inbox, err := s.services.ConversationService.ListInbox(r.Context(), service.ListInboxRequest{
+		Before: r.URL.Query().Get("before"),
+		Limit:  limit,
+	})
+	if err != nil {
+		s.serviceError(w, r, err)
+		return
+	}
+
+	s.writeJSON(w, http.StatusOK, inbox)
+}
+
+func (s *Server) muteConversation(w http.ResponseWriter, r *http.Request) {
+	conversationID, err := pathUUID(r, "conversationID")
+	if err != nil {
+		s.badRequest(w, err)
+		return
+	}
+
+	var req muteConversationRequest
+	if err := readJSON(w, r, &req); err != nil {
+		s.badRequest(w, err)
+		return
+	}
+
+	settings, err := s.services.ConversationService.MuteConversation(r.Context(), conversationID, req.Until)
+	if err != nil {
+		s.serviceError(w, r, err)
+		return
+	}
+
+	s.writeJSON(w, http.StatusOK, settings)
+}
+
+func (s *Server) unmuteConversation(w http.ResponseWriter, r *http.Request) {
+	conversationID, err := pathUUID(r, "conversationID")
+	if err != nil {
+		s.badRequest(w, err)
+		return
+	}
+
+	settings, err := s.services.ConversationService.UnmuteConversation(r.Context(), conversationID)
+	if err != nil {
+		s.serviceError(w, r, err)
+		return
+	}
+
+	s.writeJSON(w, http.StatusOK, settings)
+}
+
+func (s *Server) pinConversation(w http.ResponseWriter, r *http.Request) {
+	s.setConversationPinned(w, r, true)
+}
+
+func (s *Server) unpinConversation(w http.ResponseWriter, r *http.Request) {
+	s.setConversationPinned(w, r, false)
+}
+
+func (s *Server) setConversationPinned(w http.ResponseWriter, r *http.Request, pinned bool) {
+	conversationID, err := pathUUID(r, "conversationID")
+	if err != nil {
+		s.badRequest(w, err)
+		return
+	}
+
+	settings, err := s.services.ConversationService.PinConversation(r.Context(), conversationID, pinned)
+	if err != nil {
+		s.serviceError(w, r, err)
+		return
+	}
+
+	s.writeJSON(w, http.StatusOK, settings)
+}
+
+func (s *Server) archiveConversation(w http.ResponseWriter, r *http.Request) {
+	s.setConversationArchived(w, r, true)
+}
+
+func (s *Server) unarchiveConversation(w http.ResponseWriter, r *http.Request) {
+	s.setConversationArchived(w, r, false)
+}
+
+func (s *Server) setConversationArchived(w http.ResponseWriter, r *http.Request, archived bool) {
+	conversationID, err := pathUUID(r, "conversationID")
+	if err != nil {
+		s.badRequest(w, err)
+		return
+	}
+
+	settings, err := s.services.ConversationService.ArchiveConversation(r.Context(), conversationID, archived)
+	if err != nil {
+		s.serviceError(w, r, err)
+		return
+	}
+
+	s.writeJSON(w, http.StatusOK, settings)
+}
@@ -0,0 +1,145 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+
+	"github.com/felipedavid/chatting/service"
+	"github.com/jackc/pgx/v5/pgtype"
+)
+
+const maxBodySize = 1 << 20
+
+type errorResponse struct {
+	Error string `json:"error"`
+}
+
+func (s *Server) writeJSON(w http.ResponseWriter, status int, data any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+
+	if data == nil {
+		return
+	}
+
+	if err := json.NewEncoder(w).Encode(data); err != nil {
+		s.logger.Error("failed to encode response", "error", err)
+	}
+}
+
+func (s *Server) writeError(w http.ResponseWriter, status int, message string) {
+	s.writeJSON(w, status, errorResponse{Error: message})
+}
+
+func (s *Server) badRequest(w http.ResponseWriter, err error) {
+	s.writeError(w, http.StatusBadRequest, err.Error())
+}
+
+// serviceError reports an error returned by the service layer. Typed service
+// errors map to a status code and expose only their message; anything else is
+// an internal failure and is not shown to the client.
+func (s *Server) serviceError(w http.ResponseWriter, r *http.Request, err error) {
+	var serr *service.Error
+	if !errors.As(err, &serr) {
+		s.logger.Error("internal error", "method", r.Method, "path", r.URL.Path, "error", err)
+		s.writeError(w, http.StatusInternalServerError, "internal server error")
+		return
+	}
+
+	s.logger.Warn("service error", "method", r.Method, "path", r.URL.Path, "error", err)
+	s.writeError(w, errorStatus(serr), serr.Message)
+}
+
+func errorStatus(err error) int {
+	switch {
+	case errors.Is(err, service.ErrNotFound):
+		return http.StatusNotFound
+	case errors.Is(err, service.ErrInvalidArgument):
+		return http.StatusBadRequest
+	case errors.Is(err, service.ErrAlreadyExists), errors.Is(err, service.ErrConflict):
+		return http.StatusConflict
+	case errors.Is(err, service.ErrPermissionDenied):
+		return http.StatusForbidden
+	case errors.Is(err, service.ErrUnauthenticated):
+		return http.StatusUnauthorized
+	case errors.Is(err, service.ErrRateLimited):
+		return http.StatusTooManyRequests
+	default:
+		return http.StatusInternalServerError
+	}
+}
+
+func readJSON(w http.ResponseWriter, r *http.Request, dst any) error {
+	r.Body = http.MaxBytesReader(w, r.Body, maxBodySize)
+
+	dec := json.NewDecoder(r.Body)
+	dec.DisallowUnknownFields()
+
+	if err := dec.Decode(dst); err != nil {
+		if errors.Is(err, io.EOF) {
+			return fmt.Errorf("request body is empty")
+		}
+		return fmt.Errorf("invalid request body: %w", err)
+	}
+
+	if dec.More() {
+		return fmt.Errorf("request body must contain a single JSON value")
+	}
+
+	return nil
+}
+
+func pathUUID(r *http.Request, name string) (pgtype.UUID, error) {
+	var id pgtype.UUID
+	if err := id.Scan(r.PathValue(name)); err != nil {
+		return id, fmt.Errorf("invalid %s", name)
+	}
+	return id, nil
+}
+
+// queryUUID returns the named query parameter, or an invalid UUID when it is
+// absent.
+func queryUUID(r *http.Request, name string) (pgtype.UUID, error) {
+	var id pgtype.UUID
+	value := r.URL.Query().Get(name)
+	if value == "" {
+		return id, nil
+	}
+
+	if err := id.Scan(value); err != nil {
+		return id, fmt.Errorf("invalid %s", name)
+	}
+	return id, nil
+}
+
+func queryInt64(r *http.Request, name string, fallback int64) (int64, error) {
+	value := r.URL.Query().Get(name)
+	if value == "" {
+		return fallback, nil
+	}
+
+	n, err := strconv.ParseInt(value, 10, 64)
+	if err != nil || n < 0 {
+		return 0, fmt.Errorf("invalid %s", name)
+	}
+
+	return n, nil
+}
+
+func queryInt32(r *http.Request, name string, fallback int32) (int32, error) {
+	value := r.URL.Query().Get(name)
+	if value == "" {
+		return fallback, nil
+	}
+
+	n, err := strconv.ParseInt(value, 10, 32)
+	if err != nil || n < 0 {
+		return 0, fmt.Errorf("invalid %s", name)
+	}
+
+	return int32(n), nil
+}
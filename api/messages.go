@@ -0,0 +1,430 @@
+package api
+
+import (
+	"net/http"
+
+	"github.com/felipedavid/chatting/service"
+	"github.com/jackc/pgx/v5/pgtype"
+)
+
+type addReactionRequest struct {
+	Reaction string `json:"reaction"`
+}
+
+type editMessageRequest struct {
+	Content string `json:"content"`
+}
+
+type forwardMessageRequest struct {
+	ConversationIDs []pgtype.UUID `json:"conversation_ids"`
+}
+
+type votePollRequest struct {
+	Options []int32 `json:"options"`
+}
+
+type countResponse struct {
+	Count int64 `json:"count"`
+}
+
+func (s *Server) createMessage(w http.ResponseWriter, r *http.Request) {
+	conversationID, err := pathUUID(r, "conversationID")
+	if err != nil {
+		s.badRequest(w, err)
+		return
+	}
+
+	var req service.CreateMessageRequest
+	if err := readJSON(w, r, &req); err != nil {
+		s.badRequest(w, err)
+		return
+	}
+	req.ConversationID = conversationID
+
+	message, err := s.services.MessageService.CreateMessage(r.Context(), req)
+	if err != nil {
+		s.serviceError(w, r, err)
+		return
+	}
+
+	s.writeJSON(w, http.StatusCreated, message)
+}
+
+func (s *Server) listMessages(w http.ResponseWriter, r *http.Request) {
+	conversationID, err := pathUUID(r, "conversationID")
+	if err != nil {
+		s.badRequest(w, err)
+		return
+	}
+
+	limit, err := queryInt32(r, "limit", 50)
+	if err != nil {
+		s.badRequest(w, err)
+		return
+	}
+
+	messages, err := s.services.MessageService.GetConversationMessages(r.Context(), conversationID, service.ListMessagesRequest{
+		Before: r.URL.Query().Get("before"),
+		After:  r.URL.Query().Get("after"),
+		Limit:  limit,
+	})
+	if err != nil {
+		s.serviceError(w, r, err)
+		return
+	}
+
+	s.writeJSON(w, http.StatusOK, messages)
+}
+
+func (s *Server) listMessagesSince(w http.ResponseWriter, r *http.Request) {
+	conversationID, err := pathUUID(r, "conversationID")
+	if err != nil {
+		s.badRequest(w, err)
+		return
+	}
+
+	seq, err := queryInt64(r, "seq", 0)
+	if err != nil {
+		s.badRequest(w, err)
+		return
+	}
+
+	limit, err := queryInt32(r, "limit", 50)
+	if err != nil {
+		s.badRequest(w, err)
+		return
+	}
+
+	messages, err := s.services.MessageService.GetMessagesAfterSeq(r.Context(), conversationID, seq, limit)
+	if err != nil {
+		s.serviceError(w, r, err)
+		return
+	}
+
+	s.writeJSON(w, http.StatusOK, messages)
+}
+
+func (s *Server) getLatestMessage(w http.ResponseWriter, r *http.Request) {
+	conversationID, err := pathUUID(r, "conversationID")
+	if err != nil {
+		s.badRequest(w, err)
+		return
+	}
+
+	message, err := s.services.MessageService.GetLatestConversationMessage(r.Context(), conversationID)
+	if err != nil {
+		s.serviceError(w, r, err)
+		return
+	}
+
+	s.writeJSON(w, http.StatusOK, message)
+}
+
+func (s *Server) countMessages(w http.ResponseWriter, r *http.Request) {
+	conversationID, err := pathUUID(r, "conversationID")
+	if err != nil {
+		s.badRequest(w, err)
+		return
+	}
+
+	count, err := s.services.MessageService.CountConversationMessages(r.Context(), conversationID)
+	if err != nil {
+		s.serviceError(w, r, err)
+		return
+	}
+
+	s.writeJSON(w, http.StatusOK, countResponse{Count: count})
+}
+
+func (s *Server) getMessage(w http.ResponseWriter, r *http.Request) {
+	messageID, err := pathUUID(r, "messageID")
+	if err != nil {
+		s.badRequest(w, err)
+		return
+	}
+
+	message, err := s.services.MessageService.GetMessage(r.Context(), messageID)
+	if err != nil {
+		s.serviceError(w, r, err)
+		return
+	}
+
+	s.writeJSON(w, http.StatusOK, message)
+}
+
+func (s *Server) editMessage(w http.ResponseWriter, r *http.Request) {
+	messageID, err := pathUUID(r, "messageID")
+	if err != nil {
+		s.badRequest(w, err)
+		return
+	}
+
+	var req editMessageRequest
+	if err := readJSON(w, r, &req); err != nil {
+		s.badRequest(w, err)
+		return
+	}
+
+	message, err := s.services.MessageService.EditMessage(r.Context(), messageID, req.Content)
+	if err != nil {
+		s.serviceError(w, r, err)
+		return
+	}
+
+	s.writeJSON(w, http.StatusOK, message)
+}
+
+func (s *Server) listMessageEdits(w http.ResponseWriter, r *http.Request) {
+	messageID, err := pathUUID(r, "messageID")
+	if err != nil {
+		s.badRequest(w, err)
+		return
+	}
+
+	edits, err := s.services.MessageService.GetMessageEdits(r.Context(), messageID)
+	if err != nil {
+		s.serviceError(w, r, err)
+		return
+	}
+
+	s.writeJSON(w, http.StatusOK, edits)
+}
+
+func (s *Server) listMentions(w http.ResponseWriter, r *http.Request) {
+	limit, err := queryInt32(r, "limit", 50)
+	if err != nil {
+		s.badRequest(w, err)
+		return
+	}
+
+	mentions, err := s.services.MessageService.GetMentions(r.Context(), service.ListMentionsRequest{
+		Before: r.URL.Query().Get("before"),
+		Limit:  limit,
+	})
+	if err != nil {
+		s.serviceError(w, r, err)
+		return
+	}
+
+	s.writeJSON(w, http.StatusOK, mentions)
+}
+
+func (s *Server) getPollResults(w http.ResponseWriter, r *http.Request) {
+	messageID, err := pathUUID(r, "messageID")
+	if err != nil {
+		s.badRequest(w, err)
+		return
+	}
+
+	results, err := s.services.MessageService.GetPollResults(r.Context(), messageID)
+	if err != nil {
+		s.serviceError(w, r, err)
+		return
+	}
+
+	s.writeJSON(w, http.StatusOK, results)
+}
+
+func (s *Server) votePoll(w http.ResponseWriter, r *http.Request) {
+	messageID, err := pathUUID(r, "messageID")
+	if err != nil {
+		s.badRequest(w, err)
+		return
+	}
+
+	var req votePollRequest
+	if err := readJSON(w, r, &req); err != nil {
+		s.badRequest(w, err)
+		return
+	}
+
+	results, err := s.services.MessageService.VotePoll(r.Context(), messageID, req.Options)
+	if err != nil {
+		s.serviceError(w, r, err)
+		return
+	}
+
+	s.writeJSON(w, http.StatusOK, results)
+}
+
+func (s *Server) retractPollVote(w http.ResponseWriter, r *http.Request) {
+	messageID, err := pathUUID(r, "messageID")
+	if err != nil {
+		s.badRequest(w, err)
+		return
+	}
+
+	results, err := s.services.MessageService.RetractPollVote(r.Context(), messageID)
+	if err != nil {
+		s.serviceError(w, r, err)
+		return
+	}
+
+	s.writeJSON(w, http.StatusOK, results)
+}
+
+func (s *Server) closePoll(w http.ResponseWriter, r *http.Request) {
+	messageID, err := pathUUID(r, "messageID")
+	if err != nil {
+		s.badRequest(w, err)
+		return
+	}
+
+	results, err := s.services.MessageService.ClosePoll(r.Context(), messageID)
+	if err != nil {
+		s.serviceError(w, r, err)
+		return
+	}
+
+	s.writeJSON(w, http.StatusOK, results)
+}
+
+func (s *Server) getThread(w http.ResponseWriter, r *http.Request) {
+	messageID, err := pathUUID(r, "messageID")
+	if err != nil {
+		s.badRequest(w, err)
+		return
+	}
+
+	limit, err := queryInt32(r, "limit", 50)
+	if err != nil {
+		s.badRequest(w, err)
+		return
+	}
+
+	thread, err := s.services.MessageService.GetThread(r.Context(), messageID, service.ListThreadRequest{
+		After: r.URL.Query().Get("after"),
+		Limit: limit,
+	})
+	if err != nil {
+		s.serviceError(w, r, err)
+		return
+	}
+
+	s.writeJSON(w, http.StatusOK, thread)
+}
+
+func (s *Server) followThread(w http.ResponseWriter, r *http.Request) {
+	messageID, err := pathUUID(r, "messageID")
+	if err != nil {
+		s.badRequest(w, err)
+		return
+	}
+
+	if err := s.services.MessageService.FollowThread(r.Context(), messageID); err != nil {
+		s.serviceError(w, r, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (s *Server) unfollowThread(w http.ResponseWriter, r *http.Request) {
+	messageID, err := pathUUID(r, "messageID")
+	if err != nil {
+		s.badRequest(w, err)
+		return
+	}
+
+	if err := s.services.MessageService.UnfollowThread(r.Context(), messageID); err != nil {
+		s.serviceError(w, r, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (s *Server) forwardMessage(w http.ResponseWriter, r *http.Request) {
+	messageID, err := pathUUID(r, "messageID")
+	if err != nil {
+		s.badRequest(w, err)
+		return
+	}
+
+	var req forwardMessageRequest
+	if err := readJSON(w, r, &req); err != nil {
+		s.badRequest(w, err)
+		return
+	}
+
+	messages, err := s.services.MessageService.ForwardMessage(r.Context(), messageID, req.ConversationIDs)
+	if err != nil {
+		s.serviceError(w, r, err)
+		return
+	}
+
+	s.writeJSON(w, http.StatusCreated, messages)
+}
+
+func (s *Server) deleteMessage(w http.ResponseWriter, r *http.Request) {
+	messageID, err := pathUUID(r, "messageID")
+	if err != nil {
+		s.badRequest(w, err)
+		return
+	}
+
+	// Deleting for everyone stays the default so existing clients keep working
+	mode := service.DeleteMode(r.URL.Query().Get("for"))
+	if mode == "" {
+		mode = service.DeleteForEveryone
+	}
+
+	if err := s.services.MessageService.DeleteMessage(r.Context(), messageID, mode); err != nil {
+		s.serviceError(w, r, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (s *Server) listReactions(w http.ResponseWriter, r *http.Request) {
+	messageID, err := pathUUID(r, "messageID")
+	if err != nil {
+		s.badRequest(w, err)
+		return
+	}
+
+	reactions, err := s.services.MessageService.GetMessageReactions(r.Context(), messageID)
+	if err != nil {
+		s.serviceError(w, r, err)
+		return
+	}
+
+	s.writeJSON(w, http.StatusOK, reactions)
+}
+
+func (s *Server) addReaction(w http.ResponseWriter, r *http.Request) {
+	messageID, err := pathUUID(r, "messageID")
+	if err != nil {
+		s.badRequest(w, err)
+		return
+	}
+
+	var req addReactionRequest
+	if err := readJSON(w, r, &req); err != nil {
+		s.badRequest(w, err)
+		return
+	}
+
+	if err := s.services.MessageService.AddReaction(r.Context(), messageID, req.Reaction); err != nil {
+		s.serviceError(w, r, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (s *Server) removeReaction(w http.ResponseWriter, r *http.Request) {
+	messageID, err := pathUUID(r, "messageID")
+	if err != nil {
+		s.badRequest(w, err)
+		return
+	}
+
+	if err := s.services.MessageService.RemoveReaction(r.Context(), messageID); err != nil {
+		s.serviceError(w, r, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -0,0 +1,60 @@
+package api
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+
+	"github.com/felipedavid/chatting/auth"
+	"github.com/felipedavid/chatting/service"
+)
+
+func (s *Server) logRequests(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		s.logger.Info("request", "method", r.Method, "path", r.URL.Path, "remote", r.RemoteAddr)
+		next.ServeHTTP(w, r)
+	})
+}
+
+// authenticate resolves the bearer token into the actor every service call
+// acts on behalf of, rejecting the request when it is missing or revoked.
+func (s *Server) authenticate(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		token := bearerToken(r)
+		if token == "" {
+			s.writeError(w, http.StatusUnauthorized, "authentication required")
+			return
+		}
+
+		actor, err := s.services.SessionService.Authenticate(r.Context(), token)
+		if errors.Is(err, auth.ErrInvalidToken) {
+			s.writeError(w, http.StatusUnauthorized, err.Error())
+			return
+		}
+		if err != nil {
+			s.serviceError(w, r, err)
+			return
+		}
+
+		next.ServeHTTP(w, r.WithContext(service.WithActor(r.Context(), actor)))
+	})
+}
+
+// bearerToken reads the access token from the Authorization header. Browsers
+// cannot set headers on WebSocket handshakes, so those may pass it in the
+// access_token query parameter instead.
+func bearerToken(r *http.Request) string {
+	if header := r.Header.Get("Authorization"); header != "" {
+		token, ok := strings.CutPrefix(header, "Bearer ")
+		if !ok {
+			return ""
+		}
+		return strings.TrimSpace(token)
+	}
+
+	if strings.EqualFold(r.Header.Get("Upgrade"), "websocket") {
+		return r.URL.Query().Get("access_token")
+	}
+
+	return ""
+}
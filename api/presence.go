@@ -0,0 +1,51 @@
+package api
+
+import (
+	"net/http"
+)
+
+type updatePresenceSettingsRequest struct {
+	Visibility string `json:"visibility"`
+}
+
+func (s *Server) getPresence(w http.ResponseWriter, r *http.Request) {
+	userID, err := pathUUID(r, "userID")
+	if err != nil {
+		s.badRequest(w, err)
+		return
+	}
+
+	presence, err := s.services.PresenceService.GetPresence(r.Context(), userID)
+	if err != nil {
+		s.serviceError(w, r, err)
+		return
+	}
+
+	s.writeJSON(w, http.StatusOK, presence)
+}
+
+func (s *Server) getPresenceSettings(w http.ResponseWriter, r *http.Request) {
+	settings, err := s.services.PresenceService.GetSettings(r.Context())
+	if err != nil {
+		s.serviceError(w, r, err)
+		return
+	}
+
+	s.writeJSON(w, http.StatusOK, settings)
+}
+
+func (s *Server) updatePresenceSettings(w http.ResponseWriter, r *http.Request) {
+	var req updatePresenceSettingsRequest
+	if err := readJSON(w, r, &req); err != nil {
+		s.badRequest(w, err)
+		return
+	}
+
+	settings, err := s.services.PresenceService.UpdateSettings(r.Context(), req.Visibility)
+	if err != nil {
+		s.serviceError(w, r, err)
+		return
+	}
+
+	s.writeJSON(w, http.StatusOK, settings)
+}
@@ -0,0 +1,103 @@
+package api
+
+import (
+	"net/http"
+
+	"github.com/jackc/pgx/v5/pgtype"
+)
+
+type receiptsUpToRequest struct {
+	MessageID pgtype.UUID `json:"message_id"`
+}
+
+func (s *Server) markMessageAsDelivered(w http.ResponseWriter, r *http.Request) {
+	messageID, err := pathUUID(r, "messageID")
+	if err != nil {
+		s.badRequest(w, err)
+		return
+	}
+
+	receipt, err := s.services.ReceiptService.MarkDelivered(r.Context(), messageID)
+	if err != nil {
+		s.serviceError(w, r, err)
+		return
+	}
+
+	s.writeJSON(w, http.StatusOK, receipt)
+}
+
+func (s *Server) markMessageAsRead(w http.ResponseWriter, r *http.Request) {
+	messageID, err := pathUUID(r, "messageID")
+	if err != nil {
+		s.badRequest(w, err)
+		return
+	}
+
+	receipt, err := s.services.ReceiptService.MarkRead(r.Context(), messageID)
+	if err != nil {
+		s.serviceError(w, r, err)
+		return
+	}
+
+	s.writeJSON(w, http.StatusOK, receipt)
+}
+
+func (s *Server) getMessageStatus(w http.ResponseWriter, r *http.Request) {
+	messageID, err := pathUUID(r, "messageID")
+	if err != nil {
+		s.badRequest(w, err)
+		return
+	}
+
+	status, err := s.services.ReceiptService.GetMessageStatus(r.Context(), messageID)
+	if err != nil {
+		s.serviceError(w, r, err)
+		return
+	}
+
+	s.writeJSON(w, http.StatusOK, status)
+}
+
+func (s *Server) markConversationDelivered(w http.ResponseWriter, r *http.Request) {
+	conversationID, err := pathUUID(r, "conversationID")
+	if err != nil {
+		s.badRequest(w, err)
+		return
+	}
+
+	var req receiptsUpToRequest
+	if err := readJSON(w, r, &req); err != nil {
+		s.badRequest(w, err)
+		return
+	}
+
+	receipts, err := s.services.ReceiptService.MarkDeliveredUpTo(r.Context(), conversationID, req.MessageID)
+	if err != nil {
+		s.serviceError(w, r, err)
+		return
+	}
+
+	s.writeJSON(w, http.StatusOK, receipts)
+}
+
+func (s *Server) markConversationRead(w http.ResponseWriter, r *http.Request) {
+	conversationID, err := pathUUID(r, "conversationID")
+	if err != nil {
+		s.badRequest(w, err)
+		return
+	}
+
+	var req receiptsUpToRequest
+	if err := readJSON(w, r, &req); err != nil {
+		s.badRequest(w, err)
+		return
+	}
+
+	receipts, err := s.services.ReceiptService.MarkReadUpTo(r.Context(), conversationID, req.MessageID)
+	if err != nil {
+		s.serviceError(w, r, err)
+		return
+	}
+
+	s.writeJSON(w, http.StatusOK, receipts)
+}
@@ -0,0 +1,76 @@
+package api
+
+import (
+	"net/http"
+
+	"github.com/felipedavid/chatting/service"
+)
+
+func (s *Server) scheduleMessage(w http.ResponseWriter, r *http.Request) {
+	var req service.ScheduleMessageRequest
+	if err := readJSON(w, r, &req); err != nil {
+		s.badRequest(w, err)
+		return
+	}
+
+	scheduled, err := s.services.ScheduledMessageService.ScheduleMessage(r.Context(), req)
+	if err != nil {
+		s.serviceError(w, r, err)
+		return
+	}
+
+	s.writeJSON(w, http.StatusCreated, scheduled)
+}
+
+func (s *Server) listScheduledMessages(w http.ResponseWriter, r *http.Request) {
+	conversationID, err := queryUUID(r, "conversation_id")
+	if err != nil {
+		s.badRequest(w, err)
+		return
+	}
+
+	scheduled, err := s.services.ScheduledMessageService.ListScheduledMessages(r.Context(), conversationID)
+	if err != nil {
+		s.serviceError(w, r, err)
+		return
+	}
+
+	s.writeJSON(w, http.StatusOK, scheduled)
+}
+
+func (s *Server) updateScheduledMessage(w http.ResponseWriter, r *http.Request) {
+	scheduledMessageID, err := pathUUID(r, "scheduledMessageID")
+	if err != nil {
+		s.badRequest(w, err)
+		return
+	}
+
+	var req service.UpdateScheduledMessageRequest
+	if err := readJSON(w, r, &req); err != nil {
+		s.badRequest(w, err)
+		return
+	}
+
+	scheduled, err := s.services.ScheduledMessageService.UpdateScheduledMessage(r.Context(), scheduledMessageID, req)
+	if err != nil {
+		s.serviceError(w, r, err)
+		return
+	}
+
+	s.writeJSON(w, http.StatusOK, scheduled)
+}
+
+func (s *Server) cancelScheduledMessage(w http.ResponseWriter, r *http.Request) {
+	scheduledMessageID, err := pathUUID(r, "scheduledMessageID")
+	if err != nil {
+		s.badRequest(w, err)
+		return
+	}
+
+	if err := s.services.ScheduledMessageService.CancelScheduledMessage(r.Context(), scheduledMessageID); err != nil {
+		s.serviceError(w, r, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -0,0 +1,105 @@
+package api
+
+import (
+	"log/slog"
+	"net/http"
+
+	"github.com/felipedavid/chatting/service"
+)
+
+type Server struct {
+	services *service.Container
+	gateway  http.Handler
+	logger   *slog.Logger
+}
+
+func NewServer(services *service.Container, gateway http.Handler, logger *slog.Logger) *Server {
+	return &Server{services: services, gateway: gateway, logger: logger}
+}
+
+func (s *Server) Routes() http.Handler {
+	mux := http.NewServeMux()
+
+	mux.HandleFunc("POST /auth/code", s.requestVerificationCode)
+	mux.HandleFunc("POST /auth/login", s.login)
+	mux.HandleFunc("POST /auth/refresh", s.refreshSession)
+
+	protected := http.NewServeMux()
+
+	protected.HandleFunc("POST /auth/logout", s.logout)
+
+	protected.HandleFunc("GET /users", s.listUsers)
+	protected.HandleFunc("GET /users/{userID}", s.getUser)
+	protected.HandleFunc("GET /users/phone/{phoneNumber}", s.getUserByPhoneNumber)
+	protected.HandleFunc("DELETE /users/me", s.deleteUser)
+
+	protected.HandleFunc("GET /devices", s.listDevices)
+	protected.HandleFunc("DELETE /devices/{deviceID}", s.deleteDevice)
+
+	protected.HandleFunc("GET /conversations", s.listUserConversations)
+	protected.HandleFunc("POST /conversations", s.createConversation)
+	protected.HandleFunc("GET /conversations/unread", s.listUnreadCounts)
+	protected.HandleFunc("GET /conversations/{conversationID}", s.getConversation)
+	protected.HandleFunc("GET /conversations/{conversationID}/details", s.getConversationWithCreator)
+	protected.HandleFunc("DELETE /conversations/{conversationID}", s.deleteConversation)
+	protected.HandleFunc("PUT /conversations/{conversationID}/mute", s.muteConversation)
+	protected.HandleFunc("DELETE /conversations/{conversationID}/mute", s.unmuteConversation)
+	protected.HandleFunc("PUT /conversations/{conversationID}/pin", s.pinConversation)
+	protected.HandleFunc("DELETE /conversations/{conversationID}/pin", s.unpinConversation)
+	protected.HandleFunc("PUT /conversations/{conversationID}/archive", s.archiveConversation)
+	protected.HandleFunc("DELETE /conversations/{conversationID}/archive", s.unarchiveConversation)
+
+	protected.HandleFunc("GET /conversations/{conversationID}/participants", s.listParticipants)
+	protected.HandleFunc("POST /conversations/{conversationID}/participants", s.addParticipant)
+	protected.HandleFunc("DELETE /conversations/{conversationID}/participants/{userID}", s.removeParticipant)
+
+	protected.HandleFunc("POST /conversations/{conversationID}/messages", s.createMessage)
+	protected.HandleFunc("GET /conversations/{conversationID}/messages", s.listMessages)
+	protected.HandleFunc("GET /conversations/{conversationID}/messages/since", s.listMessagesSince)
+	protected.HandleFunc("GET /conversations/{conversationID}/messages/latest", s.getLatestMessage)
+	protected.HandleFunc("GET /conversations/{conversationID}/messages/count", s.countMessages)
+	protected.HandleFunc("GET /messages/{messageID}", s.getMessage)
+	protected.HandleFunc("PATCH /messages/{messageID}", s.editMessage)
+	protected.HandleFunc("DELETE /messages/{messageID}", s.deleteMessage)
+	protected.HandleFunc("GET /messages/{messageID}/edits", s.listMessageEdits)
+	protected.HandleFunc("POST /messages/{messageID}/forward", s.forwardMessage)
+	protected.HandleFunc("GET /messages/{messageID}/thread", s.getThread)
+	protected.HandleFunc("PUT /messages/{messageID}/thread/follow", s.followThread)
+	protected.HandleFunc("DELETE /messages/{messageID}/thread/follow", s.unfollowThread)
+
+	protected.HandleFunc("GET /messages/{messageID}/poll", s.getPollResults)
+	protected.HandleFunc("PUT /messages/{messageID}/poll/votes", s.votePoll)
+	protected.HandleFunc("DELETE /messages/{messageID}/poll/votes", s.retractPollVote)
+	protected.HandleFunc("POST /messages/{messageID}/poll/close", s.closePoll)
+
+	protected.HandleFunc("GET /messages/{messageID}/reactions", s.listReactions)
+	protected.HandleFunc("PUT /messages/{messageID}/reactions", s.addReaction)
+	protected.HandleFunc("DELETE /messages/{messageID}/reactions", s.removeReaction)
+
+	protected.HandleFunc("POST /messages/{messageID}/delivered", s.markMessageAsDelivered)
+	protected.HandleFunc("POST /messages/{messageID}/read", s.markMessageAsRead)
+	protected.HandleFunc("GET /messages/{messageID}/status", s.getMessageStatus)
+	protected.HandleFunc("POST /conversations/{conversationID}/delivered", s.markConversationDelivered)
+	protected.HandleFunc("POST /conversations/{conversationID}/read", s.markConversationRead)
+
+	protected.HandleFunc("GET /scheduled-messages", s.listScheduledMessages)
+	protected.HandleFunc("POST /scheduled-messages", s.scheduleMessage)
+	protected.HandleFunc("PUT /scheduled-messages/{scheduledMessageID}", s.updateScheduledMessage)
+	protected.HandleFunc("DELETE /scheduled-messages/{scheduledMessageID}", s.cancelScheduledMessage)
+
+	protected.HandleFunc("GET /inbox", s.listInbox)
+
+	protected.HandleFunc("GET /presence/settings", s.getPresenceSettings)
+	protected.HandleFunc("PUT /presence/settings", s.updatePresenceSettings)
+	protected.HandleFunc("GET /presence/{userID}", s.getPresence)
+
+	protected.HandleFunc("GET /mentions", s.listMentions)
+
+	protected.HandleFunc("GET /sync", s.sync)
+
+	protected.Handle("GET /ws", s.gateway)
+
+	mux.Handle("/", s.authenticate(protected))
+
+	return s.logRequests(mux)
+}
@@ -0,0 +1,32 @@
+package api
+
+import (
+	"net/http"
+
+	"github.com/felipedavid/chatting/service"
+)
+
+func (s *Server) sync(w http.ResponseWriter, r *http.Request) {
+	checkpoint, err := queryInt64(r, "checkpoint", 0)
+	if err != nil {
+		s.badRequest(w, err)
+		return
+	}
+
+	limit, err := queryInt32(r, "limit", 0)
+	if err != nil {
+		s.badRequest(w, err)
+		return
+	}
+
+	response, err := s.services.SyncService.Sync(r.Context(), service.SyncRequest{
+		Checkpoint: checkpoint,
+		Limit:      limit,
+	})
+	if err != nil {
+		s.serviceError(w, r, err)
+		return
+	}
+
+	s.writeJSON(w, http.StatusOK, response)
+}
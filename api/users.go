@@ -0,0 +1,75 @@
+package api
+
+import (
+	"net/http"
+)
+
+func (s *Server) listUsers(w http.ResponseWriter, r *http.Request) {
+	users, err := s.services.UserService.ListUsers(r.Context())
+	if err != nil {
+		s.serviceError(w, r, err)
+		return
+	}
+
+	s.writeJSON(w, http.StatusOK, users)
+}
+
+func (s *Server) getUser(w http.ResponseWriter, r *http.Request) {
+	userID, err := pathUUID(r, "userID")
+	if err != nil {
+		s.badRequest(w, err)
+		return
+	}
+
+	user, err := s.services.UserService.GetUser(r.Context(), userID)
+	if err != nil {
+		s.serviceError(w, r, err)
+		return
+	}
+
+	s.writeJSON(w, http.StatusOK, user)
+}
+
+func (s *Server) getUserByPhoneNumber(w http.ResponseWriter, r *http.Request) {
+	user, err := s.services.UserService.GetUserByPhoneNumber(r.Context(), r.PathValue("phoneNumber"))
+	if err != nil {
+		s.serviceError(w, r, err)
+		return
+	}
+
+	s.writeJSON(w, http.StatusOK, user)
+}
+
+func (s *Server) deleteUser(w http.ResponseWriter, r *http.Request) {
+	if err := s.services.UserService.DeleteUser(r.Context()); err != nil {
+		s.serviceError(w, r, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (s *Server) listDevices(w http.ResponseWriter, r *http.Request) {
+	devices, err := s.services.UserService.ListUserDevices(r.Context())
+	if err != nil {
+		s.serviceError(w, r, err)
+		return
+	}
+
+	s.writeJSON(w, http.StatusOK, devices)
+}
+
+func (s *Server) deleteDevice(w http.ResponseWriter, r *http.Request) {
+	deviceID, err := pathUUID(r, "deviceID")
+	if err != nil {
+		s.badRequest(w, err)
+		return
+	}
+
+	if err := s.services.UserService.DeleteUserDevice(r.Context(), deviceID); err != nil {
+		s.serviceError(w, r, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
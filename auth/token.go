@@ -0,0 +1,117 @@
+package auth
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/jackc/pgx/v5/pgtype"
+)
+
+const issuer = "chatting"
+
+var ErrInvalidToken = errors.New("invalid or expired token")
+
+// Claims identifies the session and device an access token was issued to.
+type Claims struct {
+	UserID    pgtype.UUID
+	DeviceID  pgtype.UUID
+	SessionID pgtype.UUID
+	ExpiresAt time.Time
+}
+
+type accessClaims struct {
+	jwt.RegisteredClaims
+	DeviceID string `json:"did"`
+}
+
+// TokenManager signs and verifies short-lived access tokens with HMAC-SHA256.
+type TokenManager struct {
+	secret []byte
+	ttl    time.Duration
+}
+
+func NewTokenManager(secret []byte, ttl time.Duration) *TokenManager {
+	return &TokenManager{secret: secret, ttl: ttl}
+}
+
+func (m *TokenManager) Issue(userID, deviceID, sessionID pgtype.UUID) (string, time.Time, error) {
+	now := time.Now()
+	expiresAt := now.Add(m.ttl)
+
+	token := jwt.NewWithClaims(jwt.SigningMethodHS256, accessClaims{
+		RegisteredClaims: jwt.RegisteredClaims{
+			Issuer:    issuer,
+			Subject:   userID.String(),
+			ID:        sessionID.String(),
+			IssuedAt:  jwt.NewNumericDate(now),
+			ExpiresAt: jwt.NewNumericDate(expiresAt),
+		},
+		DeviceID: deviceID.String(),
+	})
+
+	signed, err := token.SignedString(m.secret)
+	if err != nil {
+		return "", time.Time{}, fmt.Errorf("failed to sign access token: %w", err)
+	}
+
+	return signed, expiresAt, nil
+}
+
+// HashCode keys a one-time code sent to phoneNumber with the server secret,
+// so the few possible codes cannot be tried against a leaked hash.
+func (m *TokenManager) HashCode(phoneNumber, code string) string {
+	mac := hmac.New(sha256.New, m.secret)
+	mac.Write([]byte(phoneNumber + ":" + code))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// Parse verifies the token signature and expiry. It does not check whether
+// the session has been revoked since the token was issued.
+func (m *TokenManager) Parse(token string) (Claims, error) {
+	var claims accessClaims
+	_, err := jwt.ParseWithClaims(token, &claims, func(*jwt.Token) (any, error) {
+		return m.secret, nil
+	},
+		jwt.WithValidMethods([]string{jwt.SigningMethodHS256.Alg()}),
+		jwt.WithIssuer(issuer),
+		jwt.WithExpirationRequired(),
+	)
+	if err != nil {
+		return Claims{}, ErrInvalidToken
+	}
+
+	var parsed Claims
+	if err := parsed.UserID.Scan(claims.Subject); err != nil {
+		return Claims{}, ErrInvalidToken
+	}
+	if err := parsed.DeviceID.Scan(claims.DeviceID); err != nil {
+		return Claims{}, ErrInvalidToken
+	}
+	if err := parsed.SessionID.Scan(claims.ID); err != nil {
+		return Claims{}, ErrInvalidToken
+	}
+	parsed.ExpiresAt = claims.ExpiresAt.Time
+
+	return parsed, nil
+}
+
+// NewRefreshToken returns an opaque random token. Only its hash is stored.
+func NewRefreshToken() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", fmt.Errorf("failed to generate refresh token: %w", err)
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+func HashRefreshToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
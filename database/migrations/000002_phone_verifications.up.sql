@@ -0,0 +1,11 @@
+CREATE TABLE phone_verifications (
+    id             UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    phone_number   TEXT NOT NULL,
+    code_hash      TEXT NOT NULL,
+    attempts       INT NOT NULL DEFAULT 0,
+    expires_at     TIMESTAMPTZ NOT NULL,
+    verified_at    TIMESTAMPTZ,
+    created_at     TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+
+CREATE INDEX phone_verifications_phone_number_idx ON phone_verifications (phone_number, created_at DESC);
\ No newline at end of file
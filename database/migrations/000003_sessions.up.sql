@@ -0,0 +1,12 @@
+CREATE TABLE sessions (
+    id                  UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    user_id             UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    device_id           UUID NOT NULL REFERENCES user_devices(id) ON DELETE CASCADE,
+    refresh_token_hash  TEXT UNIQUE NOT NULL,
+    expires_at          TIMESTAMPTZ NOT NULL,
+    revoked_at          TIMESTAMPTZ,
+    last_used_at        TIMESTAMPTZ NOT NULL DEFAULT now(),
+    created_at          TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+
+CREATE INDEX sessions_device_id_idx ON sessions (device_id);
\ No newline at end of file
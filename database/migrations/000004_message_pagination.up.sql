@@ -0,0 +1 @@
+CREATE INDEX messages_conversation_created_at_id_idx ON messages (conversation_id, created_at, id);
\ No newline at end of file
@@ -0,0 +1,5 @@
+ALTER TABLE messages DROP CONSTRAINT IF EXISTS messages_conversation_seq_key;
+
+ALTER TABLE messages DROP COLUMN IF EXISTS seq;
+
+ALTER TABLE conversations DROP COLUMN IF EXISTS last_message_seq;
\ No newline at end of file
@@ -0,0 +1,24 @@
+ALTER TABLE conversations ADD COLUMN last_message_seq BIGINT NOT NULL DEFAULT 0;
+
+ALTER TABLE messages ADD COLUMN seq BIGINT;
+
+UPDATE messages m
+SET seq = numbered.seq
+FROM (
+    SELECT id, row_number() OVER (PARTITION BY conversation_id ORDER BY created_at, id) AS seq
+    FROM messages
+) numbered
+WHERE m.id = numbered.id;
+
+UPDATE conversations c
+SET last_message_seq = latest.seq
+FROM (
+    SELECT conversation_id, MAX(seq) AS seq
+    FROM messages
+    GROUP BY conversation_id
+) latest
+WHERE c.id = latest.conversation_id;
+
+ALTER TABLE messages ALTER COLUMN seq SET NOT NULL;
+
+ALTER TABLE messages ADD CONSTRAINT messages_conversation_seq_key UNIQUE (conversation_id, seq);
\ No newline at end of file
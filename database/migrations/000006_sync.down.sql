@@ -0,0 +1,3 @@
+ALTER TABLE user_devices DROP COLUMN IF EXISTS sync_checkpoint;
+
+DROP TABLE IF EXISTS conversation_events;
\ No newline at end of file
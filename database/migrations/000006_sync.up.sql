@@ -0,0 +1,13 @@
+CREATE TABLE conversation_events (
+    id               BIGSERIAL PRIMARY KEY,
+    conversation_id  UUID NOT NULL REFERENCES conversations(id) ON DELETE CASCADE,
+    event_type       TEXT NOT NULL,
+    subject_id       UUID REFERENCES users(id) ON DELETE CASCADE, -- user affected even if no longer a participant
+    payload          JSONB NOT NULL,
+    created_at       TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+
+CREATE INDEX conversation_events_conversation_id_idx ON conversation_events (conversation_id, id);
+CREATE INDEX conversation_events_subject_id_idx ON conversation_events (subject_id, id) WHERE subject_id IS NOT NULL;
+
+ALTER TABLE user_devices ADD COLUMN sync_checkpoint BIGINT NOT NULL DEFAULT 0;
\ No newline at end of file
@@ -0,0 +1,4 @@
+DROP INDEX IF EXISTS messages_sender_device_client_message_id_key;
+
+ALTER TABLE messages DROP COLUMN IF EXISTS client_message_id;
+ALTER TABLE messages DROP COLUMN IF EXISTS sender_device_id;
\ No newline at end of file
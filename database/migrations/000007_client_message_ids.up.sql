@@ -0,0 +1,6 @@
+ALTER TABLE messages ADD COLUMN sender_device_id UUID REFERENCES user_devices(id) ON DELETE SET NULL;
+ALTER TABLE messages ADD COLUMN client_message_id TEXT;
+
+CREATE UNIQUE INDEX messages_sender_device_client_message_id_key
+    ON messages (sender_device_id, client_message_id)
+    WHERE client_message_id IS NOT NULL;
\ No newline at end of file
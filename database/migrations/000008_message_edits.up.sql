@@ -0,0 +1,10 @@
+ALTER TABLE messages ADD COLUMN edited_at TIMESTAMPTZ;
+
+CREATE TABLE message_edits (
+    id                UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    message_id        UUID NOT NULL REFERENCES messages(id) ON DELETE CASCADE,
+    previous_content  TEXT,
+    edited_at         TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+
+CREATE INDEX message_edits_message_id_idx ON message_edits (message_id, edited_at);
\ No newline at end of file
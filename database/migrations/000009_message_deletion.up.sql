@@ -0,0 +1,10 @@
+ALTER TABLE messages ADD COLUMN deleted_at TIMESTAMPTZ;
+
+CREATE TABLE hidden_messages (
+    user_id     UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    message_id  UUID NOT NULL REFERENCES messages(id) ON DELETE CASCADE,
+    hidden_at   TIMESTAMPTZ NOT NULL DEFAULT now(),
+    PRIMARY KEY (user_id, message_id)
+);
+
+CREATE INDEX hidden_messages_message_id_idx ON hidden_messages (message_id);
\ No newline at end of file
@@ -0,0 +1 @@
+ALTER TABLE messages DROP COLUMN IF EXISTS forward_count;
\ No newline at end of file
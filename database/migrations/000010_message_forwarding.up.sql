@@ -0,0 +1 @@
+ALTER TABLE messages ADD COLUMN forward_count INT NOT NULL DEFAULT 0;
\ No newline at end of file
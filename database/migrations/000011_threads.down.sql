@@ -0,0 +1,7 @@
+DROP TABLE IF EXISTS thread_followers;
+
+DROP INDEX IF EXISTS messages_thread_root_id_idx;
+
+ALTER TABLE messages DROP COLUMN IF EXISTS thread_last_reply_at;
+ALTER TABLE messages DROP COLUMN IF EXISTS thread_reply_count;
+ALTER TABLE messages DROP COLUMN IF EXISTS thread_root_id;
\ No newline at end of file
@@ -0,0 +1,15 @@
+ALTER TABLE messages ADD COLUMN thread_root_id UUID REFERENCES messages(id) ON DELETE SET NULL;
+ALTER TABLE messages ADD COLUMN thread_reply_count INT NOT NULL DEFAULT 0;
+ALTER TABLE messages ADD COLUMN thread_last_reply_at TIMESTAMPTZ;
+
+CREATE INDEX messages_thread_root_id_idx ON messages (thread_root_id, created_at, id)
+WHERE thread_root_id IS NOT NULL;
+
+CREATE TABLE thread_followers (
+    message_id   UUID NOT NULL REFERENCES messages(id) ON DELETE CASCADE,
+    user_id      UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    followed_at  TIMESTAMPTZ NOT NULL DEFAULT now(),
+    PRIMARY KEY (message_id, user_id)
+);
+
+CREATE INDEX thread_followers_user_id_idx ON thread_followers (user_id);
\ No newline at end of file
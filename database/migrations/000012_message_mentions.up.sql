@@ -0,0 +1,9 @@
+CREATE TABLE message_mentions (
+    message_id       UUID NOT NULL REFERENCES messages(id) ON DELETE CASCADE,
+    conversation_id  UUID NOT NULL REFERENCES conversations(id) ON DELETE CASCADE,
+    user_id          UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    created_at       TIMESTAMPTZ NOT NULL DEFAULT now(),
+    PRIMARY KEY (message_id, user_id)
+);
+
+CREATE INDEX message_mentions_user_id_idx ON message_mentions (user_id, conversation_id);
\ No newline at end of file
@@ -0,0 +1 @@
+ALTER TABLE messages ADD COLUMN payload JSONB;
\ No newline at end of file
@@ -0,0 +1,16 @@
+CREATE TABLE polls (
+    message_id       UUID PRIMARY KEY REFERENCES messages(id) ON DELETE CASCADE,
+    multiple_choice  BOOLEAN NOT NULL DEFAULT FALSE,
+    option_count     INT NOT NULL CHECK (option_count > 0),
+    closes_at        TIMESTAMPTZ,
+    closed_at        TIMESTAMPTZ,
+    created_at       TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+
+CREATE TABLE poll_votes (
+    message_id    UUID NOT NULL REFERENCES polls(message_id) ON DELETE CASCADE,
+    user_id       UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    option_index  INT NOT NULL CHECK (option_index >= 0),
+    voted_at      TIMESTAMPTZ NOT NULL DEFAULT now(),
+    PRIMARY KEY (message_id, user_id, option_index)
+);
\ No newline at end of file
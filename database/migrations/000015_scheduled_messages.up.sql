@@ -0,0 +1,24 @@
+CREATE TABLE scheduled_messages (
+    id               UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    conversation_id  UUID NOT NULL REFERENCES conversations(id) ON DELETE CASCADE,
+    user_id          UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    device_id        UUID NOT NULL REFERENCES user_devices(id) ON DELETE CASCADE,
+    content          TEXT,
+    message_type     TEXT NOT NULL DEFAULT 'text',
+    payload          JSONB,
+    reply_to_id      UUID REFERENCES messages(id) ON DELETE SET NULL,
+    thread_root_id   UUID REFERENCES messages(id) ON DELETE SET NULL,
+    send_at          TIMESTAMPTZ NOT NULL,
+    status           TEXT NOT NULL DEFAULT 'pending' CHECK (status IN ('pending', 'sent', 'failed', 'cancelled')),
+    attempts         INT NOT NULL DEFAULT 0,
+    claimed_until    TIMESTAMPTZ,
+    message_id       UUID REFERENCES messages(id) ON DELETE SET NULL,
+    last_error       TEXT,
+    created_at       TIMESTAMPTZ NOT NULL DEFAULT now(),
+    updated_at       TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+
+CREATE INDEX scheduled_messages_due_idx ON scheduled_messages (send_at)
+WHERE status = 'pending';
+
+CREATE INDEX scheduled_messages_user_id_idx ON scheduled_messages (user_id, send_at);
\ No newline at end of file
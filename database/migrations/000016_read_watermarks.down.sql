@@ -0,0 +1,4 @@
+DROP INDEX IF EXISTS conversation_participants_user_id_idx;
+
+ALTER TABLE conversation_participants DROP COLUMN IF EXISTS unread_mention_count;
+ALTER TABLE conversation_participants DROP COLUMN IF EXISTS last_read_seq;
\ No newline at end of file
@@ -0,0 +1,34 @@
+ALTER TABLE conversation_participants ADD COLUMN last_read_seq BIGINT NOT NULL DEFAULT 0;
+ALTER TABLE conversation_participants ADD COLUMN unread_mention_count INT NOT NULL DEFAULT 0;
+
+CREATE INDEX conversation_participants_user_id_idx ON conversation_participants (user_id);
+
+-- Start each participant's watermark at the latest message they sent or
+-- have a read receipt for.
+UPDATE conversation_participants cp
+SET last_read_seq = seen.seq
+FROM (
+    SELECT p.conversation_id, p.user_id, MAX(m.seq) AS seq
+    FROM conversation_participants p
+    JOIN messages m ON m.conversation_id = p.conversation_id
+    WHERE m.sender_id = p.user_id
+       OR EXISTS (
+           SELECT 1 FROM message_receipts r
+           WHERE r.message_id = m.id AND r.user_id = p.user_id AND r.read_at IS NOT NULL
+       )
+    GROUP BY p.conversation_id, p.user_id
+) seen
+WHERE cp.conversation_id = seen.conversation_id AND cp.user_id = seen.user_id;
+
+UPDATE conversation_participants cp
+SET unread_mention_count = pending.mentions
+FROM (
+    SELECT mm.conversation_id, mm.user_id, COUNT(*) AS mentions
+    FROM message_mentions mm
+    JOIN messages m ON m.id = mm.message_id
+    JOIN conversation_participants p
+      ON p.conversation_id = mm.conversation_id AND p.user_id = mm.user_id
+    WHERE m.seq > p.last_read_seq
+    GROUP BY mm.conversation_id, mm.user_id
+) pending
+WHERE cp.conversation_id = pending.conversation_id AND cp.user_id = pending.user_id;
\ No newline at end of file
@@ -0,0 +1,3 @@
+ALTER TABLE conversation_participants ADD COLUMN muted_until TIMESTAMPTZ;
+ALTER TABLE conversation_participants ADD COLUMN pinned_at TIMESTAMPTZ;
+ALTER TABLE conversation_participants ADD COLUMN archived_at TIMESTAMPTZ;
\ No newline at end of file
@@ -0,0 +1,6 @@
+DROP INDEX IF EXISTS user_devices_presence_expires_at_idx;
+DROP INDEX IF EXISTS user_devices_user_id_idx;
+
+ALTER TABLE user_devices DROP COLUMN IF EXISTS presence_expires_at;
+ALTER TABLE users DROP COLUMN IF EXISTS presence_visibility;
+ALTER TABLE users DROP COLUMN IF EXISTS last_seen_at;
\ No newline at end of file
@@ -0,0 +1,12 @@
+ALTER TABLE users ADD COLUMN last_seen_at TIMESTAMPTZ;
+ALTER TABLE users ADD COLUMN presence_visibility TEXT NOT NULL DEFAULT 'everyone'
+    CHECK (presence_visibility IN ('everyone', 'contacts', 'nobody'));
+
+-- A device is online while its lease has not expired. The gateway renews the
+-- lease on every heartbeat, so devices whose server goes away drop offline
+-- on their own.
+ALTER TABLE user_devices ADD COLUMN presence_expires_at TIMESTAMPTZ;
+
+CREATE INDEX user_devices_user_id_idx ON user_devices (user_id);
+CREATE INDEX user_devices_presence_expires_at_idx ON user_devices (presence_expires_at)
+    WHERE presence_expires_at IS NOT NULL;
\ No newline at end of file
@@ -0,0 +1,17 @@
+DELETE FROM conversation_events e
+WHERE NOT EXISTS (SELECT 1 FROM conversations c WHERE c.id = e.conversation_id);
+
+ALTER TABLE conversation_events
+    ADD CONSTRAINT conversation_events_conversation_id_fkey
+    FOREIGN KEY (conversation_id) REFERENCES conversations(id) ON DELETE CASCADE;
+
+ALTER TABLE conversation_events ADD COLUMN subject_id UUID REFERENCES users(id) ON DELETE CASCADE;
+
+UPDATE conversation_events e
+SET subject_id = s.user_id
+FROM conversation_event_subjects s
+WHERE s.event_id = e.id;
+
+CREATE INDEX conversation_events_subject_id_idx ON conversation_events (subject_id, id) WHERE subject_id IS NOT NULL;
+
+DROP TABLE IF EXISTS conversation_event_subjects;
@@ -0,0 +1,21 @@
+-- Subjects see an event during sync whether or not they participate in its
+-- conversation, such as a removed participant or the members of a deleted
+-- conversation.
+CREATE TABLE conversation_event_subjects (
+    event_id  BIGINT NOT NULL REFERENCES conversation_events(id) ON DELETE CASCADE,
+    user_id   UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    PRIMARY KEY (user_id, event_id)
+);
+
+CREATE INDEX conversation_event_subjects_event_id_idx ON conversation_event_subjects (event_id);
+
+INSERT INTO conversation_event_subjects (event_id, user_id)
+SELECT id, subject_id FROM conversation_events
+WHERE subject_id IS NOT NULL;
+
+DROP INDEX IF EXISTS conversation_events_subject_id_idx;
+ALTER TABLE conversation_events DROP COLUMN subject_id;
+
+-- Events outlive their conversation, so a deleted conversation can still
+-- tell its former members that it is gone.
+ALTER TABLE conversation_events DROP CONSTRAINT IF EXISTS conversation_events_conversation_id_fkey;
@@ -0,0 +1,3 @@
+DELETE FROM conversation_events WHERE private;
+
+ALTER TABLE conversation_events DROP COLUMN private;
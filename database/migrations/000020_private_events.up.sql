@@ -0,0 +1,3 @@
+-- Private events, such as receipts, are only synced to their subjects
+-- rather than to every participant of the conversation.
+ALTER TABLE conversation_events ADD COLUMN private BOOLEAN NOT NULL DEFAULT false;
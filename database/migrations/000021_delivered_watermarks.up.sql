@@ -0,0 +1,4 @@
+ALTER TABLE conversation_participants ADD COLUMN last_delivered_seq BIGINT NOT NULL DEFAULT 0;
+
+-- Everything a participant has read has reached them.
+UPDATE conversation_participants SET last_delivered_seq = last_read_seq;
@@ -0,0 +1,9 @@
+-- Events too large for a NOTIFY payload are stored here and referenced by
+-- ID instead. Rows are only needed until every listener has loaded them.
+CREATE TABLE bus_events (
+    id          BIGSERIAL PRIMARY KEY,
+    envelope    JSONB NOT NULL,
+    created_at  TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX bus_events_created_at_idx ON bus_events (created_at);
@@ -0,0 +1,6 @@
+DROP INDEX IF EXISTS conversation_events_unsettled_idx;
+DROP INDEX IF EXISTS conversation_events_sync_position_idx;
+ALTER TABLE conversation_events
+    DROP COLUMN IF EXISTS sync_position,
+    DROP COLUMN IF EXISTS xact_id;
+DROP SEQUENCE IF EXISTS conversation_events_sync_position_seq;
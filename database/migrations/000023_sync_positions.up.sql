@@ -0,0 +1,21 @@
+-- Event IDs are allocated before commit, so they do not say in which order
+-- events become visible. Each event remembers the transaction that recorded
+-- it and is given a sync position only once that transaction, and every one
+-- that started before it, has finished.
+ALTER TABLE conversation_events
+    ADD COLUMN xact_id BIGINT NOT NULL DEFAULT pg_current_xact_id()::text::bigint,
+    ADD COLUMN sync_position BIGINT;
+
+CREATE SEQUENCE conversation_events_sync_position_seq;
+
+-- Every existing event has committed; keep the checkpoints devices already
+-- hold valid by reusing the event IDs as positions.
+UPDATE conversation_events SET sync_position = id;
+SELECT setval(
+    'conversation_events_sync_position_seq',
+    COALESCE((SELECT MAX(id) FROM conversation_events), 0) + 1,
+    false
+);
+
+CREATE UNIQUE INDEX conversation_events_sync_position_idx ON conversation_events (sync_position);
+CREATE INDEX conversation_events_unsettled_idx ON conversation_events (id) WHERE sync_position IS NULL;
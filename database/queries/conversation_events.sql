@@ -0,0 +1,54 @@
+-- name: CreateConversationEvent :one
+INSERT INTO conversation_events (
+  conversation_id, event_type, payload, private
+) VALUES (
+  $1, $2, $3, $4
+)
+RETURNING *;
+
+-- name: CreateConversationEventSubjects :exec
+INSERT INTO conversation_event_subjects (
+  event_id, user_id
+)
+SELECT sqlc.arg(event_id)::bigint, unnest(sqlc.arg(user_ids)::uuid[])
+ON CONFLICT DO NOTHING;
+
+-- name: DeleteConversationEvents :exec
+DELETE FROM conversation_events
+WHERE conversation_id = $1;
+
+-- name: SettleConversationEvents :exec
+-- Gives a sync position to every unsettled event whose transaction finished
+-- before the oldest one still running began. Concurrent calls queue on the
+-- row locks, so positions become visible in the order they are handed out
+-- and a checkpoint never passes an event that commits later.
+WITH settled AS (
+    SELECT id FROM conversation_events
+    WHERE sync_position IS NULL
+      AND xact_id < pg_snapshot_xmin(pg_current_snapshot())::text::bigint
+    ORDER BY id
+    FOR UPDATE
+)
+UPDATE conversation_events e
+SET sync_position = nextval('conversation_events_sync_position_seq')
+FROM settled
+WHERE e.id = settled.id;
+
+-- name: ListSyncEvents :many
+-- Public events from every conversation the user participates in, plus
+-- events the user is a subject of, such as their own removal or receipts for
+-- their messages.
+SELECT * FROM conversation_events
+WHERE sync_position > sqlc.arg(checkpoint)
+  AND (
+    (NOT private AND conversation_id IN (
+      SELECT conversation_id FROM conversation_participants
+      WHERE user_id = sqlc.arg(user_id)
+    ))
+    OR id IN (
+      SELECT event_id FROM conversation_event_subjects
+      WHERE user_id = sqlc.arg(user_id)
+    )
+  )
+ORDER BY sync_position ASC
+LIMIT sqlc.arg('limit');
@@ -27,25 +27,67 @@ JOIN conversations c ON cp.conversation_id = c.id
 WHERE cp.user_id = $1
 ORDER BY cp.joined_at DESC;
 
--- name: ListUserConversationsWithLastMessage :many
-SELECT cp.*, c.title, c.is_group, c.created_at as conversation_created_at,
-       m.content as last_message_content, m.created_at as last_message_at
+-- name: ListInbox :many
+-- Conversations are ordered by their latest activity for the user: the last
+-- message they can see, or when they joined if that is newer. 1:1
+-- conversations carry the other participant. Messages the user deleted for
+-- themselves are neither previewed nor counted as unread. A NULL cursor
+-- starts from the most recent conversation.
+SELECT c.id, c.is_group, c.title,
+       other.user_id AS other_user_id,
+       other.display_name AS other_display_name,
+       other.phone_number AS other_phone_number,
+       lm.id AS last_message_id,
+       lm.sender_id AS last_message_sender_id,
+       sender.display_name AS last_message_sender_name,
+       lm.content AS last_message_content,
+       lm.message_type AS last_message_type,
+       lm.created_at AS last_message_at,
+       lm.deleted_at AS last_message_deleted_at,
+       (c.last_message_seq - cp.last_read_seq - (
+         SELECT COUNT(*) FROM hidden_messages h
+         JOIN messages hm ON hm.id = h.message_id
+         WHERE h.user_id = cp.user_id
+           AND hm.conversation_id = cp.conversation_id
+           AND hm.seq > cp.last_read_seq
+       ))::bigint AS unread_count,
+       cp.unread_mention_count, cp.muted_until, cp.pinned_at, cp.archived_at,
+       GREATEST(lm.created_at, cp.joined_at)::timestamptz AS activity_at
 FROM conversation_participants cp
-JOIN conversations c ON cp.conversation_id = c.id
-LEFT JOIN messages m ON m.conversation_id = c.id
-WHERE cp.user_id = $1
-  AND m.created_at = (
-    SELECT MAX(created_at) 
-    FROM messages 
-    WHERE conversation_id = c.id
-  )
-ORDER BY m.created_at DESC;
+JOIN conversations c ON c.id = cp.conversation_id
+LEFT JOIN LATERAL (
+  SELECT m.* FROM messages m
+  WHERE m.conversation_id = c.id
+    AND NOT EXISTS (
+      SELECT 1 FROM hidden_messages h
+      WHERE h.message_id = m.id AND h.user_id = cp.user_id
+    )
+  ORDER BY m.seq DESC
+  LIMIT 1
+) lm ON true
+LEFT JOIN users sender ON sender.id = lm.sender_id
+LEFT JOIN LATERAL (
+  SELECT u.id AS user_id, u.display_name, u.phone_number
+  FROM conversation_participants op
+  JOIN users u ON u.id = op.user_id
+  WHERE op.conversation_id = c.id AND op.user_id != cp.user_id
+  LIMIT 1
+) other ON NOT c.is_group
+WHERE cp.user_id = sqlc.arg(user_id)
+  AND (sqlc.narg(activity_at)::timestamptz IS NULL
+    OR (GREATEST(lm.created_at, cp.joined_at), c.id) < (sqlc.narg(activity_at)::timestamptz, sqlc.narg(id)::uuid))
+ORDER BY activity_at DESC, c.id DESC
+LIMIT sqlc.arg('limit');
 
 -- name: AddConversationParticipant :one
+-- New participants start with everything already sent marked as delivered
+-- and read.
 INSERT INTO conversation_participants (
-  conversation_id, user_id, role
+  conversation_id, user_id, role, last_read_seq, last_delivered_seq
 ) VALUES (
-  $1, $2, $3
+  $1, $2, $3,
+  COALESCE((SELECT last_message_seq FROM conversations WHERE id = $1), 0),
+  COALESCE((SELECT last_message_seq FROM conversations WHERE id = $1), 0)
 )
 RETURNING *;
 
@@ -71,4 +113,92 @@ WHERE conversation_id = $1;
 SELECT EXISTS(
   SELECT 1 FROM conversation_participants
   WHERE conversation_id = $1 AND user_id = $2
-);
\ No newline at end of file
+);
+
+-- name: AdvanceReadWatermark :one
+-- Moves the participant's watermark forward to seq, never back, and recounts
+-- the mentions of them that are still above it. What they read has also
+-- reached them, so the delivered watermark follows.
+UPDATE conversation_participants cp
+SET last_read_seq = GREATEST(cp.last_read_seq, sqlc.arg(seq)::bigint),
+    last_delivered_seq = GREATEST(cp.last_delivered_seq, sqlc.arg(seq)::bigint),
+    unread_mention_count = (
+      SELECT COUNT(*) FROM message_mentions mm
+      JOIN messages m ON m.id = mm.message_id
+      WHERE mm.user_id = cp.user_id
+        AND mm.conversation_id = cp.conversation_id
+        AND m.seq > GREATEST(cp.last_read_seq, sqlc.arg(seq)::bigint)
+        AND m.deleted_at IS NULL
+    )::int
+WHERE cp.conversation_id = sqlc.arg(conversation_id) AND cp.user_id = sqlc.arg(user_id)
+RETURNING *;
+
+-- name: AdvanceDeliveredWatermark :exec
+-- Moves the participant's delivered watermark forward to seq, never back.
+UPDATE conversation_participants
+SET last_delivered_seq = GREATEST(last_delivered_seq, sqlc.arg(seq)::bigint)
+WHERE conversation_id = sqlc.arg(conversation_id) AND user_id = sqlc.arg(user_id);
+
+-- name: IncrementUnreadMentions :exec
+UPDATE conversation_participants
+SET unread_mention_count = unread_mention_count + 1
+WHERE conversation_id = sqlc.arg(conversation_id) AND user_id = ANY(sqlc.arg(user_ids)::uuid[]);
+
+-- name: RecountUnreadMentions :exec
+-- Recounts the mentions of each user that are above their watermark, for
+-- when a message's mentions change.
+UPDATE conversation_participants cp
+SET unread_mention_count = (
+      SELECT COUNT(*) FROM message_mentions mm
+      JOIN messages m ON m.id = mm.message_id
+      WHERE mm.user_id = cp.user_id
+        AND mm.conversation_id = cp.conversation_id
+        AND m.seq > cp.last_read_seq
+        AND m.deleted_at IS NULL
+    )::int
+WHERE cp.conversation_id = sqlc.arg(conversation_id) AND cp.user_id = ANY(sqlc.arg(user_ids)::uuid[]);
+
+-- name: ListUnreadCounts :many
+-- Unread counts come from the watermark, less the messages above it the
+-- user deleted for themselves, so conversations with nothing unread are
+-- skipped without touching messages.
+SELECT conversation_id, unread_count, unread_mention_count
+FROM (
+  SELECT cp.conversation_id,
+         (c.last_message_seq - cp.last_read_seq - (
+           SELECT COUNT(*) FROM hidden_messages h
+           JOIN messages hm ON hm.id = h.message_id
+           WHERE h.user_id = cp.user_id
+             AND hm.conversation_id = cp.conversation_id
+             AND hm.seq > cp.last_read_seq
+         ))::bigint AS unread_count,
+         cp.unread_mention_count,
+         c.last_message_seq
+  FROM conversation_participants cp
+  JOIN conversations c ON c.id = cp.conversation_id
+  WHERE cp.user_id = $1
+    AND (c.last_message_seq > cp.last_read_seq OR cp.unread_mention_count > 0)
+) counts
+WHERE unread_count > 0 OR unread_mention_count > 0
+ORDER BY last_message_seq DESC;
+
+-- name: SetConversationMuted :one
+-- A NULL muted_until unmutes the conversation; 'infinity' mutes it until
+-- the user unmutes it.
+UPDATE conversation_participants
+SET muted_until = sqlc.narg(muted_until)
+WHERE conversation_id = sqlc.arg(conversation_id) AND user_id = sqlc.arg(user_id)
+RETURNING *;
+
+-- name: SetConversationPinned :one
+-- Pinning a pinned conversation keeps its original pin time.
+UPDATE conversation_participants
+SET pinned_at = CASE WHEN sqlc.arg(pinned)::bool THEN COALESCE(pinned_at, NOW()) END
+WHERE conversation_id = sqlc.arg(conversation_id) AND user_id = sqlc.arg(user_id)
+RETURNING *;
+
+-- name: SetConversationArchived :one
+UPDATE conversation_participants
+SET archived_at = CASE WHEN sqlc.arg(archived)::bool THEN COALESCE(archived_at, NOW()) END
+WHERE conversation_id = sqlc.arg(conversation_id) AND user_id = sqlc.arg(user_id)
+RETURNING *;
\ No newline at end of file
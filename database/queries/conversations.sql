@@ -46,6 +46,12 @@ RETURNING *;
 DELETE FROM conversations
 WHERE id = $1;
 
+-- name: ClearConversationCreator :exec
+-- Forgets a user who is being deleted as the creator of their conversations.
+UPDATE conversations
+SET created_by = NULL
+WHERE created_by = $1;
+
 -- name: SearchConversationsByTitle :many
 SELECT * FROM conversations
 WHERE title ILIKE '%' || $1 || '%'
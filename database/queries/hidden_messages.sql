@@ -0,0 +1,12 @@
+-- name: HideMessage :exec
+INSERT INTO hidden_messages (
+  user_id, message_id
+) VALUES (
+  $1, $2
+)
+ON CONFLICT DO NOTHING;
+
+-- name: ListHiddenMessageIDs :many
+-- Returns those of the given messages the user deleted for themselves.
+SELECT message_id FROM hidden_messages
+WHERE user_id = sqlc.arg(user_id) AND message_id = ANY(sqlc.arg(message_ids)::uuid[]);
@@ -29,6 +29,14 @@ INSERT INTO media (
 )
 RETURNING *;
 
+-- name: CopyMessageMedia :exec
+INSERT INTO media (
+  message_id, file_url, mime_type, file_size
+)
+SELECT sqlc.arg(target_message_id)::uuid, file_url, mime_type, file_size
+FROM media
+WHERE message_id = sqlc.arg(source_message_id)::uuid;
+
 -- name: UpdateMediaFileInfo :one
 UPDATE media
 SET file_url = $2,
@@ -0,0 +1,16 @@
+-- name: CreateMessageEdit :one
+INSERT INTO message_edits (
+  message_id, previous_content
+) VALUES (
+  $1, $2
+)
+RETURNING *;
+
+-- name: ListMessageEdits :many
+SELECT * FROM message_edits
+WHERE message_id = $1
+ORDER BY edited_at ASC;
+
+-- name: DeleteMessageEdits :exec
+DELETE FROM message_edits
+WHERE message_id = $1;
\ No newline at end of file
@@ -0,0 +1,35 @@
+-- name: CreateMessageMentions :exec
+INSERT INTO message_mentions (
+  message_id, conversation_id, user_id
+)
+SELECT sqlc.arg(message_id)::uuid, sqlc.arg(conversation_id)::uuid, unnest(sqlc.arg(user_ids)::uuid[])
+ON CONFLICT DO NOTHING;
+
+-- name: DeleteMessageMentions :exec
+DELETE FROM message_mentions
+WHERE message_id = $1;
+
+-- name: ListMessageMentions :many
+-- Returns the mentions of every given message, grouped by message.
+SELECT * FROM message_mentions
+WHERE message_id = ANY(sqlc.arg(message_ids)::uuid[])
+ORDER BY message_id, user_id;
+
+-- name: ListUserMentions :many
+-- Mentions are listed newest first. A NULL cursor starts from the latest one.
+SELECT m.* FROM message_mentions mm
+JOIN messages m ON m.id = mm.message_id
+WHERE mm.user_id = sqlc.arg(user_id)
+  AND (sqlc.narg(created_at)::timestamptz IS NULL
+    OR (m.created_at, m.id) < (sqlc.narg(created_at)::timestamptz, sqlc.narg(id)::uuid))
+  AND m.deleted_at IS NULL
+  AND EXISTS (
+    SELECT 1 FROM conversation_participants cp
+    WHERE cp.conversation_id = mm.conversation_id AND cp.user_id = mm.user_id
+  )
+  AND NOT EXISTS (
+    SELECT 1 FROM hidden_messages h
+    WHERE h.message_id = m.id AND h.user_id = mm.user_id
+  )
+ORDER BY m.created_at DESC, m.id DESC
+LIMIT sqlc.arg('limit');
\ No newline at end of file
@@ -29,12 +29,15 @@ ORDER BY m.created_at DESC;
 
 -- name: ListUnreadMessages :many
 SELECT m.*, c.title as conversation_title
-FROM messages m
-JOIN conversations c ON m.conversation_id = c.id
-WHERE m.id NOT IN (
-  SELECT message_id FROM message_receipts 
-  WHERE user_id = $1 AND read_at IS NOT NULL
-) AND m.sender_id != $1
+FROM conversation_participants cp
+JOIN conversations c ON c.id = cp.conversation_id
+JOIN messages m
+  ON m.conversation_id = cp.conversation_id AND m.seq > cp.last_read_seq
+WHERE cp.user_id = $1 AND m.sender_id != $1
+  AND NOT EXISTS (
+    SELECT 1 FROM hidden_messages h
+    WHERE h.message_id = m.id AND h.user_id = $1
+  )
 ORDER BY m.created_at DESC;
 
 -- name: CreateMessageReceipt :one
@@ -46,25 +49,95 @@ INSERT INTO message_receipts (
 RETURNING *;
 
 -- name: MarkMessageAsDelivered :one
+-- Returns no row if the message was already delivered to the user, so the
+-- first delivery time is kept.
 INSERT INTO message_receipts (
   message_id, user_id, delivered_at
 ) VALUES (
   $1, $2, NOW()
 )
-ON CONFLICT (message_id, user_id) 
+ON CONFLICT (message_id, user_id)
 DO UPDATE SET delivered_at = NOW()
+WHERE message_receipts.delivered_at IS NULL
 RETURNING *;
 
 -- name: MarkMessageAsRead :one
+-- Returns no row if the user had already read the message. Reading a
+-- message also counts as receiving it.
 INSERT INTO message_receipts (
   message_id, user_id, delivered_at, read_at
 ) VALUES (
   $1, $2, NOW(), NOW()
 )
-ON CONFLICT (message_id, user_id) 
-DO UPDATE SET read_at = NOW()
+ON CONFLICT (message_id, user_id)
+DO UPDATE SET delivered_at = COALESCE(message_receipts.delivered_at, NOW()),
+              read_at = NOW()
+WHERE message_receipts.read_at IS NULL
 RETURNING *;
 
+-- name: MarkMessagesDeliveredUpTo :many
+-- Marks every message between the user's delivered watermark and seq that
+-- they did not send as delivered to them and returns the senders of the
+-- messages that changed. Run it before advancing the watermark.
+WITH marked AS (
+  INSERT INTO message_receipts (
+    message_id, user_id, delivered_at
+  )
+  SELECT m.id, sqlc.arg(user_id)::uuid, NOW()
+  FROM messages m
+  WHERE m.conversation_id = sqlc.arg(conversation_id)
+    AND m.seq <= sqlc.arg(seq)
+    AND m.seq > (
+      SELECT cp.last_delivered_seq FROM conversation_participants cp
+      WHERE cp.conversation_id = m.conversation_id AND cp.user_id = sqlc.arg(user_id)::uuid
+    )
+    AND m.sender_id IS DISTINCT FROM sqlc.arg(user_id)::uuid
+    AND NOT EXISTS (
+      SELECT 1 FROM message_receipts r
+      WHERE r.message_id = m.id AND r.user_id = sqlc.arg(user_id)::uuid AND r.delivered_at IS NOT NULL
+    )
+  ON CONFLICT (message_id, user_id)
+  DO UPDATE SET delivered_at = NOW()
+  WHERE message_receipts.delivered_at IS NULL
+  RETURNING message_id
+)
+SELECT DISTINCT m.sender_id
+FROM marked
+JOIN messages m ON m.id = marked.message_id
+WHERE m.sender_id IS NOT NULL;
+
+-- name: MarkMessagesReadUpTo :many
+-- Marks every message between the user's read watermark and seq that they
+-- did not send as read by them and returns the senders of the messages that
+-- changed. Run it before advancing the watermark.
+WITH marked AS (
+  INSERT INTO message_receipts (
+    message_id, user_id, delivered_at, read_at
+  )
+  SELECT m.id, sqlc.arg(user_id)::uuid, NOW(), NOW()
+  FROM messages m
+  WHERE m.conversation_id = sqlc.arg(conversation_id)
+    AND m.seq <= sqlc.arg(seq)
+    AND m.seq > (
+      SELECT cp.last_read_seq FROM conversation_participants cp
+      WHERE cp.conversation_id = m.conversation_id AND cp.user_id = sqlc.arg(user_id)::uuid
+    )
+    AND m.sender_id IS DISTINCT FROM sqlc.arg(user_id)::uuid
+    AND NOT EXISTS (
+      SELECT 1 FROM message_receipts r
+      WHERE r.message_id = m.id AND r.user_id = sqlc.arg(user_id)::uuid AND r.read_at IS NOT NULL
+    )
+  ON CONFLICT (message_id, user_id)
+  DO UPDATE SET delivered_at = COALESCE(message_receipts.delivered_at, NOW()),
+                read_at = NOW()
+  WHERE message_receipts.read_at IS NULL
+  RETURNING message_id
+)
+SELECT DISTINCT m.sender_id
+FROM marked
+JOIN messages m ON m.id = marked.message_id
+WHERE m.sender_id IS NOT NULL;
+
 -- name: UpdateMessageReceipt :one
 UPDATE message_receipts
 SET delivered_at = $3,
@@ -97,10 +170,30 @@ FROM message_receipts
 WHERE message_id = $1;
 
 -- name: GetUnreadMessageCount :one
-SELECT COUNT(*) FROM messages m
-WHERE m.conversation_id = $1 
-  AND m.sender_id != $2
-  AND m.id NOT IN (
-    SELECT message_id FROM message_receipts 
-    WHERE user_id = $2 AND read_at IS NOT NULL
-  );
\ No newline at end of file
+-- Messages above the watermark the user deleted for themselves are not
+-- unread.
+SELECT (c.last_message_seq - cp.last_read_seq - (
+         SELECT COUNT(*) FROM hidden_messages h
+         JOIN messages hm ON hm.id = h.message_id
+         WHERE h.user_id = cp.user_id
+           AND hm.conversation_id = cp.conversation_id
+           AND hm.seq > cp.last_read_seq
+       ))::bigint AS unread_count
+FROM conversation_participants cp
+JOIN conversations c ON c.id = cp.conversation_id
+WHERE cp.conversation_id = $1 AND cp.user_id = $2;
+
+-- name: GetMessageReceiptCounts :one
+-- Recipients are the current participants, other than the sender, who had
+-- joined by the time the message was sent.
+SELECT
+  COUNT(*) AS recipient_count,
+  COUNT(r.delivered_at) AS delivered_count,
+  COUNT(r.read_at) AS read_count
+FROM messages m
+JOIN conversation_participants cp
+  ON cp.conversation_id = m.conversation_id
+  AND cp.user_id IS DISTINCT FROM m.sender_id
+  AND cp.joined_at <= m.created_at
+LEFT JOIN message_receipts r ON r.message_id = m.id AND r.user_id = cp.user_id
+WHERE m.id = $1;
\ No newline at end of file
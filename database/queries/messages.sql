@@ -2,6 +2,11 @@
 SELECT * FROM messages
 WHERE id = $1 LIMIT 1;
 
+-- name: GetMessageByClientMessageID :one
+SELECT * FROM messages
+WHERE sender_device_id = $1 AND client_message_id = $2
+LIMIT 1;
+
 -- name: GetMessageWithDetails :one
 SELECT m.*, u.phone_number as sender_phone, u.display_name as sender_name
 FROM messages m
@@ -44,16 +49,37 @@ WHERE reply_to_id = $1
 ORDER BY created_at ASC;
 
 -- name: CreateMessage :one
+-- Bumping the conversation's counter locks its row, so concurrent inserts
+-- into the same conversation take consecutive sequence numbers.
+WITH next AS (
+  UPDATE conversations
+  SET last_message_seq = last_message_seq + 1
+  WHERE id = sqlc.arg(conversation_id)
+  RETURNING last_message_seq
+)
 INSERT INTO messages (
-  conversation_id, sender_id, content, message_type, reply_to_id
-) VALUES (
-  $1, $2, $3, $4, $5
+  conversation_id, sender_id, content, message_type, reply_to_id, seq,
+  sender_device_id, client_message_id, forward_count, thread_root_id, payload
 )
+SELECT
+  sqlc.arg(conversation_id)::uuid,
+  sqlc.narg(sender_id)::uuid,
+  sqlc.narg(content)::text,
+  sqlc.arg(message_type)::text,
+  sqlc.narg(reply_to_id)::uuid,
+  next.last_message_seq,
+  sqlc.narg(sender_device_id)::uuid,
+  sqlc.narg(client_message_id)::text,
+  sqlc.arg(forward_count)::int,
+  sqlc.narg(thread_root_id)::uuid,
+  sqlc.narg(payload)::jsonb
+FROM next
 RETURNING *;
 
 -- name: UpdateMessageContent :one
 UPDATE messages
-SET content = $2
+SET content = $2,
+    edited_at = now()
 WHERE id = $1
 RETURNING *;
 
@@ -83,17 +109,79 @@ LIMIT 50;
 
 -- name: GetLatestConversationMessage :one
 SELECT * FROM messages
-WHERE conversation_id = $1
+WHERE conversation_id = sqlc.arg(conversation_id)
+  AND NOT EXISTS (
+    SELECT 1 FROM hidden_messages h
+    WHERE h.message_id = messages.id AND h.user_id = sqlc.arg(user_id)
+  )
 ORDER BY created_at DESC
 LIMIT 1;
 
+-- name: ListLatestConversationMessages :many
+SELECT * FROM messages
+WHERE conversation_id = sqlc.arg(conversation_id)
+  AND NOT EXISTS (
+    SELECT 1 FROM hidden_messages h
+    WHERE h.message_id = messages.id AND h.user_id = sqlc.arg(user_id)
+  )
+ORDER BY created_at DESC, id DESC
+LIMIT sqlc.arg('limit');
+
 -- name: GetMessagesAfterTimestamp :many
 SELECT * FROM messages
-WHERE conversation_id = $1 AND created_at > $2
-ORDER BY created_at ASC;
+WHERE conversation_id = sqlc.arg(conversation_id)
+  AND (created_at, id) > (sqlc.arg(created_at)::timestamptz, sqlc.arg(id)::uuid)
+  AND NOT EXISTS (
+    SELECT 1 FROM hidden_messages h
+    WHERE h.message_id = messages.id AND h.user_id = sqlc.arg(user_id)
+  )
+ORDER BY created_at ASC, id ASC
+LIMIT sqlc.arg('limit');
 
 -- name: GetMessagesBeforeTimestamp :many
 SELECT * FROM messages
-WHERE conversation_id = $1 AND created_at < $2
-ORDER BY created_at DESC
-LIMIT $3;
\ No newline at end of file
+WHERE conversation_id = sqlc.arg(conversation_id)
+  AND (created_at, id) < (sqlc.arg(created_at)::timestamptz, sqlc.arg(id)::uuid)
+  AND NOT EXISTS (
+    SELECT 1 FROM hidden_messages h
+    WHERE h.message_id = messages.id AND h.user_id = sqlc.arg(user_id)
+  )
+ORDER BY created_at DESC, id DESC
+LIMIT sqlc.arg('limit');
+
+-- name: ListMessagesAfterSeq :many
+-- Messages the user deleted for themselves are included, so they do not
+-- look like gaps in the sequence; see ListHiddenMessageIDs.
+SELECT * FROM messages
+WHERE conversation_id = sqlc.arg(conversation_id)
+  AND seq > sqlc.arg(seq)
+ORDER BY seq ASC
+LIMIT sqlc.arg('limit');
+
+-- name: TombstoneMessage :one
+UPDATE messages
+SET content = NULL,
+    payload = NULL,
+    deleted_at = now()
+WHERE id = $1 AND deleted_at IS NULL
+RETURNING *;
+
+-- name: ListThreadReplies :many
+-- Replies are listed oldest first. A NULL cursor starts from the first reply.
+SELECT * FROM messages
+WHERE thread_root_id = sqlc.arg(thread_root_id)
+  AND (sqlc.narg(created_at)::timestamptz IS NULL
+    OR (created_at, id) > (sqlc.narg(created_at)::timestamptz, sqlc.narg(id)::uuid))
+  AND NOT EXISTS (
+    SELECT 1 FROM hidden_messages h
+    WHERE h.message_id = messages.id AND h.user_id = sqlc.arg(user_id)
+  )
+ORDER BY created_at ASC, id ASC
+LIMIT sqlc.arg('limit');
+
+-- name: IncrementThreadReplies :one
+UPDATE messages
+SET thread_reply_count = thread_reply_count + 1,
+    thread_last_reply_at = $2
+WHERE id = $1
+RETURNING *;
\ No newline at end of file
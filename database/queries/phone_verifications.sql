@@ -0,0 +1,33 @@
+-- name: GetLatestPhoneVerification :one
+SELECT * FROM phone_verifications
+WHERE phone_number = $1
+ORDER BY created_at DESC
+LIMIT 1;
+
+-- name: CreatePhoneVerification :one
+INSERT INTO phone_verifications (
+  phone_number, code_hash, expires_at
+) VALUES (
+  $1, $2, $3
+)
+RETURNING *;
+
+-- name: IncrementPhoneVerificationAttempts :one
+UPDATE phone_verifications
+SET attempts = attempts + 1
+WHERE id = $1
+RETURNING *;
+
+-- name: MarkPhoneVerificationVerified :one
+UPDATE phone_verifications
+SET verified_at = NOW()
+WHERE id = $1 AND verified_at IS NULL
+RETURNING *;
+
+-- name: CountRecentPhoneVerifications :one
+SELECT COUNT(*) FROM phone_verifications
+WHERE phone_number = $1 AND created_at > $2;
+
+-- name: DeleteExpiredPhoneVerifications :exec
+DELETE FROM phone_verifications
+WHERE expires_at < $1;
\ No newline at end of file
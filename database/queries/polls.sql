@@ -0,0 +1,42 @@
+-- name: CreatePoll :one
+INSERT INTO polls (
+  message_id, multiple_choice, option_count, closes_at
+) VALUES (
+  $1, $2, $3, $4
+)
+RETURNING *;
+
+-- name: GetPoll :one
+SELECT * FROM polls
+WHERE message_id = $1 LIMIT 1;
+
+-- name: ClosePoll :one
+UPDATE polls
+SET closed_at = now()
+WHERE message_id = $1 AND closed_at IS NULL
+RETURNING *;
+
+-- name: CreatePollVotes :exec
+INSERT INTO poll_votes (
+  message_id, user_id, option_index
+)
+SELECT sqlc.arg(message_id)::uuid, sqlc.arg(user_id)::uuid, unnest(sqlc.arg(option_indexes)::int[]);
+
+-- name: DeleteUserPollVotes :exec
+DELETE FROM poll_votes
+WHERE message_id = $1 AND user_id = $2;
+
+-- name: ListUserPollVotes :many
+SELECT option_index FROM poll_votes
+WHERE message_id = $1 AND user_id = $2
+ORDER BY option_index ASC;
+
+-- name: CountPollVotes :many
+SELECT option_index, COUNT(*) AS votes
+FROM poll_votes
+WHERE message_id = $1
+GROUP BY option_index;
+
+-- name: CountPollVoters :one
+SELECT COUNT(DISTINCT user_id) FROM poll_votes
+WHERE message_id = $1;
\ No newline at end of file
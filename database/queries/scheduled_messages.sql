@@ -0,0 +1,83 @@
+-- name: CreateScheduledMessage :one
+INSERT INTO scheduled_messages (
+  conversation_id, user_id, device_id, content, message_type, payload,
+  reply_to_id, thread_root_id, send_at
+) VALUES (
+  $1, $2, $3, $4, $5, $6, $7, $8, $9
+)
+RETURNING *;
+
+-- name: GetScheduledMessage :one
+SELECT * FROM scheduled_messages
+WHERE id = $1 LIMIT 1;
+
+-- name: ListPendingScheduledMessages :many
+SELECT * FROM scheduled_messages
+WHERE user_id = sqlc.arg(user_id)
+  AND status = 'pending'
+  AND (sqlc.narg(conversation_id)::uuid IS NULL OR conversation_id = sqlc.narg(conversation_id)::uuid)
+ORDER BY send_at ASC, id ASC;
+
+-- name: UpdateScheduledMessage :one
+-- A message that the dispatcher has claimed can no longer be changed.
+UPDATE scheduled_messages
+SET content = $2,
+    message_type = $3,
+    payload = $4,
+    send_at = $5,
+    updated_at = now()
+WHERE id = $1
+  AND status = 'pending'
+  AND (claimed_until IS NULL OR claimed_until < now())
+RETURNING *;
+
+-- name: CancelScheduledMessage :one
+UPDATE scheduled_messages
+SET status = 'cancelled',
+    updated_at = now()
+WHERE id = $1
+  AND status = 'pending'
+  AND (claimed_until IS NULL OR claimed_until < now())
+RETURNING *;
+
+-- name: ClaimDueScheduledMessages :many
+-- Claims are leases: if the instance that claimed a message stops before
+-- finishing, the message becomes due again once its lease expires. SKIP
+-- LOCKED lets several instances claim disjoint batches.
+UPDATE scheduled_messages
+SET claimed_until = now() + make_interval(secs => sqlc.arg(lease_seconds)::float8),
+    attempts = attempts + 1,
+    updated_at = now()
+WHERE id IN (
+  SELECT id FROM scheduled_messages
+  WHERE status = 'pending'
+    AND send_at <= now()
+    AND (claimed_until IS NULL OR claimed_until < now())
+  ORDER BY send_at ASC
+  LIMIT sqlc.arg('limit')
+  FOR UPDATE SKIP LOCKED
+)
+RETURNING *;
+
+-- name: MarkScheduledMessageSent :exec
+UPDATE scheduled_messages
+SET status = 'sent',
+    message_id = $2,
+    claimed_until = NULL,
+    last_error = NULL,
+    updated_at = now()
+WHERE id = $1;
+
+-- name: MarkScheduledMessageFailed :exec
+UPDATE scheduled_messages
+SET status = 'failed',
+    claimed_until = NULL,
+    last_error = $2,
+    updated_at = now()
+WHERE id = $1;
+
+-- name: RecordScheduledMessageError :exec
+UPDATE scheduled_messages
+SET last_error = $2,
+    updated_at = now()
+WHERE id = $1;
\ No newline at end of file
@@ -0,0 +1,47 @@
+-- name: GetSession :one
+SELECT * FROM sessions
+WHERE id = $1 LIMIT 1;
+
+-- name: GetActiveSession :one
+SELECT * FROM sessions
+WHERE id = $1 AND revoked_at IS NULL AND expires_at > NOW()
+LIMIT 1;
+
+-- name: GetSessionByRefreshTokenHash :one
+SELECT * FROM sessions
+WHERE refresh_token_hash = $1 LIMIT 1;
+
+-- name: CreateSession :one
+INSERT INTO sessions (
+  user_id, device_id, refresh_token_hash, expires_at
+) VALUES (
+  $1, $2, $3, $4
+)
+RETURNING *;
+
+-- name: RotateSessionRefreshToken :one
+-- Only rotates the token that was presented, so two requests racing with the
+-- same refresh token cannot both succeed.
+UPDATE sessions
+SET refresh_token_hash = sqlc.arg(new_refresh_token_hash),
+    expires_at = sqlc.arg(expires_at),
+    last_used_at = NOW()
+WHERE id = sqlc.arg(id)
+  AND refresh_token_hash = sqlc.arg(refresh_token_hash)
+  AND revoked_at IS NULL
+RETURNING *;
+
+-- name: RevokeSession :exec
+UPDATE sessions
+SET revoked_at = NOW()
+WHERE id = $1 AND revoked_at IS NULL;
+
+-- name: RevokeDeviceSessions :exec
+UPDATE sessions
+SET revoked_at = NOW()
+WHERE device_id = $1 AND revoked_at IS NULL;
+
+-- name: RevokeUserSessions :exec
+UPDATE sessions
+SET revoked_at = NOW()
+WHERE user_id = $1 AND revoked_at IS NULL;
\ No newline at end of file
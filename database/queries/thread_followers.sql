@@ -0,0 +1,25 @@
+-- name: FollowThread :exec
+INSERT INTO thread_followers (
+  message_id, user_id
+) VALUES (
+  $1, $2
+)
+ON CONFLICT DO NOTHING;
+
+-- name: UnfollowThread :exec
+DELETE FROM thread_followers
+WHERE message_id = $1 AND user_id = $2;
+
+-- name: IsFollowingThread :one
+SELECT EXISTS (
+  SELECT 1 FROM thread_followers
+  WHERE message_id = $1 AND user_id = $2
+);
+
+-- name: ListThreadFollowers :many
+-- Followers who have since left the conversation are skipped.
+SELECT tf.user_id FROM thread_followers tf
+JOIN messages m ON m.id = tf.message_id
+JOIN conversation_participants cp
+  ON cp.conversation_id = m.conversation_id AND cp.user_id = tf.user_id
+WHERE tf.message_id = $1;
\ No newline at end of file
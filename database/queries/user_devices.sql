@@ -42,4 +42,57 @@ WHERE user_id = $1;
 
 -- name: CountUserDevices :one
 SELECT COUNT(*) FROM user_devices
-WHERE user_id = $1;
\ No newline at end of file
+WHERE user_id = $1;
+
+-- name: AdvanceUserDeviceSyncCheckpoint :one
+UPDATE user_devices
+SET sync_checkpoint = GREATEST(sync_checkpoint, sqlc.arg(sync_checkpoint)::bigint)
+WHERE id = sqlc.arg(id)
+RETURNING *;
+
+-- name: MarkDeviceOnline :one
+-- Extends the device's presence lease and records the user as seen. Reports
+-- whether any of the user's devices was online beforehand.
+WITH seen AS (
+  UPDATE users SET last_seen_at = NOW()
+  WHERE id = sqlc.arg(user_id)
+)
+UPDATE user_devices
+SET presence_expires_at = NOW() + make_interval(secs => sqlc.arg(ttl_seconds)::float8)
+WHERE id = sqlc.arg(device_id) AND user_id = sqlc.arg(user_id)
+RETURNING EXISTS (
+  SELECT 1 FROM user_devices d
+  WHERE d.user_id = sqlc.arg(user_id) AND d.presence_expires_at > NOW()
+) AS was_online;
+
+-- name: MarkDeviceOffline :one
+-- Ends the device's presence lease and records the user as last seen now.
+-- Reports whether another of the user's devices is still online.
+WITH device AS (
+  UPDATE user_devices SET presence_expires_at = NULL
+  WHERE id = sqlc.arg(device_id) AND user_id = sqlc.arg(user_id)
+)
+UPDATE users u
+SET last_seen_at = NOW()
+WHERE u.id = sqlc.arg(user_id)
+RETURNING u.last_seen_at, EXISTS (
+  SELECT 1 FROM user_devices d
+  WHERE d.user_id = u.id AND d.id != sqlc.arg(device_id) AND d.presence_expires_at > NOW()
+) AS online;
+
+-- name: ExpireDevicePresence :many
+-- Ends the presence leases that were not renewed in time, such as those of
+-- devices whose server went away, and returns the users this left with no
+-- device online.
+WITH expired AS (
+  UPDATE user_devices SET presence_expires_at = NULL
+  WHERE presence_expires_at <= NOW()
+  RETURNING user_id
+)
+SELECT DISTINCT u.id, u.last_seen_at
+FROM expired e
+JOIN users u ON u.id = e.user_id
+WHERE NOT EXISTS (
+  SELECT 1 FROM user_devices d
+  WHERE d.user_id = e.user_id AND d.presence_expires_at > NOW()
+);
\ No newline at end of file
@@ -52,4 +52,36 @@ LIMIT 20;
 SELECT * FROM users
 WHERE display_name ILIKE '%' || $1 || '%'
 ORDER BY display_name
-LIMIT 20;
\ No newline at end of file
+LIMIT 20;
+
+-- name: ListVisiblePresence :many
+-- Returns the presence of those of the given users whose visibility lets the
+-- viewer see it: 'everyone', or 'contacts' when they have the viewer as a
+-- contact. With contacts_only, users the viewer does not have as contacts
+-- are left out too. Users can always see their own presence.
+SELECT u.id, u.last_seen_at, EXISTS (
+  SELECT 1 FROM user_devices d
+  WHERE d.user_id = u.id AND d.presence_expires_at > NOW()
+) AS online
+FROM users u
+WHERE u.id = ANY(sqlc.arg(user_ids)::uuid[])
+  AND (NOT sqlc.arg(contacts_only)::bool OR EXISTS (
+    SELECT 1 FROM contacts c
+    WHERE c.user_id = sqlc.arg(viewer_id)::uuid AND c.contact_id = u.id
+  ))
+  AND (u.id = sqlc.arg(viewer_id)::uuid
+    OR u.presence_visibility = 'everyone'
+    OR (u.presence_visibility = 'contacts' AND EXISTS (
+      SELECT 1 FROM contacts c
+      WHERE c.user_id = u.id AND c.contact_id = sqlc.arg(viewer_id)::uuid
+    )));
+
+-- name: GetPresenceVisibility :one
+SELECT presence_visibility FROM users
+WHERE id = $1;
+
+-- name: UpdatePresenceVisibility :one
+UPDATE users
+SET presence_visibility = $2
+WHERE id = $1
+RETURNING presence_visibility;
\ No newline at end of file
@@ -0,0 +1,81 @@
+package event
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	"github.com/jackc/pgx/v5/pgtype"
+)
+
+type Type string
+
+const (
+	MessageCreated     Type = "message.created"
+	MessageEdited      Type = "message.edited"
+	MessageDeleted     Type = "message.deleted"
+	MessageHidden      Type = "message.hidden"
+	ThreadUpdated      Type = "thread.updated"
+	ThreadReply        Type = "thread.reply"
+	Mentioned          Type = "message.mentioned"
+	PollUpdated        Type = "poll.updated"
+	ReactionAdded      Type = "reaction.added"
+	ReactionRemoved    Type = "reaction.removed"
+	ReceiptUpdated     Type = "receipt.updated"
+	ReceiptsUpdated    Type = "receipts.updated"
+	TypingUpdated      Type = "typing.updated"
+	ParticipantAdded   Type = "participant.added"
+	ParticipantRemoved Type = "participant.removed"
+
+	ConversationDeleted Type = "conversation.deleted"
+
+	InboxSettingsUpdated Type = "inbox.settings_updated"
+
+	// Presence events belong to no conversation and are routed by their
+	// user_id instead.
+	PresenceUpdated Type = "presence.updated"
+	PresenceRevoked Type = "presence.revoked"
+
+	// SessionRevoked belongs to no conversation either. It closes the
+	// sockets of the revoked session, or of every session of the device
+	// when session_id is empty, and is never delivered to clients.
+	SessionRevoked Type = "session.revoked"
+)
+
+// Event is a change inside a conversation that every connected participant
+// should learn about.
+type Event struct {
+	Type           Type            `json:"type"`
+	ConversationID pgtype.UUID     `json:"conversation_id"`
+	Data           json.RawMessage `json:"data"`
+	// Recipients narrows delivery to these participants. When empty every
+	// participant receives the event.
+	Recipients []pgtype.UUID `json:"recipients,omitempty"`
+}
+
+func New(eventType Type, conversationID pgtype.UUID, data any) (Event, error) {
+	payload, err := json.Marshal(data)
+	if err != nil {
+		return Event{}, fmt.Errorf("failed to encode %s event: %w", eventType, err)
+	}
+
+	return Event{
+		Type:           eventType,
+		ConversationID: conversationID,
+		Data:           payload,
+	}, nil
+}
+
+type Publisher interface {
+	Publish(ctx context.Context, e Event) error
+}
+
+// Handler receives every event published on a Bus.
+type Handler func(ctx context.Context, e Event)
+
+// Bus delivers published events to every subscriber, regardless of which
+// server instance published them.
+type Bus interface {
+	Publisher
+	Subscribe(handler Handler) (unsubscribe func())
+}
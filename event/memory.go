@@ -0,0 +1,51 @@
+package event
+
+import (
+	"context"
+	"sync"
+)
+
+// MemoryBus delivers events to subscribers in the same process. It is meant
+// for single-instance deployments and tests.
+type MemoryBus struct {
+	mu       sync.RWMutex
+	nextID   int
+	handlers map[int]Handler
+}
+
+func NewMemoryBus() *MemoryBus {
+	return &MemoryBus{handlers: make(map[int]Handler)}
+}
+
+func (b *MemoryBus) Publish(ctx context.Context, e Event) error {
+	b.dispatch(ctx, e)
+	return nil
+}
+
+func (b *MemoryBus) Subscribe(handler Handler) func() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	id := b.nextID
+	b.nextID++
+	b.handlers[id] = handler
+
+	return func() {
+		b.mu.Lock()
+		defer b.mu.Unlock()
+		delete(b.handlers, id)
+	}
+}
+
+func (b *MemoryBus) dispatch(ctx context.Context, e Event) {
+	b.mu.RLock()
+	handlers := make([]Handler, 0, len(b.handlers))
+	for _, handler := range b.handlers {
+		handlers = append(handlers, handler)
+	}
+	b.mu.RUnlock()
+
+	for _, handler := range handlers {
+		handler(ctx, e)
+	}
+}
@@ -0,0 +1,176 @@
+package event
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+const (
+	// Channel is the Postgres NOTIFY channel shared by every instance.
+	Channel = "chat_events"
+
+	// maxPayloadSize bounds NOTIFY payloads, which Postgres requires to be
+	// shorter than 8000 bytes.
+	maxPayloadSize = 7999
+
+	// storedRetention is how long an event too large for a NOTIFY is kept
+	// for the other instances to load.
+	storedRetention = 5 * time.Minute
+
+	reconnectDelay = 2 * time.Second
+)
+
+// envelope is the NOTIFY payload. Origin lets an instance skip the copy of
+// its own events, which it has already delivered locally. Events too large
+// to send inline are stored in bus_events and sent as a Ref to their row.
+type envelope struct {
+	Origin string `json:"origin"`
+	Event  Event  `json:"event"`
+	Ref    int64  `json:"ref,omitempty"`
+}
+
+// PostgresBus fans events out across server instances with LISTEN/NOTIFY.
+// Events published by this instance are delivered to local subscribers
+// immediately; events from other instances arrive through Run.
+type PostgresBus struct {
+	pool   *pgxpool.Pool
+	local  *MemoryBus
+	origin string
+	logger *slog.Logger
+}
+
+func NewPostgresBus(pool *pgxpool.Pool, logger *slog.Logger) (*PostgresBus, error) {
+	origin := make([]byte, 8)
+	if _, err := rand.Read(origin); err != nil {
+		return nil, fmt.Errorf("failed to generate bus origin: %w", err)
+	}
+
+	return &PostgresBus{
+		pool:   pool,
+		local:  NewMemoryBus(),
+		origin: hex.EncodeToString(origin),
+		logger: logger,
+	}, nil
+}
+
+func (b *PostgresBus) Publish(ctx context.Context, e Event) error {
+	b.local.dispatch(ctx, e)
+
+	payload, err := json.Marshal(envelope{Origin: b.origin, Event: e})
+	if err != nil {
+		return fmt.Errorf("failed to encode event: %w", err)
+	}
+	if len(payload) > maxPayloadSize {
+		return b.notifyStored(ctx, e.Type, payload)
+	}
+
+	if _, err := b.pool.Exec(ctx, "SELECT pg_notify($1, $2)", Channel, string(payload)); err != nil {
+		return fmt.Errorf("failed to notify %s event: %w", e.Type, err)
+	}
+
+	return nil
+}
+
+// notifyStored stores an envelope too large for a NOTIFY and notifies a
+// reference to it instead, clearing out stored envelopes old enough that
+// every listener has loaded them.
+func (b *PostgresBus) notifyStored(ctx context.Context, eventType Type, payload []byte) error {
+	const query = `
+WITH expired AS (
+  DELETE FROM bus_events
+  WHERE created_at < NOW() - make_interval(secs => $3)
+), stored AS (
+  INSERT INTO bus_events (envelope) VALUES ($2)
+  RETURNING id
+)
+SELECT pg_notify($1, json_build_object('origin', $4::text, 'ref', id)::text)
+FROM stored`
+
+	if _, err := b.pool.Exec(ctx, query, Channel, payload, storedRetention.Seconds(), b.origin); err != nil {
+		return fmt.Errorf("failed to notify stored %s event: %w", eventType, err)
+	}
+
+	return nil
+}
+
+func (b *PostgresBus) Subscribe(handler Handler) func() {
+	return b.local.Subscribe(handler)
+}
+
+// Run listens for events published by other instances until ctx is done,
+// reconnecting whenever the listening connection is lost.
+func (b *PostgresBus) Run(ctx context.Context) error {
+	for {
+		err := b.listen(ctx)
+		if ctx.Err() != nil {
+			return nil
+		}
+
+		b.logger.Warn("event listener disconnected", "error", err)
+
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-time.After(reconnectDelay):
+		}
+	}
+}
+
+func (b *PostgresBus) listen(ctx context.Context) error {
+	pooled, err := b.pool.Acquire(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to acquire connection: %w", err)
+	}
+
+	// The connection keeps its LISTEN registration, so it must never be
+	// handed back to the pool.
+	conn := pooled.Hijack()
+	defer conn.Close(context.Background())
+
+	if _, err := conn.Exec(ctx, "LISTEN "+pgx.Identifier{Channel}.Sanitize()); err != nil {
+		return fmt.Errorf("failed to listen: %w", err)
+	}
+
+	for {
+		notification, err := conn.WaitForNotification(ctx)
+		if err != nil {
+			return err
+		}
+
+		var msg envelope
+		if err := json.Unmarshal([]byte(notification.Payload), &msg); err != nil {
+			b.logger.Warn("dropping malformed event", "error", err)
+			continue
+		}
+
+		if msg.Origin == b.origin {
+			continue
+		}
+
+		if msg.Ref != 0 {
+			var stored []byte
+			if err := conn.QueryRow(ctx, "SELECT envelope FROM bus_events WHERE id = $1", msg.Ref).Scan(&stored); err != nil {
+				if errors.Is(err, pgx.ErrNoRows) {
+					b.logger.Warn("dropping expired event", "ref", msg.Ref)
+					continue
+				}
+				return fmt.Errorf("failed to load stored event: %w", err)
+			}
+			if err := json.Unmarshal(stored, &msg); err != nil {
+				b.logger.Warn("dropping malformed event", "ref", msg.Ref, "error", err)
+				continue
+			}
+		}
+
+		b.local.dispatch(ctx, msg.Event)
+	}
+}
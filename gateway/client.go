@@ -0,0 +1,166 @@
+package gateway
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/coder/websocket"
+	"github.com/jackc/pgx/v5/pgtype"
+)
+
+const (
+	sendBufferSize = 64
+	writeTimeout   = 10 * time.Second
+	pingInterval   = 30 * time.Second
+
+	// deliveryFlushInterval is how often the deliveries written to a socket
+	// are recorded.
+	deliveryFlushInterval = time.Second
+)
+
+type client struct {
+	userID    pgtype.UUID
+	deviceID  pgtype.UUID
+	sessionID pgtype.UUID
+	conn      *websocket.Conn
+	send      chan outbound
+
+	// delivered is called with the newest message of each conversation
+	// whose frame has been written to the socket since the last call.
+	delivered func(ctx context.Context, conversationID, messageID pgtype.UUID)
+
+	// pending holds, per conversation, the newest message from another user
+	// written to the socket whose delivery has not been recorded yet.
+	pendingMu sync.Mutex
+	pending   map[pgtype.UUID]messageData
+
+	// heartbeat is called each time the peer answers a ping.
+	heartbeat func(ctx context.Context)
+
+	// conversations, watching and removed are guarded by the hub's mutex.
+	// removed is only set while the hub is resolving the client.
+	conversations map[pgtype.UUID]struct{}
+	watching      map[pgtype.UUID]struct{}
+	removed       map[pgtype.UUID]struct{}
+
+	// slow is closed when the client falls too far behind and must be
+	// disconnected.
+	slow     chan struct{}
+	slowOnce sync.Once
+
+	// revoked is closed when the client's session is revoked and it must be
+	// disconnected.
+	revoked     chan struct{}
+	revokedOnce sync.Once
+}
+
+func newClient(conn *websocket.Conn, userID, deviceID, sessionID pgtype.UUID, delivered func(context.Context, pgtype.UUID, pgtype.UUID), heartbeat func(context.Context)) *client {
+	return &client{
+		userID:        userID,
+		deviceID:      deviceID,
+		sessionID:     sessionID,
+		conn:          conn,
+		send:          make(chan outbound, sendBufferSize),
+		delivered:     delivered,
+		pending:       make(map[pgtype.UUID]messageData),
+		heartbeat:     heartbeat,
+		conversations: make(map[pgtype.UUID]struct{}),
+		watching:      make(map[pgtype.UUID]struct{}),
+		slow:          make(chan struct{}),
+		revoked:       make(chan struct{}),
+	}
+}
+
+// revoke disconnects the client without blocking the hub.
+func (c *client) revoke() {
+	c.revokedOnce.Do(func() { close(c.revoked) })
+}
+
+// enqueue queues a frame without blocking the hub.
+func (c *client) enqueue(out outbound) {
+	select {
+	case c.send <- out:
+	default:
+		c.slowOnce.Do(func() { close(c.slow) })
+	}
+}
+
+func (c *client) writeLoop(ctx context.Context) error {
+	ticker := time.NewTicker(pingInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-c.slow:
+			return c.conn.Close(websocket.StatusPolicyViolation, "connection too slow to keep up with events")
+		case <-c.revoked:
+			return c.conn.Close(websocket.StatusPolicyViolation, "session revoked")
+		case out := <-c.send:
+			if err := c.write(ctx, out.frame); err != nil {
+				return err
+			}
+			if out.message.ID.Valid && out.message.SenderID != c.userID {
+				c.noteDelivered(out.message)
+			}
+		case <-ticker.C:
+			pingCtx, cancel := context.WithTimeout(ctx, writeTimeout)
+			err := c.conn.Ping(pingCtx)
+			cancel()
+			if err != nil {
+				return err
+			}
+			c.heartbeat(ctx)
+		}
+	}
+}
+
+// noteDelivered remembers that a message reached the socket, for
+// deliveryLoop to record.
+func (c *client) noteDelivered(message messageData) {
+	c.pendingMu.Lock()
+	defer c.pendingMu.Unlock()
+
+	if newest, ok := c.pending[message.ConversationID]; !ok || message.Seq > newest.Seq {
+		c.pending[message.ConversationID] = message
+	}
+}
+
+// deliveryLoop records the deliveries noted by writeLoop in batches of one
+// per conversation, so writing frames never waits on the database. Once ctx
+// is done it records what is left and returns.
+func (c *client) deliveryLoop(ctx context.Context) {
+	ticker := time.NewTicker(deliveryFlushInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			// The frames were written even though the socket is gone now
+			c.flushDeliveries(context.WithoutCancel(ctx))
+			return
+		case <-ticker.C:
+			c.flushDeliveries(ctx)
+		}
+	}
+}
+
+func (c *client) flushDeliveries(ctx context.Context) {
+	c.pendingMu.Lock()
+	pending := c.pending
+	c.pending = make(map[pgtype.UUID]messageData)
+	c.pendingMu.Unlock()
+
+	for conversationID, message := range pending {
+		c.delivered(ctx, conversationID, message.ID)
+	}
+}
+
+func (c *client) write(ctx context.Context, frame []byte) error {
+	ctx, cancel := context.WithTimeout(ctx, writeTimeout)
+	defer cancel()
+
+	return c.conn.Write(ctx, websocket.MessageText, frame)
+}
@@ -0,0 +1,107 @@
+package gateway
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+
+	"github.com/coder/websocket"
+	"github.com/felipedavid/chatting/event"
+	"github.com/jackc/pgx/v5/pgtype"
+)
+
+// Frame types devices send over their socket.
+const (
+	// framePresenceSubscribe replaces the users whose presence the socket
+	// follows. An empty list stops following everyone.
+	framePresenceSubscribe = "presence.subscribe"
+
+	// frameTypingStart and frameTypingStop show and clear the device's
+	// typing or recording indicator in a conversation.
+	frameTypingStart = "typing.start"
+	frameTypingStop  = "typing.stop"
+)
+
+// clientFrame is a request sent by a device over its socket.
+type clientFrame struct {
+	Type           string        `json:"type"`
+	UserIDs        []pgtype.UUID `json:"user_ids"`
+	ConversationID pgtype.UUID   `json:"conversation_id"`
+	Activity       string        `json:"activity"`
+}
+
+// readLoop handles the frames the device sends until the socket fails or
+// ctx is cancelled.
+func (g *Gateway) readLoop(ctx context.Context, c *client) {
+	for {
+		typ, data, err := c.conn.Read(ctx)
+		if err != nil {
+			if !errors.Is(err, context.Canceled) && websocket.CloseStatus(err) == -1 {
+				g.logger.Debug("failed to read from device", "device_id", c.deviceID, "error", err)
+			}
+			return
+		}
+		if typ != websocket.MessageText {
+			continue
+		}
+
+		var frame clientFrame
+		if err := json.Unmarshal(data, &frame); err != nil {
+			g.logger.Debug("ignoring malformed frame", "device_id", c.deviceID, "error", err)
+			continue
+		}
+
+		g.handleFrame(ctx, c, frame)
+	}
+}
+
+func (g *Gateway) handleFrame(ctx context.Context, c *client, frame clientFrame) {
+	switch frame.Type {
+	case framePresenceSubscribe:
+		g.subscribePresence(ctx, c, frame.UserIDs)
+	case frameTypingStart:
+		if err := g.services.TypingService.StartTyping(ctx, frame.ConversationID, frame.Activity); err != nil {
+			g.logger.Debug("dropping typing signal", "device_id", c.deviceID, "error", err)
+		}
+	case frameTypingStop:
+		if err := g.services.TypingService.StopTyping(ctx, frame.ConversationID); err != nil {
+			g.logger.Debug("dropping typing signal", "device_id", c.deviceID, "error", err)
+		}
+	default:
+		g.logger.Debug("ignoring unknown frame", "device_id", c.deviceID, "type", frame.Type)
+	}
+}
+
+// subscribePresence makes the client follow the presence of those of the
+// users it may see and sends it their current presence.
+func (g *Gateway) subscribePresence(ctx context.Context, c *client, userIDs []pgtype.UUID) {
+	presence, err := g.services.PresenceService.SubscribablePresence(ctx, userIDs)
+	if err != nil {
+		g.logger.Warn("failed to subscribe to presence", "device_id", c.deviceID, "error", err)
+		return
+	}
+
+	visible := make([]pgtype.UUID, 0, len(presence))
+	for _, p := range presence {
+		visible = append(visible, p.UserID)
+	}
+
+	// Watch before sending the snapshot so no change falls in between
+	g.hub.watch(c, visible)
+
+	for _, p := range presence {
+		e, err := event.New(event.PresenceUpdated, pgtype.UUID{}, p)
+		if err != nil {
+			g.logger.Error("failed to encode event", "type", event.PresenceUpdated, "error", err)
+			return
+		}
+
+		frame, err := json.Marshal(e)
+		if err != nil {
+			g.logger.Error("failed to encode event", "type", event.PresenceUpdated, "error", err)
+			return
+		}
+
+		c.enqueue(outbound{frame: frame})
+	}
+}
@@ -0,0 +1,134 @@
+package gateway
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"net/http"
+
+	"github.com/coder/websocket"
+	"github.com/felipedavid/chatting/service"
+	"github.com/jackc/pgx/v5/pgtype"
+)
+
+// Gateway upgrades device connections to WebSockets and streams them the
+// events of every conversation their user participates in. A connected
+// device keeps its user online, can follow the presence of contacts and
+// sends typing indicators.
+type Gateway struct {
+	hub      *Hub
+	services *service.Container
+	logger   *slog.Logger
+}
+
+func New(hub *Hub, services *service.Container, logger *slog.Logger) *Gateway {
+	return &Gateway{hub: hub, services: services, logger: logger}
+}
+
+func (g *Gateway) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	actor, ok := service.ActorFromContext(r.Context())
+	if !ok {
+		http.Error(w, "authentication required", http.StatusUnauthorized)
+		return
+	}
+
+	conn, err := websocket.Accept(w, r, nil)
+	if err != nil {
+		g.logger.Warn("failed to accept websocket", "device_id", actor.DeviceID, "error", err)
+		return
+	}
+
+	c := newClient(conn, actor.UserID, actor.DeviceID, actor.SessionID, g.recordDelivery, g.recordHeartbeat)
+
+	// Registering before listing means a conversation joined in between is
+	// subscribed by its event rather than missed
+	g.hub.register(c)
+	defer g.unregister(r.Context(), c)
+
+	conversations, err := g.services.ConversationService.ListUserConversations(r.Context())
+	if err != nil {
+		g.logger.Error("failed to resolve device conversations", "device_id", c.deviceID, "error", err)
+		conn.Close(websocket.StatusInternalError, "failed to resolve conversations")
+		return
+	}
+
+	conversationIDs := make([]pgtype.UUID, 0, len(conversations))
+	for _, conversation := range conversations {
+		conversationIDs = append(conversationIDs, conversation.ConversationID)
+	}
+	g.hub.subscribe(c, conversationIDs)
+
+	// The session may have been revoked after the handshake authenticated
+	// it but before the hub could route the revocation to this socket
+	if err := g.services.SessionService.CheckSession(r.Context()); err != nil {
+		g.logger.Info("closing revoked session", "device_id", c.deviceID, "error", err)
+		conn.Close(websocket.StatusPolicyViolation, "session revoked")
+		return
+	}
+
+	if err := g.services.PresenceService.Connect(r.Context()); err != nil {
+		g.logger.Warn("failed to record presence", "device_id", c.deviceID, "error", err)
+	}
+
+	g.logger.Info("device connected", "user_id", c.userID, "device_id", c.deviceID)
+
+	// The read loop handles control frames and the device's requests, and
+	// cancels the context once the peer goes away.
+	ctx, cancel := context.WithCancel(r.Context())
+	defer cancel()
+	go func() {
+		defer cancel()
+		g.readLoop(ctx, c)
+	}()
+
+	deliveries := make(chan struct{})
+	go func() {
+		defer close(deliveries)
+		c.deliveryLoop(ctx)
+	}()
+
+	err = c.writeLoop(ctx)
+	if err != nil && !errors.Is(err, context.Canceled) && websocket.CloseStatus(err) == -1 {
+		g.logger.Warn("device connection closed", "device_id", c.deviceID, "error", err)
+	}
+
+	// Record the deliveries of the last frames written
+	cancel()
+	<-deliveries
+
+	conn.CloseNow()
+	g.logger.Info("device disconnected", "user_id", c.userID, "device_id", c.deviceID)
+}
+
+// recordDelivery marks every message of a conversation up to messageID
+// delivered to the user of the device whose context ctx is, now that they
+// reached their socket.
+func (g *Gateway) recordDelivery(ctx context.Context, conversationID, messageID pgtype.UUID) {
+	if _, err := g.services.ReceiptService.MarkDeliveredUpTo(ctx, conversationID, messageID); err != nil && ctx.Err() == nil {
+		g.logger.Warn("failed to record delivery", "message_id", messageID, "error", err)
+	}
+}
+
+// recordHeartbeat renews the presence of the device whose context ctx is.
+func (g *Gateway) recordHeartbeat(ctx context.Context) {
+	if err := g.services.PresenceService.Heartbeat(ctx); err != nil && ctx.Err() == nil {
+		g.logger.Warn("failed to record heartbeat", "error", err)
+	}
+}
+
+// unregister removes a client from the hub and, unless its device is still
+// connected through another socket, marks the device offline and clears its
+// typing indicators.
+func (g *Gateway) unregister(ctx context.Context, c *client) {
+	if g.hub.unregister(c) {
+		return
+	}
+
+	// The request is over by now, but the device still has to go offline
+	ctx = context.WithoutCancel(ctx)
+
+	g.services.TypingService.StopDevice(ctx)
+	if err := g.services.PresenceService.Disconnect(ctx); err != nil {
+		g.logger.Warn("failed to record presence", "device_id", c.deviceID, "error", err)
+	}
+}
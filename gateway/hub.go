@@ -0,0 +1,336 @@
+package gateway
+
+import (
+	"context"
+	"encoding/json"
+	"log/slog"
+	"sync"
+
+	"github.com/felipedavid/chatting/event"
+	"github.com/jackc/pgx/v5/pgtype"
+)
+
+// Hub keeps track of the sockets connected to this instance and fans events
+// out to the ones subscribed to the event's conversation. Presence events go
+// to the sockets watching the event's user instead, and session revocations
+// close the revoked session's sockets.
+type Hub struct {
+	mu            sync.RWMutex
+	users         map[pgtype.UUID]map[*client]struct{}
+	conversations map[pgtype.UUID]map[*client]struct{}
+	watchers      map[pgtype.UUID]map[*client]struct{}
+
+	// resolving holds the registered clients whose conversations are still
+	// being listed.
+	resolving map[*client]struct{}
+
+	logger *slog.Logger
+}
+
+func NewHub(logger *slog.Logger) *Hub {
+	return &Hub{
+		users:         make(map[pgtype.UUID]map[*client]struct{}),
+		conversations: make(map[pgtype.UUID]map[*client]struct{}),
+		watchers:      make(map[pgtype.UUID]map[*client]struct{}),
+		resolving:     make(map[*client]struct{}),
+		logger:        logger,
+	}
+}
+
+type participantData struct {
+	UserID pgtype.UUID `json:"user_id"`
+}
+
+type presenceData struct {
+	UserID pgtype.UUID `json:"user_id"`
+}
+
+type sessionData struct {
+	UserID    pgtype.UUID `json:"user_id"`
+	DeviceID  pgtype.UUID `json:"device_id"`
+	SessionID pgtype.UUID `json:"session_id"`
+}
+
+type messageData struct {
+	ID             pgtype.UUID `json:"id"`
+	ConversationID pgtype.UUID `json:"conversation_id"`
+	SenderID       pgtype.UUID `json:"sender_id"`
+	Seq            int64       `json:"seq"`
+}
+
+// outbound is a frame queued for a client. For new messages it also carries
+// the message, so delivery can be recorded once the frame is written.
+type outbound struct {
+	frame   []byte
+	message messageData
+}
+
+// Handle is subscribed to the event bus and delivers each event to the local
+// sockets that belong to the event's conversation.
+func (h *Hub) Handle(ctx context.Context, e event.Event) {
+	// Recipients are for routing only; they are not part of what clients see
+	recipients := e.Recipients
+	e.Recipients = nil
+
+	frame, err := json.Marshal(e)
+	if err != nil {
+		h.logger.Error("failed to encode event", "type", e.Type, "error", err)
+		return
+	}
+
+	out := outbound{frame: frame}
+
+	if e.Type == event.SessionRevoked {
+		var session sessionData
+		if err := json.Unmarshal(e.Data, &session); err != nil {
+			h.logger.Error("failed to decode session event", "error", err)
+			return
+		}
+		h.revokeSession(session)
+		return
+	}
+
+	if e.Type == event.PresenceUpdated || e.Type == event.PresenceRevoked {
+		var presence presenceData
+		if err := json.Unmarshal(e.Data, &presence); err != nil {
+			h.logger.Error("failed to decode presence event", "error", err)
+			return
+		}
+		h.deliverPresence(presence.UserID, out, e.Type == event.PresenceRevoked)
+		return
+	}
+
+	if e.Type == event.MessageCreated {
+		if err := json.Unmarshal(e.Data, &out.message); err != nil {
+			h.logger.Error("failed to decode message event", "error", err)
+			return
+		}
+	}
+
+	var participant participantData
+	if e.Type == event.ParticipantAdded || e.Type == event.ParticipantRemoved {
+		if err := json.Unmarshal(e.Data, &participant); err != nil {
+			h.logger.Error("failed to decode participant event", "error", err)
+			return
+		}
+	}
+
+	// A new participant's sockets must be subscribed before delivery so they
+	// receive the event announcing their own membership.
+	if e.Type == event.ParticipantAdded {
+		h.subscribeUser(participant.UserID, e.ConversationID)
+	}
+
+	if len(recipients) > 0 {
+		h.deliverTo(recipients, e.ConversationID, out)
+	} else {
+		h.deliver(e.ConversationID, out)
+	}
+
+	switch e.Type {
+	case event.ParticipantRemoved:
+		h.unsubscribeUser(participant.UserID, e.ConversationID)
+	case event.ConversationDeleted:
+		h.unsubscribeAll(e.ConversationID)
+	}
+}
+
+func (h *Hub) deliver(conversationID pgtype.UUID, out outbound) {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+
+	for c := range h.conversations[conversationID] {
+		c.enqueue(out)
+	}
+}
+
+// deliverTo sends the frame to the sockets of each recipient that are
+// subscribed to the conversation.
+func (h *Hub) deliverTo(userIDs []pgtype.UUID, conversationID pgtype.UUID, out outbound) {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+
+	for _, userID := range userIDs {
+		for c := range h.users[userID] {
+			if _, ok := c.conversations[conversationID]; ok {
+				c.enqueue(out)
+			}
+		}
+	}
+}
+
+// deliverPresence sends the frame to the sockets watching the user. Revoking
+// also stops them watching, until they subscribe again.
+func (h *Hub) deliverPresence(userID pgtype.UUID, out outbound, revoke bool) {
+	if !revoke {
+		h.mu.RLock()
+		defer h.mu.RUnlock()
+
+		for c := range h.watchers[userID] {
+			c.enqueue(out)
+		}
+		return
+	}
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	for c := range h.watchers[userID] {
+		c.enqueue(out)
+		delete(c.watching, userID)
+	}
+	delete(h.watchers, userID)
+}
+
+// revokeSession disconnects the sockets opened with a revoked session, or
+// with any session of the device if no session is given.
+func (h *Hub) revokeSession(session sessionData) {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+
+	for c := range h.users[session.UserID] {
+		if c.deviceID != session.DeviceID {
+			continue
+		}
+		if session.SessionID.Valid && c.sessionID != session.SessionID {
+			continue
+		}
+		c.revoke()
+	}
+}
+
+// register adds the client to the hub before its conversations are listed,
+// so it is subscribed to any it joins in the meantime. Until subscribe is
+// called, the conversations it leaves or that are deleted are remembered so
+// a stale list cannot subscribe it to them again.
+func (h *Hub) register(c *client) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if h.users[c.userID] == nil {
+		h.users[c.userID] = make(map[*client]struct{})
+	}
+	h.users[c.userID][c] = struct{}{}
+
+	h.resolving[c] = struct{}{}
+	c.removed = make(map[pgtype.UUID]struct{})
+}
+
+// subscribe subscribes a registered client to the conversations its user
+// participated in when it connected.
+func (h *Hub) subscribe(c *client, conversationIDs []pgtype.UUID) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	for _, conversationID := range conversationIDs {
+		if _, ok := c.removed[conversationID]; ok {
+			continue
+		}
+		h.subscribeLocked(c, conversationID)
+	}
+
+	delete(h.resolving, c)
+	c.removed = nil
+}
+
+// unregister removes the client from the hub and reports whether its device
+// is still connected through another socket on this instance.
+func (h *Hub) unregister(c *client) bool {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	for conversationID := range c.conversations {
+		h.unsubscribeLocked(c, conversationID)
+	}
+	h.unwatchAllLocked(c)
+	delete(h.resolving, c)
+
+	delete(h.users[c.userID], c)
+	if len(h.users[c.userID]) == 0 {
+		delete(h.users, c.userID)
+	}
+
+	for other := range h.users[c.userID] {
+		if other.deviceID == c.deviceID {
+			return true
+		}
+	}
+	return false
+}
+
+// watch replaces the users whose presence the client follows.
+func (h *Hub) watch(c *client, userIDs []pgtype.UUID) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	h.unwatchAllLocked(c)
+	for _, userID := range userIDs {
+		if h.watchers[userID] == nil {
+			h.watchers[userID] = make(map[*client]struct{})
+		}
+		h.watchers[userID][c] = struct{}{}
+		c.watching[userID] = struct{}{}
+	}
+}
+
+func (h *Hub) unwatchAllLocked(c *client) {
+	for userID := range c.watching {
+		delete(h.watchers[userID], c)
+		if len(h.watchers[userID]) == 0 {
+			delete(h.watchers, userID)
+		}
+		delete(c.watching, userID)
+	}
+}
+
+func (h *Hub) subscribeUser(userID, conversationID pgtype.UUID) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	for c := range h.users[userID] {
+		h.subscribeLocked(c, conversationID)
+		delete(c.removed, conversationID)
+	}
+}
+
+func (h *Hub) unsubscribeUser(userID, conversationID pgtype.UUID) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	for c := range h.users[userID] {
+		h.unsubscribeLocked(c, conversationID)
+		if c.removed != nil {
+			c.removed[conversationID] = struct{}{}
+		}
+	}
+}
+
+// unsubscribeAll drops every socket's subscription to a conversation that
+// no longer exists.
+func (h *Hub) unsubscribeAll(conversationID pgtype.UUID) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	for c := range h.conversations[conversationID] {
+		h.unsubscribeLocked(c, conversationID)
+	}
+	for c := range h.resolving {
+		c.removed[conversationID] = struct{}{}
+	}
+}
+
+func (h *Hub) subscribeLocked(c *client, conversationID pgtype.UUID) {
+	if h.conversations[conversationID] == nil {
+		h.conversations[conversationID] = make(map[*client]struct{})
+	}
+	h.conversations[conversationID][c] = struct{}{}
+	c.conversations[conversationID] = struct{}{}
+}
+
+func (h *Hub) unsubscribeLocked(c *client, conversationID pgtype.UUID) {
+	delete(h.conversations[conversationID], c)
+	if len(h.conversations[conversationID]) == 0 {
+		delete(h.conversations, conversationID)
+	}
+	delete(c.conversations, conversationID)
+}
@@ -4,6 +4,8 @@ go 1.25.2
 
 require (
 	github.com/brianvoe/gofakeit/v6 v6.28.0
+	github.com/coder/websocket v1.8.14
+	github.com/golang-jwt/jwt/v5 v5.3.0
 	github.com/jackc/pgx/v5 v5.7.6
 )
 
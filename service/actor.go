@@ -0,0 +1,34 @@
+package service
+
+import (
+	"context"
+
+	"github.com/jackc/pgx/v5/pgtype"
+)
+
+// Actor is the authenticated user and device a service call is made on
+// behalf of.
+type Actor struct {
+	UserID    pgtype.UUID
+	DeviceID  pgtype.UUID
+	SessionID pgtype.UUID
+}
+
+type actorKey struct{}
+
+func WithActor(ctx context.Context, actor Actor) context.Context {
+	return context.WithValue(ctx, actorKey{}, actor)
+}
+
+func ActorFromContext(ctx context.Context) (Actor, bool) {
+	actor, ok := ctx.Value(actorKey{}).(Actor)
+	return actor, ok && actor.UserID.Valid
+}
+
+func requireActor(ctx context.Context) (Actor, error) {
+	actor, ok := ActorFromContext(ctx)
+	if !ok {
+		return Actor{}, unauthenticated("authentication required")
+	}
+	return actor, nil
+}
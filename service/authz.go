@@ -0,0 +1,84 @@
+package service
+
+import (
+	"context"
+	"errors"
+
+	"github.com/felipedavid/chatting/storage"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgtype"
+)
+
+const (
+	RoleOwner  = "owner"
+	RoleAdmin  = "admin"
+	RoleMember = "member"
+)
+
+// roleRank orders roles so checks can be written as "at least admin".
+func roleRank(role string) int {
+	switch role {
+	case RoleOwner:
+		return 3
+	case RoleAdmin:
+		return 2
+	default:
+		return 1
+	}
+}
+
+// authorizer enforces conversation membership and roles. Services hold one so
+// every operation goes through the same checks.
+type authorizer struct {
+	queries *storage.Queries
+}
+
+// requireParticipant returns the caller's membership in the conversation or
+// a permission-denied error when they are not part of it.
+func (a authorizer) requireParticipant(ctx context.Context, conversationID, userID pgtype.UUID) (storage.ConversationParticipant, error) {
+	participant, err := a.queries.GetConversationParticipant(ctx, storage.GetConversationParticipantParams{
+		ConversationID: conversationID,
+		UserID:         userID,
+	})
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return participant, permissionDenied("not a participant in this conversation")
+		}
+		return participant, storageError(err, "failed to check conversation membership")
+	}
+
+	return participant, nil
+}
+
+// requireRole is requireParticipant plus a minimum role.
+func (a authorizer) requireRole(ctx context.Context, conversationID, userID pgtype.UUID, minimum string) (storage.ConversationParticipant, error) {
+	participant, err := a.requireParticipant(ctx, conversationID, userID)
+	if err != nil {
+		return participant, err
+	}
+
+	if roleRank(participant.Role.String) < roleRank(minimum) {
+		return participant, permissionDenied("requires the %s role", minimum)
+	}
+
+	return participant, nil
+}
+
+// requireMessageAccess loads a message the caller can see because they
+// participate in its conversation.
+func (a authorizer) requireMessageAccess(ctx context.Context, messageID, userID pgtype.UUID) (storage.Message, storage.ConversationParticipant, error) {
+	message, err := a.queries.GetMessage(ctx, messageID)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return message, storage.ConversationParticipant{}, notFound("message not found")
+		}
+		return message, storage.ConversationParticipant{}, storageError(err, "failed to get message")
+	}
+
+	participant, err := a.requireParticipant(ctx, message.ConversationID, userID)
+	if err != nil {
+		return message, participant, err
+	}
+
+	return message, participant, nil
+}
@@ -0,0 +1,10 @@
+package service
+
+import "time"
+
+// Config holds the tunable policies of the services.
+type Config struct {
+	// MessageEditWindow is how long after sending a message its sender may
+	// still edit it. Zero means messages can always be edited.
+	MessageEditWindow time.Duration
+}
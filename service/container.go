@@ -1,17 +1,39 @@
 package service
 
-import "github.com/felipedavid/chatting/storage"
+import (
+	"github.com/felipedavid/chatting/auth"
+	"github.com/felipedavid/chatting/event"
+	"github.com/felipedavid/chatting/sms"
+	"github.com/felipedavid/chatting/storage"
+)
 
 type Container struct {
-	UserService         *UserService
-	ConversationService *ConversationService
-	MessageService      *MessageService
+	UserService             *UserService
+	ConversationService     *ConversationService
+	MessageService          *MessageService
+	VerificationService     *VerificationService
+	SessionService          *SessionService
+	SyncService             *SyncService
+	ReceiptService          *ReceiptService
+	ScheduledMessageService *ScheduledMessageService
+	PresenceService         *PresenceService
+	TypingService           *TypingService
 }
 
-func NewContainer(queries *storage.Queries) *Container {
+func NewContainer(queries *storage.Queries, tx TxRunner, publisher event.Publisher, smsSender sms.Sender, tokens *auth.TokenManager, cfg Config) *Container {
+	verificationService := NewVerificationService(queries, tx, smsSender, tokens)
+	messageService := NewMessageService(queries, tx, publisher, cfg)
+
 	return &Container{
-		UserService:         NewUserService(queries),
-		ConversationService: NewConversationService(queries),
-		MessageService:      NewMessageService(queries),
+		UserService:             NewUserService(queries, tx, publisher),
+		ConversationService:     NewConversationService(queries, tx, publisher),
+		MessageService:          messageService,
+		VerificationService:     verificationService,
+		SessionService:          NewSessionService(queries, tx, verificationService, tokens, publisher),
+		SyncService:             NewSyncService(queries),
+		ReceiptService:          NewReceiptService(queries, tx, publisher),
+		ScheduledMessageService: NewScheduledMessageService(queries, messageService),
+		PresenceService:         NewPresenceService(queries, publisher),
+		TypingService:           NewTypingService(queries, publisher),
 	}
 }
@@ -2,8 +2,9 @@ package service
 
 import (
 	"context"
-	"fmt"
+	"errors"
 
+	"github.com/felipedavid/chatting/event"
 	"github.com/felipedavid/chatting/storage"
 	"github.com/jackc/pgx/v5"
 	"github.com/jackc/pgx/v5/pgtype"
@@ -11,50 +12,70 @@ import (
 
 type ConversationService struct {
 	queries *storage.Queries
+	tx      TxRunner
+	events  notifier
+	authz   authorizer
 }
 
-func NewConversationService(queries *storage.Queries) *ConversationService {
-	return &ConversationService{queries: queries}
+func NewConversationService(queries *storage.Queries, tx TxRunner, publisher event.Publisher) *ConversationService {
+	return &ConversationService{queries: queries, tx: tx, events: notifier{publisher: publisher}, authz: authorizer{queries: queries}}
 }
 
 type CreateConversationRequest struct {
-	IsGroup   bool
-	Title     string
-	CreatedBy pgtype.UUID
+	IsGroup bool   `json:"is_group"`
+	Title   string `json:"title"`
 }
 
 type ConversationResponse struct {
-	ID        pgtype.UUID
-	IsGroup   bool
-	Title     string
-	CreatedBy pgtype.UUID
-	CreatedAt pgtype.Timestamptz
+	ID        pgtype.UUID        `json:"id"`
+	IsGroup   bool               `json:"is_group"`
+	Title     string             `json:"title"`
+	CreatedBy pgtype.UUID        `json:"created_by"`
+	CreatedAt pgtype.Timestamptz `json:"created_at"`
 }
 
 type ConversationWithCreatorResponse struct {
-	ID           pgtype.UUID
-	IsGroup      bool
-	Title        string
-	CreatedBy    pgtype.UUID
-	CreatedAt    pgtype.Timestamptz
-	CreatorPhone string
-	CreatorName  string
+	ID           pgtype.UUID        `json:"id"`
+	IsGroup      bool               `json:"is_group"`
+	Title        string             `json:"title"`
+	CreatedBy    pgtype.UUID        `json:"created_by"`
+	CreatedAt    pgtype.Timestamptz `json:"created_at"`
+	CreatorPhone string             `json:"creator_phone"`
+	CreatorName  string             `json:"creator_name"`
 }
 
 func (s *ConversationService) CreateConversation(ctx context.Context, req CreateConversationRequest) (*ConversationResponse, error) {
-	if !req.CreatedBy.Valid {
-		return nil, fmt.Errorf("created by user ID is required")
+	actor, err := requireActor(ctx)
+	if err != nil {
+		return nil, err
 	}
 
 	params := storage.CreateConversationParams{
 		IsGroup:   req.IsGroup,
 		Title:     pgtype.Text{String: req.Title, Valid: req.Title != ""},
-		CreatedBy: req.CreatedBy,
+		CreatedBy: actor.UserID,
 	}
 
-	conversation, err := s.queries.CreateConversation(ctx, params)
+	var conversation storage.Conversation
+	err = s.tx.InTx(ctx, func(q *storage.Queries) error {
+		conversation, err = q.CreateConversation(ctx, params)
+		if err != nil {
+			return storageError(err, "failed to create conversation")
+		}
+
+		// The creator owns the conversation
+		if _, err := q.AddConversationParticipant(ctx, storage.AddConversationParticipantParams{
+			ConversationID: conversation.ID,
+			UserID:         actor.UserID,
+			Role:           pgtype.Text{String: RoleOwner, Valid: true},
+		}); err != nil {
+			return storageError(err, "failed to add conversation owner")
+		}
+
+		return nil
+	})
 	if err != nil {
-		return nil, fmt.Errorf("failed to create conversation: %w", err)
+		return nil, err
 	}
 
 	return &ConversationResponse{
@@ -67,16 +88,24 @@ func (s *ConversationService) CreateConversation(ctx context.Context, req Create
 }
 
 func (s *ConversationService) GetConversation(ctx context.Context, conversationID pgtype.UUID) (*ConversationResponse, error) {
+	actor, err := requireActor(ctx)
+	if err != nil {
+		return nil, err
+	}
 	if !conversationID.Valid {
-		return nil, fmt.Errorf("conversation ID is required")
+		return nil, invalidArgument("conversation ID is required")
+	}
+
+	if _, err := s.authz.requireParticipant(ctx, conversationID, actor.UserID); err != nil {
+		return nil, err
 	}
 
 	conversation, err := s.queries.GetConversation(ctx, conversationID)
 	if err != nil {
-		if err == pgx.ErrNoRows {
-			return nil, fmt.Errorf("conversation not found")
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, notFound("conversation not found")
 		}
-		return nil, fmt.Errorf("failed to get conversation: %w", err)
+		return nil, storageError(err, "failed to get conversation")
 	}
 
 	return &ConversationResponse{
@@ -89,16 +118,24 @@ func (s *ConversationService) GetConversation(ctx context.Context, conversationI
 }
 
 func (s *ConversationService) GetConversationWithCreator(ctx context.Context, conversationID pgtype.UUID) (*ConversationWithCreatorResponse, error) {
+	actor, err := requireActor(ctx)
+	if err != nil {
+		return nil, err
+	}
 	if !conversationID.Valid {
-		return nil, fmt.Errorf("conversation ID is required")
+		return nil, invalidArgument("conversation ID is required")
+	}
+
+	if _, err := s.authz.requireParticipant(ctx, conversationID, actor.UserID); err != nil {
+		return nil, err
 	}
 
 	conversation, err := s.queries.GetConversationByIdWithCreator(ctx, conversationID)
 	if err != nil {
-		if err == pgx.ErrNoRows {
-			return nil, fmt.Errorf("conversation not found")
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, notFound("conversation not found")
 		}
-		return nil, fmt.Errorf("failed to get conversation with creator: %w", err)
+		return nil, storageError(err, "failed to get conversation with creator")
 	}
 
 	return &ConversationWithCreatorResponse{
@@ -113,64 +150,266 @@ func (s *ConversationService) GetConversationWithCreator(ctx context.Context, co
 }
 
 func (s *ConversationService) DeleteConversation(ctx context.Context, conversationID pgtype.UUID) error {
+	actor, err := requireActor(ctx)
+	if err != nil {
+		return err
+	}
 	if !conversationID.Valid {
-		return fmt.Errorf("conversation ID is required")
+		return invalidArgument("conversation ID is required")
 	}
 
-	// First delete all messages in the conversation
-	if err := s.queries.DeleteConversationMessages(ctx, conversationID); err != nil {
-		return fmt.Errorf("failed to delete conversation messages: %w", err)
-	}
+	var deleted event.Event
+	err = s.tx.InTx(ctx, func(q *storage.Queries) error {
+		authz := authorizer{queries: q}
+		if _, err := authz.requireRole(ctx, conversationID, actor.UserID, RoleOwner); err != nil {
+			return err
+		}
+
+		participants, err := q.ListConversationParticipants(ctx, conversationID)
+		if err != nil {
+			return storageError(err, "failed to list participants")
+		}
+
+		// First delete all messages in the conversation
+		if err := q.DeleteConversationMessages(ctx, conversationID); err != nil {
+			return storageError(err, "failed to delete conversation messages")
+		}
+
+		// Then delete the conversation
+		if err := q.DeleteConversation(ctx, conversationID); err != nil {
+			return storageError(err, "failed to delete conversation")
+		}
+
+		// Nobody can sync the conversation's history anymore; its former
+		// participants only need to learn that it is gone
+		if err := q.DeleteConversationEvents(ctx, conversationID); err != nil {
+			return storageError(err, "failed to delete conversation events")
+		}
 
-	// Then delete the conversation
-	if err := s.queries.DeleteConversation(ctx, conversationID); err != nil {
-		return fmt.Errorf("failed to delete conversation: %w", err)
+		members := make([]pgtype.UUID, 0, len(participants))
+		for _, participant := range participants {
+			members = append(members, participant.UserID)
+		}
+
+		deleted, err = s.events.recordFor(ctx, q, members, event.ConversationDeleted, conversationID, ConversationDeletedResponse{
+			ConversationID: conversationID,
+			DeletedBy:      actor.UserID,
+		})
+		return err
+	})
+	if err != nil {
+		return err
 	}
 
+	s.events.publish(ctx, deleted)
+
 	return nil
 }
 
+// AddParticipant adds userID to the conversation. Admins can add members;
+// only the owner can add other admins.
 func (s *ConversationService) AddParticipant(ctx context.Context, conversationID, userID pgtype.UUID, role string) error {
+	actor, err := requireActor(ctx)
+	if err != nil {
+		return err
+	}
 	if !conversationID.Valid || !userID.Valid {
-		return fmt.Errorf("conversation ID and user ID are required")
+		return invalidArgument("conversation ID and user ID are required")
+	}
+	if role == "" {
+		role = RoleMember
+	}
+	if role != RoleMember && role != RoleAdmin {
+		return invalidArgument("role must be %q or %q", RoleMember, RoleAdmin)
 	}
 
 	params := storage.AddConversationParticipantParams{
 		ConversationID: conversationID,
 		UserID:         userID,
-		Role:           pgtype.Text{String: role, Valid: role != ""},
+		Role:           pgtype.Text{String: role, Valid: true},
 	}
 
-	if _, err := s.queries.AddConversationParticipant(ctx, params); err != nil {
-		return fmt.Errorf("failed to add participant: %w", err)
+	var added event.Event
+	var announced []event.Event
+	err = s.tx.InTx(ctx, func(q *storage.Queries) error {
+		// The caller's role is checked in the transaction so a concurrent
+		// demotion or removal cannot slip in between
+		authz := authorizer{queries: q}
+		caller, err := authz.requireRole(ctx, conversationID, actor.UserID, RoleAdmin)
+		if err != nil {
+			return err
+		}
+		if roleRank(role) >= roleRank(caller.Role.String) {
+			return permissionDenied("cannot grant the %s role", role)
+		}
+
+		participant, err := q.AddConversationParticipant(ctx, params)
+		if err != nil {
+			switch err := storageError(err, "failed to add participant"); {
+			case errors.Is(err, ErrAlreadyExists):
+				return alreadyExists("user is already a participant")
+			case errors.Is(err, ErrInvalidArgument):
+				return notFound("user not found")
+			default:
+				return err
+			}
+		}
+
+		added, err = s.events.record(ctx, q, event.ParticipantAdded, conversationID, ParticipantResponse{
+			ConversationID: participant.ConversationID,
+			UserID:         participant.UserID,
+			Role:           participant.Role.String,
+			JoinedAt:       participant.JoinedAt,
+		})
+		if err != nil {
+			return err
+		}
+
+		announced, err = s.announce(ctx, q, conversationID, SystemParticipantAdded, actor.UserID, userID)
+		return err
+	})
+	if err != nil {
+		return err
 	}
 
+	// The new participant's sockets are subscribed by the first event, so
+	// they receive the announcement too
+	s.events.publish(ctx, append([]event.Event{added}, announced...)...)
+
 	return nil
 }
 
+// RemoveParticipant removes userID from the conversation. Anyone but the
+// owner may leave; removing someone else requires outranking them.
 func (s *ConversationService) RemoveParticipant(ctx context.Context, conversationID, userID pgtype.UUID) error {
+	actor, err := requireActor(ctx)
+	if err != nil {
+		return err
+	}
 	if !conversationID.Valid || !userID.Valid {
-		return fmt.Errorf("conversation ID and user ID are required")
+		return invalidArgument("conversation ID and user ID are required")
 	}
 
-	if err := s.queries.RemoveConversationParticipant(ctx, storage.RemoveConversationParticipantParams{
-		ConversationID: conversationID,
-		UserID:         userID,
-	}); err != nil {
-		return fmt.Errorf("failed to remove participant: %w", err)
+	action := SystemParticipantRemoved
+	if userID == actor.UserID {
+		action = SystemParticipantLeft
+	}
+
+	var removed event.Event
+	var announced []event.Event
+	err = s.tx.InTx(ctx, func(q *storage.Queries) error {
+		authz := authorizer{queries: q}
+		caller, err := authz.requireParticipant(ctx, conversationID, actor.UserID)
+		if err != nil {
+			return err
+		}
+
+		if userID == actor.UserID {
+			if caller.Role.String == RoleOwner {
+				return permissionDenied("the owner cannot leave the conversation")
+			}
+		} else {
+			target, err := q.GetConversationParticipant(ctx, storage.GetConversationParticipantParams{
+				ConversationID: conversationID,
+				UserID:         userID,
+			})
+			if err != nil {
+				if errors.Is(err, pgx.ErrNoRows) {
+					return notFound("participant not found")
+				}
+				return storageError(err, "failed to get participant")
+			}
+
+			if roleRank(caller.Role.String) < roleRank(RoleAdmin) || roleRank(target.Role.String) >= roleRank(caller.Role.String) {
+				return permissionDenied("cannot remove this participant")
+			}
+		}
+
+		if err := q.RemoveConversationParticipant(ctx, storage.RemoveConversationParticipantParams{
+			ConversationID: conversationID,
+			UserID:         userID,
+		}); err != nil {
+			return storageError(err, "failed to remove participant")
+		}
+
+		// The removed user is no longer a participant but must still sync
+		// their own removal
+		removed, err = s.events.recordFor(ctx, q, []pgtype.UUID{userID}, event.ParticipantRemoved, conversationID, ParticipantResponse{
+			ConversationID: conversationID,
+			UserID:         userID,
+		})
+		if err != nil {
+			return err
+		}
+
+		announced, err = s.announce(ctx, q, conversationID, action, actor.UserID, userID)
+		return err
+	})
+	if err != nil {
+		return err
 	}
 
+	s.events.publish(ctx, append([]event.Event{removed}, announced...)...)
+
 	return nil
 }
 
+// announce adds a system message about a change to the conversation's
+// membership and records it for sync. The announcement is no news to the
+// actor or to a user who was added, so their read watermarks move past it.
+// It returns the recorded events.
+func (s *ConversationService) announce(ctx context.Context, q *storage.Queries, conversationID pgtype.UUID, action string, actorID, userID pgtype.UUID) ([]event.Event, error) {
+	payload, err := encodePayload(SystemPayload{Action: action, ActorID: actorID, UserID: userID})
+	if err != nil {
+		return nil, err
+	}
+
+	message, err := q.CreateMessage(ctx, storage.CreateMessageParams{
+		ConversationID: conversationID,
+		MessageType:    MessageTypeSystem,
+		Payload:        payload,
+	})
+	if err != nil {
+		return nil, storageError(err, "failed to create system message")
+	}
+
+	created, err := s.events.record(ctx, q, event.MessageCreated, conversationID, newMessageResponse(message))
+	if err != nil {
+		return nil, err
+	}
+	events := []event.Event{created}
+
+	readers := []pgtype.UUID{actorID}
+	if action == SystemParticipantAdded {
+		readers = append(readers, userID)
+	}
+	for _, reader := range readers {
+		read, err := readUpTo(ctx, q, s.events, conversationID, reader, message.Seq)
+		if err != nil {
+			return nil, err
+		}
+		events = append(events, read...)
+	}
+
+	return events, nil
+}
+
 func (s *ConversationService) GetConversationParticipants(ctx context.Context, conversationID pgtype.UUID) ([]ParticipantResponse, error) {
+	actor, err := requireActor(ctx)
+	if err != nil {
+		return nil, err
+	}
 	if !conversationID.Valid {
-		return nil, fmt.Errorf("conversation ID is required")
+		return nil, invalidArgument("conversation ID is required")
+	}
+
+	if _, err := s.authz.requireParticipant(ctx, conversationID, actor.UserID); err != nil {
+		return nil, err
 	}
 
 	participants, err := s.queries.ListConversationParticipantsWithDetails(ctx, conversationID)
 	if err != nil {
-		return nil, fmt.Errorf("failed to get conversation participants: %w", err)
+		return nil, storageError(err, "failed to get conversation participants")
 	}
 
 	var responses []ParticipantResponse
@@ -188,11 +427,84 @@ func (s *ConversationService) GetConversationParticipants(ctx context.Context, c
 	return responses, nil
 }
 
+func (s *ConversationService) ListUserConversations(ctx context.Context) ([]UserConversationResponse, error) {
+	actor, err := requireActor(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	conversations, err := s.queries.ListUserConversations(ctx, actor.UserID)
+	if err != nil {
+		return nil, storageError(err, "failed to list user conversations")
+	}
+
+	var responses []UserConversationResponse
+	for _, conversation := range conversations {
+		responses = append(responses, UserConversationResponse{
+			ConversationID: conversation.ConversationID,
+			IsGroup:        conversation.IsGroup,
+			Title:          conversation.Title.String,
+			Role:           conversation.Role.String,
+			JoinedAt:       conversation.JoinedAt,
+			CreatedAt:      conversation.ConversationCreatedAt,
+		})
+	}
+
+	return responses, nil
+}
+
+// GetUnreadCounts returns the unread message and mention counts of every
+// conversation of the caller's that has something unread.
+func (s *ConversationService) GetUnreadCounts(ctx context.Context) ([]UnreadCountResponse, error) {
+	actor, err := requireActor(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := s.queries.ListUnreadCounts(ctx, actor.UserID)
+	if err != nil {
+		return nil, storageError(err, "failed to count unread messages")
+	}
+
+	counts := []UnreadCountResponse{}
+	for _, row := range rows {
+		counts = append(counts, UnreadCountResponse{
+			ConversationID:     row.ConversationID,
+			UnreadCount:        row.UnreadCount,
+			UnreadMentionCount: row.UnreadMentionCount,
+		})
+	}
+
+	return counts, nil
+}
+
+type UserConversationResponse struct {
+	ConversationID pgtype.UUID        `json:"conversation_id"`
+	IsGroup        bool               `json:"is_group"`
+	Title          string             `json:"title"`
+	Role           string             `json:"role"`
+	JoinedAt       pgtype.Timestamptz `json:"joined_at"`
+	CreatedAt      pgtype.Timestamptz `json:"created_at"`
+}
+
+// ConversationDeletedResponse tells the former participants of a
+// conversation that it was deleted along with its history.
+type ConversationDeletedResponse struct {
+	ConversationID pgtype.UUID `json:"conversation_id"`
+	DeletedBy      pgtype.UUID `json:"deleted_by"`
+}
+
+type UnreadCountResponse struct {
+	ConversationID     pgtype.UUID `json:"conversation_id"`
+	UnreadCount        int64       `json:"unread_count"`
+	UnreadMentionCount int32       `json:"unread_mention_count"`
+}
+
 type ParticipantResponse struct {
-	ConversationID pgtype.UUID
-	UserID         pgtype.UUID
-	Role           string
-	JoinedAt       pgtype.Timestamptz
-	PhoneNumber    string
-	DisplayName    string
+	ConversationID pgtype.UUID        `json:"conversation_id"`
+	UserID         pgtype.UUID        `json:"user_id"`
+	Role           string             `json:"role"`
+	JoinedAt       pgtype.Timestamptz `json:"joined_at"`
+	PhoneNumber    string             `json:"phone_number"`
+	DisplayName    string             `json:"display_name"`
 }
@@ -0,0 +1,49 @@
+package service
+
+import (
+	"encoding/base64"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgtype"
+)
+
+// cursor is a position in a conversation's message history. Messages are
+// ordered by (created_at, id) so that messages sharing a timestamp still have
+// a stable order.
+type cursor struct {
+	CreatedAt pgtype.Timestamptz
+	ID        pgtype.UUID
+}
+
+// encodeCursor returns an opaque token for the position. Clients must not
+// rely on its format.
+func encodeCursor(createdAt pgtype.Timestamptz, id pgtype.UUID) string {
+	raw := strconv.FormatInt(createdAt.Time.UnixMicro(), 10) + ":" + id.String()
+	return base64.RawURLEncoding.EncodeToString([]byte(raw))
+}
+
+func decodeCursor(token string) (cursor, error) {
+	raw, err := base64.RawURLEncoding.DecodeString(token)
+	if err != nil {
+		return cursor{}, invalidArgument("invalid cursor")
+	}
+
+	micros, id, ok := strings.Cut(string(raw), ":")
+	if !ok {
+		return cursor{}, invalidArgument("invalid cursor")
+	}
+
+	usec, err := strconv.ParseInt(micros, 10, 64)
+	if err != nil {
+		return cursor{}, invalidArgument("invalid cursor")
+	}
+
+	c := cursor{CreatedAt: pgtype.Timestamptz{Time: time.UnixMicro(usec), Valid: true}}
+	if err := c.ID.Scan(id); err != nil {
+		return cursor{}, invalidArgument("invalid cursor")
+	}
+
+	return c, nil
+}
@@ -0,0 +1,110 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+)
+
+// Error kinds returned by the services. Match them with errors.Is; the
+// concrete error carries a message meant for the client.
+var (
+	ErrNotFound         = errors.New("not found")
+	ErrInvalidArgument  = errors.New("invalid argument")
+	ErrAlreadyExists    = errors.New("already exists")
+	ErrPermissionDenied = errors.New("permission denied")
+	ErrConflict         = errors.New("conflict")
+	ErrUnauthenticated  = errors.New("unauthenticated")
+	ErrRateLimited      = errors.New("rate limited")
+)
+
+// Postgres error codes the services classify.
+const (
+	pgUniqueViolation      = "23505"
+	pgForeignKeyViolation  = "23503"
+	pgCheckViolation       = "23514"
+	pgSerializationFailure = "40001"
+	pgDeadlockDetected     = "40P01"
+)
+
+// Error is a service error of a known kind, optionally wrapping the error
+// that caused it.
+type Error struct {
+	Kind    error
+	Message string
+	Err     error
+}
+
+func (e *Error) Error() string {
+	if e.Err != nil {
+		return fmt.Sprintf("%s: %v", e.Message, e.Err)
+	}
+	return e.Message
+}
+
+func (e *Error) Is(target error) bool {
+	return target == e.Kind
+}
+
+func (e *Error) Unwrap() error {
+	return e.Err
+}
+
+func newError(kind error, format string, args ...any) error {
+	return &Error{Kind: kind, Message: fmt.Sprintf(format, args...)}
+}
+
+func notFound(format string, args ...any) error {
+	return newError(ErrNotFound, format, args...)
+}
+
+func invalidArgument(format string, args ...any) error {
+	return newError(ErrInvalidArgument, format, args...)
+}
+
+func alreadyExists(format string, args ...any) error {
+	return newError(ErrAlreadyExists, format, args...)
+}
+
+func permissionDenied(format string, args ...any) error {
+	return newError(ErrPermissionDenied, "permission denied: "+format, args...)
+}
+
+func conflict(format string, args ...any) error {
+	return newError(ErrConflict, format, args...)
+}
+
+func unauthenticated(format string, args ...any) error {
+	return newError(ErrUnauthenticated, format, args...)
+}
+
+func rateLimited(format string, args ...any) error {
+	return newError(ErrRateLimited, format, args...)
+}
+
+// storageError wraps a database error. Constraint violations and
+// serialization failures are classified so callers can tell them apart from
+// infrastructure failures; anything else is returned as a plain wrapped error.
+func storageError(err error, format string, args ...any) error {
+	message := fmt.Sprintf(format, args...)
+
+	if errors.Is(err, pgx.ErrNoRows) {
+		return &Error{Kind: ErrNotFound, Message: message, Err: err}
+	}
+
+	var pgErr *pgconn.PgError
+	if errors.As(err, &pgErr) {
+		switch pgErr.Code {
+		case pgUniqueViolation:
+			return &Error{Kind: ErrAlreadyExists, Message: message, Err: err}
+		case pgForeignKeyViolation, pgCheckViolation:
+			return &Error{Kind: ErrInvalidArgument, Message: message, Err: err}
+		case pgSerializationFailure, pgDeadlockDetected:
+			return &Error{Kind: ErrConflict, Message: message, Err: err}
+		}
+	}
+
+	return fmt.Errorf("%s: %w", message, err)
+}
@@ -0,0 +1,119 @@
+package service
+
+import (
+	"context"
+	"log/slog"
+
+	"github.com/felipedavid/chatting/event"
+	"github.com/felipedavid/chatting/storage"
+	"github.com/jackc/pgx/v5/pgtype"
+)
+
+// notifier records conversation events for devices that are offline and
+// publishes them to the ones that are connected.
+type notifier struct {
+	publisher event.Publisher
+}
+
+// record stores an event for sync through q, which must belong to the
+// transaction making the change the event describes, so the change never
+// commits without it. The event is returned to be published once the
+// transaction has committed.
+func (n notifier) record(ctx context.Context, q *storage.Queries, eventType event.Type, conversationID pgtype.UUID, data any) (event.Event, error) {
+	return n.recordFor(ctx, q, nil, eventType, conversationID, data)
+}
+
+// recordFor is record for events that subjects must see during sync even if
+// they are no longer participants, such as their own removal.
+func (n notifier) recordFor(ctx context.Context, q *storage.Queries, subjects []pgtype.UUID, eventType event.Type, conversationID pgtype.UUID, data any) (event.Event, error) {
+	return n.insert(ctx, q, subjects, false, eventType, conversationID, data)
+}
+
+// recordPrivate is record for events only recipients may see, such as
+// receipts for their messages. The event is published to them alone.
+func (n notifier) recordPrivate(ctx context.Context, q *storage.Queries, recipients []pgtype.UUID, eventType event.Type, conversationID pgtype.UUID, data any) (event.Event, error) {
+	e, err := n.insert(ctx, q, recipients, true, eventType, conversationID, data)
+	if err != nil {
+		return event.Event{}, err
+	}
+	e.Recipients = recipients
+
+	return e, nil
+}
+
+func (n notifier) insert(ctx context.Context, q *storage.Queries, subjects []pgtype.UUID, private bool, eventType event.Type, conversationID pgtype.UUID, data any) (event.Event, error) {
+	e, err := event.New(eventType, conversationID, data)
+	if err != nil {
+		return event.Event{}, err
+	}
+
+	recorded, err := q.CreateConversationEvent(ctx, storage.CreateConversationEventParams{
+		ConversationID: conversationID,
+		EventType:      string(eventType),
+		Payload:        e.Data,
+		Private:        private,
+	})
+	if err != nil {
+		return event.Event{}, storageError(err, "failed to record event")
+	}
+
+	if len(subjects) > 0 {
+		if err := q.CreateConversationEventSubjects(ctx, storage.CreateConversationEventSubjectsParams{
+			EventID: recorded.ID,
+			UserIds: subjects,
+		}); err != nil {
+			return event.Event{}, storageError(err, "failed to record event subjects")
+		}
+	}
+
+	return e, nil
+}
+
+// publish sends recorded events to the devices that are connected. Delivery
+// is best effort: a failure is logged, and devices that miss an event catch
+// up on it through sync.
+func (n notifier) publish(ctx context.Context, events ...event.Event) {
+	for _, e := range events {
+		if err := n.publisher.Publish(ctx, e); err != nil {
+			slog.Warn("failed to publish event", "type", e.Type, "conversation_id", e.ConversationID, "error", err)
+		}
+	}
+}
+
+// publishGlobal sends an event that belongs to no conversation, such as a
+// presence change, to every instance. It is neither recorded for sync nor
+// routed by conversation.
+func publishGlobal(ctx context.Context, publisher event.Publisher, eventType event.Type, data any) {
+	e, err := event.New(eventType, pgtype.UUID{}, data)
+	if err != nil {
+		slog.Warn("failed to encode event", "type", eventType, "error", err)
+		return
+	}
+
+	if err := publisher.Publish(ctx, e); err != nil {
+		slog.Warn("failed to publish event", "type", eventType, "error", err)
+	}
+}
+
+// notify delivers an event to the connected devices of recipients only. It
+// is not recorded for sync, so it suits notifications derived from changes
+// that were recorded on their own and signals that are worthless once they
+// are late.
+func (n notifier) notify(ctx context.Context, recipients []pgtype.UUID, eventType event.Type, conversationID pgtype.UUID, data any) {
+	if len(recipients) == 0 {
+		return
+	}
+
+	n.send(ctx, recipients, eventType, conversationID, data)
+}
+
+func (n notifier) send(ctx context.Context, recipients []pgtype.UUID, eventType event.Type, conversationID pgtype.UUID, data any) {
+	e, err := event.New(eventType, conversationID, data)
+	if err != nil {
+		slog.Warn("failed to encode event", "type", eventType, "conversation_id", conversationID, "error", err)
+		return
+	}
+	e.Recipients = recipients
+
+	n.publish(ctx, e)
+}
@@ -0,0 +1,268 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/felipedavid/chatting/event"
+	"github.com/felipedavid/chatting/storage"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgtype"
+)
+
+// maxPreviewLength is the number of characters of the last message shown in
+// the inbox.
+const maxPreviewLength = 100
+
+type ListInboxRequest struct {
+	Before string
+	Limit  int32
+}
+
+// InboxPage is one page of the caller's conversations, most recently active
+// first. NextCursor continues with less active ones.
+type InboxPage struct {
+	Conversations []InboxEntry `json:"conversations"`
+	NextCursor    string       `json:"next_cursor,omitempty"`
+	HasMore       bool         `json:"has_more"`
+}
+
+// InboxEntry is a conversation as listed in the inbox. The title of a 1:1
+// conversation is the other participant's name, who is also given as
+// OtherUserID.
+type InboxEntry struct {
+	ConversationID     pgtype.UUID        `json:"conversation_id"`
+	IsGroup            bool               `json:"is_group"`
+	Title              string             `json:"title"`
+	OtherUserID        pgtype.UUID        `json:"other_user_id"`
+	LastMessage        *MessagePreview    `json:"last_message"`
+	UnreadCount        int64              `json:"unread_count"`
+	UnreadMentionCount int32              `json:"unread_mention_count"`
+	Muted              bool               `json:"muted"`
+	MutedUntil         pgtype.Timestamptz `json:"muted_until"`
+	Pinned             bool               `json:"pinned"`
+	Archived           bool               `json:"archived"`
+	ActivityAt         pgtype.Timestamptz `json:"activity_at"`
+}
+
+// MessagePreview is a shortened message for the inbox. Preview is empty for
+// deleted messages and for messages without text, whose type tells clients
+// what to show instead.
+type MessagePreview struct {
+	ID          pgtype.UUID        `json:"id"`
+	SenderID    pgtype.UUID        `json:"sender_id"`
+	SenderName  string             `json:"sender_name"`
+	MessageType string             `json:"message_type"`
+	Preview     string             `json:"preview"`
+	Deleted     bool               `json:"deleted"`
+	CreatedAt   pgtype.Timestamptz `json:"created_at"`
+}
+
+// InboxSettingsResponse holds how the caller files a conversation in their
+// inbox.
+type InboxSettingsResponse struct {
+	ConversationID pgtype.UUID        `json:"conversation_id"`
+	MutedUntil     pgtype.Timestamptz `json:"muted_until"`
+	PinnedAt       pgtype.Timestamptz `json:"pinned_at"`
+	ArchivedAt     pgtype.Timestamptz `json:"archived_at"`
+}
+
+// messagePreview collapses whitespace in content and cuts it to
+// maxPreviewLength characters.
+func messagePreview(content string) string {
+	preview := []rune(strings.Join(strings.Fields(content), " "))
+	if len(preview) > maxPreviewLength {
+		return string(preview[:maxPreviewLength-1]) + "…"
+	}
+	return string(preview)
+}
+
+// muted reports whether a conversation muted until the given time is still
+// muted.
+func muted(until pgtype.Timestamptz) bool {
+	if !until.Valid {
+		return false
+	}
+	return until.InfinityModifier == pgtype.Infinity || until.Time.After(time.Now())
+}
+
+func newInboxEntry(row storage.ListInboxRow) InboxEntry {
+	entry := InboxEntry{
+		ConversationID:     row.ID,
+		IsGroup:            row.IsGroup,
+		Title:              row.Title.String,
+		UnreadCount:        row.UnreadCount,
+		UnreadMentionCount: row.UnreadMentionCount,
+		Muted:              muted(row.MutedUntil),
+		Pinned:             row.PinnedAt.Valid,
+		Archived:           row.ArchivedAt.Valid,
+		ActivityAt:         row.ActivityAt,
+	}
+	if entry.Muted {
+		entry.MutedUntil = row.MutedUntil
+	}
+
+	if !row.IsGroup && row.OtherUserID.Valid {
+		entry.OtherUserID = row.OtherUserID
+		entry.Title = row.OtherDisplayName.String
+		if entry.Title == "" {
+			entry.Title = row.OtherPhoneNumber.String
+		}
+	}
+
+	if row.LastMessageID.Valid {
+		entry.LastMessage = &MessagePreview{
+			ID:          row.LastMessageID,
+			SenderID:    row.LastMessageSenderID,
+			SenderName:  row.LastMessageSenderName.String,
+			MessageType: row.LastMessageType.String,
+			Deleted:     row.LastMessageDeletedAt.Valid,
+			CreatedAt:   row.LastMessageAt,
+		}
+		if !entry.LastMessage.Deleted {
+			entry.LastMessage.Preview = messagePreview(row.LastMessageContent.String)
+		}
+	}
+
+	return entry
+}
+
+func newInboxSettingsResponse(participant storage.ConversationParticipant) *InboxSettingsResponse {
+	return &InboxSettingsResponse{
+		ConversationID: participant.ConversationID,
+		MutedUntil:     participant.MutedUntil,
+		PinnedAt:       participant.PinnedAt,
+		ArchivedAt:     participant.ArchivedAt,
+	}
+}
+
+// ListInbox returns the caller's conversations, including archived ones,
+// with what the inbox shows for each of them.
+func (s *ConversationService) ListInbox(ctx context.Context, req ListInboxRequest) (*InboxPage, error) {
+	actor, err := requireActor(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	if req.Limit <= 0 {
+		req.Limit = 50
+	}
+	if req.Limit > maxPageSize {
+		req.Limit = maxPageSize
+	}
+
+	params := storage.ListInboxParams{
+		UserID: actor.UserID,
+		Limit:  req.Limit + 1,
+	}
+	if req.Before != "" {
+		c, err := decodeCursor(req.Before)
+		if err != nil {
+			return nil, err
+		}
+		params.ActivityAt, params.ID = c.CreatedAt, c.ID
+	}
+
+	// Fetch one extra row to learn whether another page follows
+	rows, err := s.queries.ListInbox(ctx, params)
+	if err != nil {
+		return nil, storageError(err, "failed to list inbox")
+	}
+
+	page := &InboxPage{Conversations: []InboxEntry{}}
+	if len(rows) > int(req.Limit) {
+		rows = rows[:req.Limit]
+		page.HasMore = true
+	}
+
+	for _, row := range rows {
+		page.Conversations = append(page.Conversations, newInboxEntry(row))
+	}
+
+	if len(rows) > 0 {
+		last := rows[len(rows)-1]
+		page.NextCursor = encodeCursor(last.ActivityAt, last.ID)
+	}
+
+	return page, nil
+}
+
+// MuteConversation mutes a conversation for the caller until the given
+// time, or until they unmute it if until is nil.
+func (s *ConversationService) MuteConversation(ctx context.Context, conversationID pgtype.UUID, until *time.Time) (*InboxSettingsResponse, error) {
+	mutedUntil := pgtype.Timestamptz{InfinityModifier: pgtype.Infinity, Valid: true}
+	if until != nil {
+		if !until.After(time.Now()) {
+			return nil, invalidArgument("mute end must be in the future")
+		}
+		mutedUntil = pgtype.Timestamptz{Time: *until, Valid: true}
+	}
+
+	return s.updateInboxSettings(ctx, conversationID, func(userID pgtype.UUID) (storage.ConversationParticipant, error) {
+		return s.queries.SetConversationMuted(ctx, storage.SetConversationMutedParams{
+			MutedUntil:     mutedUntil,
+			ConversationID: conversationID,
+			UserID:         userID,
+		})
+	})
+}
+
+func (s *ConversationService) UnmuteConversation(ctx context.Context, conversationID pgtype.UUID) (*InboxSettingsResponse, error) {
+	return s.updateInboxSettings(ctx, conversationID, func(userID pgtype.UUID) (storage.ConversationParticipant, error) {
+		return s.queries.SetConversationMuted(ctx, storage.SetConversationMutedParams{
+			ConversationID: conversationID,
+			UserID:         userID,
+		})
+	})
+}
+
+// PinConversation pins or unpins a conversation in the caller's inbox.
+func (s *ConversationService) PinConversation(ctx context.Context, conversationID pgtype.UUID, pinned bool) (*InboxSettingsResponse, error) {
+	return s.updateInboxSettings(ctx, conversationID, func(userID pgtype.UUID) (storage.ConversationParticipant, error) {
+		return s.queries.SetConversationPinned(ctx, storage.SetConversationPinnedParams{
+			Pinned:         pinned,
+			ConversationID: conversationID,
+			UserID:         userID,
+		})
+	})
+}
+
+// ArchiveConversation archives or unarchives a conversation in the caller's
+// inbox.
+func (s *ConversationService) ArchiveConversation(ctx context.Context, conversationID pgtype.UUID, archived bool) (*InboxSettingsResponse, error) {
+	return s.updateInboxSettings(ctx, conversationID, func(userID pgtype.UUID) (storage.ConversationParticipant, error) {
+		return s.queries.SetConversationArchived(ctx, storage.SetConversationArchivedParams{
+			Archived:       archived,
+			ConversationID: conversationID,
+			UserID:         userID,
+		})
+	})
+}
+
+// updateInboxSettings applies update to the caller's participation in a
+// conversation and tells their other devices about the new settings.
+func (s *ConversationService) updateInboxSettings(ctx context.Context, conversationID pgtype.UUID, update func(userID pgtype.UUID) (storage.ConversationParticipant, error)) (*InboxSettingsResponse, error) {
+	actor, err := requireActor(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if !conversationID.Valid {
+		return nil, invalidArgument("conversation ID is required")
+	}
+
+	participant, err := update(actor.UserID)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, permissionDenied("not a participant in this conversation")
+		}
+		return nil, storageError(err, "failed to update conversation settings")
+	}
+
+	response := newInboxSettingsResponse(participant)
+
+	s.events.notify(ctx, []pgtype.UUID{actor.UserID}, event.InboxSettingsUpdated, conversationID, response)
+
+	return response, nil
+}
@@ -0,0 +1,228 @@
+package service
+
+import (
+	"context"
+	"slices"
+	"strings"
+	"unicode"
+
+	"github.com/felipedavid/chatting/storage"
+	"github.com/jackc/pgx/v5/pgtype"
+)
+
+// mentionAll is the handle that mentions every participant. Only admins and
+// the owner can use it; from anyone else it is plain text.
+const mentionAll = "all"
+
+type ListMentionsRequest struct {
+	Before string
+	Limit  int32
+}
+
+// MentionPage is one page of the messages that mention the caller, newest
+// first. NextCursor continues with older mentions.
+type MentionPage struct {
+	Messages   []MessageResponse `json:"messages"`
+	NextCursor string            `json:"next_cursor,omitempty"`
+	HasMore    bool              `json:"has_more"`
+}
+
+// mentionHandles returns the handles written as @handle in content, in the
+// order they appear. A handle runs until the next space and loses any
+// trailing punctuation, so "@ana," mentions "ana".
+func mentionHandles(content string) []string {
+	var handles []string
+	for _, word := range strings.Fields(content) {
+		handle, ok := strings.CutPrefix(word, "@")
+		if !ok {
+			continue
+		}
+		handle = strings.TrimRightFunc(handle, unicode.IsPunct)
+		if handle != "" {
+			handles = append(handles, handle)
+		}
+	}
+	return handles
+}
+
+// resolveMentions returns the participants content mentions, other than the
+// sender. A participant is mentioned by their phone number or by their
+// display name without spaces, ignoring case.
+func resolveMentions(ctx context.Context, q *storage.Queries, sender storage.ConversationParticipant, content string) ([]pgtype.UUID, error) {
+	handles := mentionHandles(content)
+	if len(handles) == 0 {
+		return nil, nil
+	}
+
+	participants, err := q.ListConversationParticipantsWithDetails(ctx, sender.ConversationID)
+	if err != nil {
+		return nil, storageError(err, "failed to list participants")
+	}
+
+	everyone := roleRank(sender.Role.String) >= roleRank(RoleAdmin)
+	mentioned := make(map[pgtype.UUID]bool)
+	for _, handle := range handles {
+		all := everyone && strings.EqualFold(handle, mentionAll)
+		for _, p := range participants {
+			name := strings.Join(strings.Fields(p.DisplayName.String), "")
+			if all || handle == p.PhoneNumber || (name != "" && strings.EqualFold(handle, name)) {
+				mentioned[p.UserID] = true
+			}
+		}
+	}
+	delete(mentioned, sender.UserID)
+
+	var userIDs []pgtype.UUID
+	for _, p := range participants {
+		if mentioned[p.UserID] {
+			userIDs = append(userIDs, p.UserID)
+		}
+	}
+
+	return userIDs, nil
+}
+
+// replaceMentions resolves the mentions in the new content of an edited
+// message, replaces the ones recorded for it and recounts the unread
+// mentions of everyone who gained or lost one. It returns the message's
+// mentions along with those of them that it did not have before.
+func replaceMentions(ctx context.Context, q *storage.Queries, sender storage.ConversationParticipant, message storage.Message) (mentions, added []pgtype.UUID, err error) {
+	previous, err := q.ListMessageMentions(ctx, []pgtype.UUID{message.ID})
+	if err != nil {
+		return nil, nil, storageError(err, "failed to get mentions")
+	}
+
+	mentions, err = resolveMentions(ctx, q, sender, message.Content.String)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if err := q.DeleteMessageMentions(ctx, message.ID); err != nil {
+		return nil, nil, storageError(err, "failed to delete mentions")
+	}
+	if len(mentions) > 0 {
+		if err := q.CreateMessageMentions(ctx, storage.CreateMessageMentionsParams{
+			MessageID:      message.ID,
+			ConversationID: message.ConversationID,
+			UserIds:        mentions,
+		}); err != nil {
+			return nil, nil, storageError(err, "failed to record mentions")
+		}
+	}
+
+	affected := slices.Clone(mentions)
+	for _, mention := range previous {
+		if !slices.Contains(mentions, mention.UserID) {
+			affected = append(affected, mention.UserID)
+		}
+	}
+	for _, userID := range mentions {
+		if !slices.ContainsFunc(previous, func(m storage.MessageMention) bool { return m.UserID == userID }) {
+			added = append(added, userID)
+		}
+	}
+
+	if len(affected) > 0 {
+		if err := q.RecountUnreadMentions(ctx, storage.RecountUnreadMentionsParams{
+			ConversationID: message.ConversationID,
+			UserIds:        affected,
+		}); err != nil {
+			return nil, nil, storageError(err, "failed to count mentions")
+		}
+	}
+
+	return mentions, added, nil
+}
+
+// attachMentions fills in the mentions of each response, loading them for
+// the whole batch at once. Deleted and hidden messages have none.
+func attachMentions(ctx context.Context, q *storage.Queries, responses []MessageResponse) error {
+	var messageIDs []pgtype.UUID
+	for _, response := range responses {
+		if !response.Deleted && !response.Hidden {
+			messageIDs = append(messageIDs, response.ID)
+		}
+	}
+	if len(messageIDs) == 0 {
+		return nil
+	}
+
+	mentions, err := q.ListMessageMentions(ctx, messageIDs)
+	if err != nil {
+		return storageError(err, "failed to get mentions")
+	}
+
+	byMessage := make(map[pgtype.UUID][]pgtype.UUID)
+	for _, mention := range mentions {
+		byMessage[mention.MessageID] = append(byMessage[mention.MessageID], mention.UserID)
+	}
+	for i := range responses {
+		responses[i].Mentions = byMessage[responses[i].ID]
+	}
+
+	return nil
+}
+
+// messageResponse is newMessageResponse with the message's mentions.
+func (s *MessageService) messageResponse(ctx context.Context, message storage.Message) (*MessageResponse, error) {
+	responses := []MessageResponse{newMessageResponse(message)}
+	if err := attachMentions(ctx, s.queries, responses); err != nil {
+		return nil, err
+	}
+
+	return &responses[0], nil
+}
+
+// GetMentions returns the messages that mention the caller across all the
+// conversations they still participate in.
+func (s *MessageService) GetMentions(ctx context.Context, req ListMentionsRequest) (*MentionPage, error) {
+	actor, err := requireActor(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	if req.Limit <= 0 {
+		req.Limit = 50
+	}
+	if req.Limit > maxPageSize {
+		req.Limit = maxPageSize
+	}
+
+	params := storage.ListUserMentionsParams{
+		UserID: actor.UserID,
+		Limit:  req.Limit + 1,
+	}
+	if req.Before != "" {
+		c, err := decodeCursor(req.Before)
+		if err != nil {
+			return nil, err
+		}
+		params.CreatedAt, params.ID = c.CreatedAt, c.ID
+	}
+
+	// Fetch one extra row to learn whether another page follows
+	messages, err := s.queries.ListUserMentions(ctx, params)
+	if err != nil {
+		return nil, storageError(err, "failed to get mentions")
+	}
+
+	page := &MentionPage{Messages: []MessageResponse{}}
+	if len(messages) > int(req.Limit) {
+		messages = messages[:req.Limit]
+		page.HasMore = true
+	}
+
+	for _, message := range messages {
+		page.Messages = append(page.Messages, newMessageResponse(message))
+	}
+	if err := attachMentions(ctx, s.queries, page.Messages); err != nil {
+		return nil, err
+	}
+
+	if len(messages) > 0 {
+		oldest := messages[len(messages)-1]
+		page.NextCursor = encodeCursor(oldest.CreatedAt, oldest.ID)
+	}
+
+	return page, nil
+}
@@ -2,264 +2,1008 @@ package service
 
 import (
 	"context"
-	"fmt"
+	"encoding/json"
+	"errors"
+	"slices"
+	"time"
 
+	"github.com/felipedavid/chatting/event"
 	"github.com/felipedavid/chatting/storage"
 	"github.com/jackc/pgx/v5"
 	"github.com/jackc/pgx/v5/pgtype"
 )
 
+const (
+	maxPageSize              = 100
+	maxClientMessageIDLength = 128
+	maxForwardTargets        = 5
+
+	// frequentlyForwardedThreshold is the forward count from which clients
+	// label a message as forwarded many times.
+	frequentlyForwardedThreshold = 5
+)
+
+type DeleteMode string
+
+const (
+	DeleteForMe       DeleteMode = "me"
+	DeleteForEveryone DeleteMode = "everyone"
+)
+
 type MessageService struct {
-	queries *storage.Queries
+	queries    *storage.Queries
+	tx         TxRunner
+	events     notifier
+	authz      authorizer
+	editWindow time.Duration
 }
 
-func NewMessageService(queries *storage.Queries) *MessageService {
-	return &MessageService{queries: queries}
+func NewMessageService(queries *storage.Queries, tx TxRunner, publisher event.Publisher, cfg Config) *MessageService {
+	return &MessageService{
+		queries:    queries,
+		tx:         tx,
+		events:     notifier{publisher: publisher},
+		authz:      authorizer{queries: queries},
+		editWindow: cfg.MessageEditWindow,
+	}
 }
 
 type CreateMessageRequest struct {
-	ConversationID pgtype.UUID
-	SenderID       pgtype.UUID
-	Content        string
-	MessageType    string
-	ReplyToID      pgtype.UUID
+	ConversationID pgtype.UUID `json:"conversation_id"`
+	// ClientMessageID is generated by the sending device. Retrying a send
+	// with the same ID returns the message stored the first time.
+	ClientMessageID string `json:"client_message_id"`
+	Content         string `json:"content"`
+	MessageType     string `json:"message_type"`
+	// Payload is the structured part of the message, whose shape depends on
+	// MessageType. Text messages have none.
+	Payload   json.RawMessage `json:"payload"`
+	ReplyToID pgtype.UUID     `json:"reply_to_id"`
+	// ThreadRootID posts the message as a reply in the thread rooted at
+	// that message. Replying within a thread joins the same thread.
+	ThreadRootID pgtype.UUID `json:"thread_root_id"`
+}
+
+type ListMessagesRequest struct {
+	Before string
+	After  string
+	Limit  int32
+}
+
+// MessagePage is one page of a conversation's history. HasMore reports
+// whether further messages exist in the direction that was requested.
+type MessagePage struct {
+	Messages    []MessageResponse `json:"messages"`
+	OlderCursor string            `json:"older_cursor,omitempty"`
+	NewerCursor string            `json:"newer_cursor,omitempty"`
+	HasMore     bool              `json:"has_more"`
 }
 
 type MessageResponse struct {
-	ID             pgtype.UUID
-	ConversationID pgtype.UUID
-	SenderID       pgtype.UUID
-	Content        string
-	MessageType    string
-	ReplyToID      pgtype.UUID
-	CreatedAt      pgtype.Timestamptz
+	ID                  pgtype.UUID        `json:"id"`
+	ConversationID      pgtype.UUID        `json:"conversation_id"`
+	SenderID            pgtype.UUID        `json:"sender_id"`
+	ClientMessageID     string             `json:"client_message_id,omitempty"`
+	Content             string             `json:"content"`
+	MessageType         string             `json:"message_type"`
+	Payload             json.RawMessage    `json:"payload,omitempty"`
+	ReplyToID           pgtype.UUID        `json:"reply_to_id"`
+	Seq                 int64              `json:"seq"`
+	Edited              bool               `json:"edited"`
+	EditedAt            pgtype.Timestamptz `json:"edited_at"`
+	Deleted             bool               `json:"deleted"`
+	DeletedAt           pgtype.Timestamptz `json:"deleted_at"`
+	Forwarded           bool               `json:"forwarded"`
+	ForwardCount        int32              `json:"forward_count"`
+	FrequentlyForwarded bool               `json:"frequently_forwarded"`
+	ThreadRootID        pgtype.UUID        `json:"thread_root_id"`
+	ThreadReplyCount    int32              `json:"thread_reply_count"`
+	ThreadLastReplyAt   pgtype.Timestamptz `json:"thread_last_reply_at"`
+	Mentions            []pgtype.UUID      `json:"mentions,omitempty"`
+	Hidden              bool               `json:"hidden,omitempty"`
+	CreatedAt           pgtype.Timestamptz `json:"created_at"`
+}
+
+func newMessageResponse(message storage.Message) MessageResponse {
+	return MessageResponse{
+		ID:                  message.ID,
+		ConversationID:      message.ConversationID,
+		SenderID:            message.SenderID,
+		ClientMessageID:     message.ClientMessageID.String,
+		Content:             message.Content.String,
+		MessageType:         message.MessageType,
+		Payload:             message.Payload,
+		ReplyToID:           message.ReplyToID,
+		Seq:                 message.Seq,
+		Edited:              message.EditedAt.Valid,
+		EditedAt:            message.EditedAt,
+		Deleted:             message.DeletedAt.Valid,
+		DeletedAt:           message.DeletedAt,
+		Forwarded:           message.ForwardCount > 0,
+		ForwardCount:        message.ForwardCount,
+		FrequentlyForwarded: message.ForwardCount >= frequentlyForwardedThreshold,
+		ThreadRootID:        message.ThreadRootID,
+		ThreadReplyCount:    message.ThreadReplyCount,
+		ThreadLastReplyAt:   message.ThreadLastReplyAt,
+		CreatedAt:           message.CreatedAt,
+	}
+}
+
+// newHiddenMessageResponse stands in for a message the caller deleted for
+// themselves where leaving it out would open a gap in the sequence. It keeps
+// nothing but the message's position.
+func newHiddenMessageResponse(message storage.Message) MessageResponse {
+	return MessageResponse{
+		ID:             message.ID,
+		ConversationID: message.ConversationID,
+		Seq:            message.Seq,
+		Hidden:         true,
+		CreatedAt:      message.CreatedAt,
+	}
+}
+
+// checkReplyTarget rejects replies to messages outside the conversation or
+// that the caller deleted for themselves. An unset target is no reply.
+func checkReplyTarget(ctx context.Context, q *storage.Queries, conversationID, userID, replyToID pgtype.UUID) error {
+	if !replyToID.Valid {
+		return nil
+	}
+
+	target, err := q.GetMessage(ctx, replyToID)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return notFound("reply target not found")
+		}
+		return storageError(err, "failed to get reply target")
+	}
+	if target.ConversationID != conversationID {
+		return invalidArgument("reply target belongs to another conversation")
+	}
+
+	hidden, err := q.ListHiddenMessageIDs(ctx, storage.ListHiddenMessageIDsParams{
+		UserID:     userID,
+		MessageIds: []pgtype.UUID{replyToID},
+	})
+	if err != nil {
+		return storageError(err, "failed to get hidden messages")
+	}
+	if len(hidden) > 0 {
+		return notFound("reply target not found")
+	}
+
+	return nil
 }
 
 func (s *MessageService) CreateMessage(ctx context.Context, req CreateMessageRequest) (*MessageResponse, error) {
-	if !req.ConversationID.Valid {
-		return nil, fmt.Errorf("conversation ID is required")
+	actor, err := requireActor(ctx)
+	if err != nil {
+		return nil, err
 	}
-	if !req.SenderID.Valid {
-		return nil, fmt.Errorf("sender ID is required")
+	if !req.ConversationID.Valid {
+		return nil, invalidArgument("conversation ID is required")
 	}
-	if req.Content == "" {
-		return nil, fmt.Errorf("message content is required")
+	if len(req.ClientMessageID) > maxClientMessageIDLength {
+		return nil, invalidArgument("client message ID must be at most %d characters", maxClientMessageIDLength)
 	}
 	if req.MessageType == "" {
-		req.MessageType = "text"
+		req.MessageType = MessageTypeText
+	}
+	payload, err := validatePayload(req.MessageType, req.Content, req.Payload)
+	if err != nil {
+		return nil, err
 	}
 
-	// Check if sender is a participant in the conversation
-	isParticipant, err := s.queries.IsUserInConversation(ctx, storage.IsUserInConversationParams{
-		ConversationID: req.ConversationID,
-		UserID:         req.SenderID,
-	})
+	participant, err := s.authz.requireParticipant(ctx, req.ConversationID, actor.UserID)
 	if err != nil {
-		return nil, fmt.Errorf("failed to check if user is participant: %w", err)
+		return nil, err
 	}
-	if !isParticipant {
-		return nil, fmt.Errorf("sender is not a participant in this conversation")
+
+	if req.ClientMessageID != "" {
+		existing, err := s.findClientMessage(ctx, actor.DeviceID, req)
+		if err != nil || existing != nil {
+			return existing, err
+		}
 	}
 
 	params := storage.CreateMessageParams{
-		ConversationID: req.ConversationID,
-		SenderID:       req.SenderID,
-		Content:        pgtype.Text{String: req.Content, Valid: true},
-		MessageType:    req.MessageType,
-		ReplyToID:      req.ReplyToID,
+		ConversationID:  req.ConversationID,
+		SenderID:        actor.UserID,
+		Content:         pgtype.Text{String: req.Content, Valid: req.Content != ""},
+		MessageType:     req.MessageType,
+		Payload:         payload,
+		ReplyToID:       req.ReplyToID,
+		SenderDeviceID:  actor.DeviceID,
+		ClientMessageID: pgtype.Text{String: req.ClientMessageID, Valid: req.ClientMessageID != ""},
 	}
 
-	message, err := s.queries.CreateMessage(ctx, params)
+	mentions, err := resolveMentions(ctx, s.queries, participant, req.Content)
 	if err != nil {
-		return nil, fmt.Errorf("failed to create message: %w", err)
+		return nil, err
 	}
 
-	return &MessageResponse{
-		ID:             message.ID,
-		ConversationID: message.ConversationID,
-		SenderID:       message.SenderID,
-		Content:        message.Content.String,
-		MessageType:    message.MessageType,
-		ReplyToID:      message.ReplyToID,
-		CreatedAt:      message.CreatedAt,
-	}, nil
+	var message, root storage.Message
+	var events []event.Event
+	err = s.tx.InTx(ctx, func(q *storage.Queries) error {
+		events = nil
+		root = storage.Message{}
+
+		if err := checkReplyTarget(ctx, q, req.ConversationID, actor.UserID, req.ReplyToID); err != nil {
+			return err
+		}
+
+		var err error
+		if req.ThreadRootID.Valid {
+			message, root, err = createThreadReply(ctx, q, params, req.ThreadRootID)
+			if err != nil {
+				return err
+			}
+		} else {
+			message, err = q.CreateMessage(ctx, params)
+			if err != nil {
+				return storageError(err, "failed to create message")
+			}
+		}
+
+		if isMediaType(message.MessageType) {
+			if err := recordMedia(ctx, q, message); err != nil {
+				return err
+			}
+		}
+
+		if message.MessageType == MessageTypePoll {
+			if err := createPoll(ctx, q, message); err != nil {
+				return err
+			}
+		}
+
+		if len(mentions) > 0 {
+			if err := q.CreateMessageMentions(ctx, storage.CreateMessageMentionsParams{
+				MessageID:      message.ID,
+				ConversationID: message.ConversationID,
+				UserIds:        mentions,
+			}); err != nil {
+				return storageError(err, "failed to record mentions")
+			}
+			if err := q.IncrementUnreadMentions(ctx, storage.IncrementUnreadMentionsParams{
+				ConversationID: message.ConversationID,
+				UserIds:        mentions,
+			}); err != nil {
+				return storageError(err, "failed to count mentions")
+			}
+		}
+
+		response := newMessageResponse(message)
+		response.Mentions = mentions
+
+		created, err := s.events.record(ctx, q, event.MessageCreated, message.ConversationID, response)
+		if err != nil {
+			return err
+		}
+		events = append(events, created)
+
+		if root.ID.Valid {
+			updated, err := s.events.record(ctx, q, event.ThreadUpdated, root.ConversationID, newThreadSummaryResponse(root))
+			if err != nil {
+				return err
+			}
+			events = append(events, updated)
+		}
+
+		// Sending a message means the sender has caught up with the
+		// conversation
+		read, err := readUpTo(ctx, q, s.events, message.ConversationID, message.SenderID, message.Seq)
+		if err != nil {
+			return err
+		}
+		events = append(events, read...)
+
+		return nil
+	})
+	if err != nil {
+		if req.ClientMessageID != "" && errors.Is(err, ErrAlreadyExists) {
+			// A concurrent retry of the same send won the race
+			existing, findErr := s.findClientMessage(ctx, actor.DeviceID, req)
+			if findErr != nil || existing != nil {
+				return existing, findErr
+			}
+		}
+		return nil, err
+	}
+
+	response := newMessageResponse(message)
+	response.Mentions = mentions
+
+	s.events.publish(ctx, events...)
+	s.events.notify(ctx, mentions, event.Mentioned, response.ConversationID, response)
+	if root.ID.Valid {
+		s.notifyThreadFollowers(ctx, root, response)
+	}
+
+	return &response, nil
+}
+
+// ForwardMessage copies a message, including its media, into each of the
+// target conversations on behalf of the caller, who must participate in the
+// source conversation and in every target.
+func (s *MessageService) ForwardMessage(ctx context.Context, messageID pgtype.UUID, conversationIDs []pgtype.UUID) ([]MessageResponse, error) {
+	actor, err := requireActor(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if !messageID.Valid {
+		return nil, invalidArgument("message ID is required")
+	}
+
+	var targets []pgtype.UUID
+	for _, id := range conversationIDs {
+		if !id.Valid {
+			return nil, invalidArgument("conversation IDs must be valid")
+		}
+		if !slices.Contains(targets, id) {
+			targets = append(targets, id)
+		}
+	}
+	if len(targets) == 0 {
+		return nil, invalidArgument("at least one target conversation is required")
+	}
+	if len(targets) > maxForwardTargets {
+		return nil, invalidArgument("a message can be forwarded to at most %d conversations at once", maxForwardTargets)
+	}
+
+	var forwarded []storage.Message
+	var events []event.Event
+	err = s.tx.InTx(ctx, func(q *storage.Queries) error {
+		forwarded = nil
+		events = nil
+		authz := authorizer{queries: q}
+
+		source, _, err := authz.requireMessageAccess(ctx, messageID, actor.UserID)
+		if err != nil {
+			return err
+		}
+		if source.DeletedAt.Valid {
+			return conflict("message has been deleted")
+		}
+		if source.MessageType == MessageTypePoll || source.MessageType == MessageTypeSystem {
+			return invalidArgument("%s messages cannot be forwarded", source.MessageType)
+		}
+
+		for _, conversationID := range targets {
+			if _, err := authz.requireParticipant(ctx, conversationID, actor.UserID); err != nil {
+				return err
+			}
+
+			message, err := q.CreateMessage(ctx, storage.CreateMessageParams{
+				ConversationID: conversationID,
+				SenderID:       actor.UserID,
+				Content:        source.Content,
+				MessageType:    source.MessageType,
+				Payload:        source.Payload,
+				SenderDeviceID: actor.DeviceID,
+				ForwardCount:   source.ForwardCount + 1,
+			})
+			if err != nil {
+				return storageError(err, "failed to forward message")
+			}
+
+			if err := q.CopyMessageMedia(ctx, storage.CopyMessageMediaParams{
+				TargetMessageID: message.ID,
+				SourceMessageID: source.ID,
+			}); err != nil {
+				return storageError(err, "failed to copy message media")
+			}
+
+			created, err := s.events.record(ctx, q, event.MessageCreated, conversationID, newMessageResponse(message))
+			if err != nil {
+				return err
+			}
+
+			read, err := readUpTo(ctx, q, s.events, message.ConversationID, message.SenderID, message.Seq)
+			if err != nil {
+				return err
+			}
+
+			forwarded = append(forwarded, message)
+			events = append(events, created)
+			events = append(events, read...)
+		}
+
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	s.events.publish(ctx, events...)
+
+	responses := []MessageResponse{}
+	for _, message := range forwarded {
+		responses = append(responses, newMessageResponse(message))
+	}
+
+	return responses, nil
+}
+
+// findClientMessage returns the message a device already stored under
+// req.ClientMessageID, or nil if there is none.
+func (s *MessageService) findClientMessage(ctx context.Context, deviceID pgtype.UUID, req CreateMessageRequest) (*MessageResponse, error) {
+	message, err := s.queries.GetMessageByClientMessageID(ctx, storage.GetMessageByClientMessageIDParams{
+		SenderDeviceID:  deviceID,
+		ClientMessageID: pgtype.Text{String: req.ClientMessageID, Valid: true},
+	})
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, nil
+		}
+		return nil, storageError(err, "failed to get message by client message ID")
+	}
+	if message.ConversationID != req.ConversationID {
+		return nil, conflict("client message ID was already used in another conversation")
+	}
+
+	return s.messageResponse(ctx, message)
 }
 
 func (s *MessageService) GetMessage(ctx context.Context, messageID pgtype.UUID) (*MessageResponse, error) {
+	actor, err := requireActor(ctx)
+	if err != nil {
+		return nil, err
+	}
 	if !messageID.Valid {
-		return nil, fmt.Errorf("message ID is required")
+		return nil, invalidArgument("message ID is required")
 	}
 
-	message, err := s.queries.GetMessage(ctx, messageID)
+	message, _, err := s.authz.requireMessageAccess(ctx, messageID, actor.UserID)
 	if err != nil {
-		if err == pgx.ErrNoRows {
-			return nil, fmt.Errorf("message not found")
+		return nil, err
+	}
+
+	return s.messageResponse(ctx, message)
+}
+
+// GetConversationMessages returns a page of messages, newest first. Without a
+// cursor it starts at the most recent message; Before pages towards older
+// messages and After towards newer ones.
+func (s *MessageService) GetConversationMessages(ctx context.Context, conversationID pgtype.UUID, req ListMessagesRequest) (*MessagePage, error) {
+	actor, err := requireActor(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if !conversationID.Valid {
+		return nil, invalidArgument("conversation ID is required")
+	}
+	if req.Before != "" && req.After != "" {
+		return nil, invalidArgument("only one of before and after may be set")
+	}
+
+	if _, err := s.authz.requireParticipant(ctx, conversationID, actor.UserID); err != nil {
+		return nil, err
+	}
+
+	if req.Limit <= 0 {
+		req.Limit = 50
+	}
+	if req.Limit > maxPageSize {
+		req.Limit = maxPageSize
+	}
+
+	// Fetch one extra row to learn whether another page follows
+	var messages []storage.Message
+	switch {
+	case req.Before != "":
+		var c cursor
+		if c, err = decodeCursor(req.Before); err != nil {
+			return nil, err
+		}
+		messages, err = s.queries.GetMessagesBeforeTimestamp(ctx, storage.GetMessagesBeforeTimestampParams{
+			ConversationID: conversationID,
+			CreatedAt:      c.CreatedAt,
+			ID:             c.ID,
+			UserID:         actor.UserID,
+			Limit:          req.Limit + 1,
+		})
+	case req.After != "":
+		var c cursor
+		if c, err = decodeCursor(req.After); err != nil {
+			return nil, err
 		}
-		return nil, fmt.Errorf("failed to get message: %w", err)
+		messages, err = s.queries.GetMessagesAfterTimestamp(ctx, storage.GetMessagesAfterTimestampParams{
+			ConversationID: conversationID,
+			CreatedAt:      c.CreatedAt,
+			ID:             c.ID,
+			UserID:         actor.UserID,
+			Limit:          req.Limit + 1,
+		})
+	default:
+		messages, err = s.queries.ListLatestConversationMessages(ctx, storage.ListLatestConversationMessagesParams{
+			ConversationID: conversationID,
+			UserID:         actor.UserID,
+			Limit:          req.Limit + 1,
+		})
+	}
+	if err != nil {
+		return nil, storageError(err, "failed to get conversation messages")
 	}
 
-	return &MessageResponse{
-		ID:             message.ID,
-		ConversationID: message.ConversationID,
-		SenderID:       message.SenderID,
-		Content:        message.Content.String,
-		MessageType:    message.MessageType,
-		ReplyToID:      message.ReplyToID,
-		CreatedAt:      message.CreatedAt,
-	}, nil
+	page := &MessagePage{Messages: []MessageResponse{}}
+	if len(messages) > int(req.Limit) {
+		messages = messages[:req.Limit]
+		page.HasMore = true
+	}
+
+	// Newer pages come back oldest first; present every page newest first
+	if req.After != "" {
+		slices.Reverse(messages)
+	}
+
+	for _, message := range messages {
+		page.Messages = append(page.Messages, newMessageResponse(message))
+	}
+	if err := attachMentions(ctx, s.queries, page.Messages); err != nil {
+		return nil, err
+	}
+
+	if len(messages) > 0 {
+		newest, oldest := messages[0], messages[len(messages)-1]
+		page.NewerCursor = encodeCursor(newest.CreatedAt, newest.ID)
+		page.OlderCursor = encodeCursor(oldest.CreatedAt, oldest.ID)
+	}
+
+	return page, nil
 }
 
-func (s *MessageService) GetConversationMessages(ctx context.Context, conversationID pgtype.UUID, limit int32, offset int32) ([]MessageResponse, error) {
+// GetMessagesAfterSeq returns up to limit messages with a sequence number
+// greater than seq, oldest first. Clients use it to fill gaps they detect in
+// the sequence; a short page means they have caught up. Messages the caller
+// deleted for themselves come back as hidden placeholders.
+func (s *MessageService) GetMessagesAfterSeq(ctx context.Context, conversationID pgtype.UUID, seq int64, limit int32) ([]MessageResponse, error) {
+	actor, err := requireActor(ctx)
+	if err != nil {
+		return nil, err
+	}
 	if !conversationID.Valid {
-		return nil, fmt.Errorf("conversation ID is required")
+		return nil, invalidArgument("conversation ID is required")
+	}
+	if seq < 0 {
+		return nil, invalidArgument("seq must not be negative")
+	}
+
+	if _, err := s.authz.requireParticipant(ctx, conversationID, actor.UserID); err != nil {
+		return nil, err
 	}
 
 	if limit <= 0 {
 		limit = 50
 	}
+	if limit > maxPageSize {
+		limit = maxPageSize
+	}
 
-	messages, err := s.queries.ListConversationMessagesPaginated(ctx, storage.ListConversationMessagesPaginatedParams{
+	messages, err := s.queries.ListMessagesAfterSeq(ctx, storage.ListMessagesAfterSeqParams{
 		ConversationID: conversationID,
+		Seq:            seq,
 		Limit:          limit,
-		Offset:         offset,
 	})
 	if err != nil {
-		return nil, fmt.Errorf("failed to get conversation messages: %w", err)
+		return nil, storageError(err, "failed to get messages after seq")
 	}
 
-	var responses []MessageResponse
+	messageIDs := make([]pgtype.UUID, 0, len(messages))
 	for _, message := range messages {
-		responses = append(responses, MessageResponse{
-			ID:             message.ID,
-			ConversationID: message.ConversationID,
-			SenderID:       message.SenderID,
-			Content:        message.Content.String,
-			MessageType:    message.MessageType,
-			ReplyToID:      message.ReplyToID,
-			CreatedAt:      message.CreatedAt,
-		})
+		messageIDs = append(messageIDs, message.ID)
+	}
+
+	hidden, err := s.queries.ListHiddenMessageIDs(ctx, storage.ListHiddenMessageIDsParams{
+		UserID:     actor.UserID,
+		MessageIds: messageIDs,
+	})
+	if err != nil {
+		return nil, storageError(err, "failed to get hidden messages")
+	}
+
+	responses := []MessageResponse{}
+	for _, message := range messages {
+		if slices.Contains(hidden, message.ID) {
+			responses = append(responses, newHiddenMessageResponse(message))
+		} else {
+			responses = append(responses, newMessageResponse(message))
+		}
+	}
+	if err := attachMentions(ctx, s.queries, responses); err != nil {
+		return nil, err
 	}
 
 	return responses, nil
 }
 
 func (s *MessageService) GetLatestConversationMessage(ctx context.Context, conversationID pgtype.UUID) (*MessageResponse, error) {
+	actor, err := requireActor(ctx)
+	if err != nil {
+		return nil, err
+	}
 	if !conversationID.Valid {
-		return nil, fmt.Errorf("conversation ID is required")
+		return nil, invalidArgument("conversation ID is required")
+	}
+
+	if _, err := s.authz.requireParticipant(ctx, conversationID, actor.UserID); err != nil {
+		return nil, err
 	}
 
-	message, err := s.queries.GetLatestConversationMessage(ctx, conversationID)
+	message, err := s.queries.GetLatestConversationMessage(ctx, storage.GetLatestConversationMessageParams{
+		ConversationID: conversationID,
+		UserID:         actor.UserID,
+	})
 	if err != nil {
-		if err == pgx.ErrNoRows {
-			return nil, fmt.Errorf("no messages found in conversation")
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, notFound("no messages found in conversation")
 		}
-		return nil, fmt.Errorf("failed to get latest message: %w", err)
+		return nil, storageError(err, "failed to get latest message")
 	}
 
-	return &MessageResponse{
-		ID:             message.ID,
-		ConversationID: message.ConversationID,
-		SenderID:       message.SenderID,
-		Content:        message.Content.String,
-		MessageType:    message.MessageType,
-		ReplyToID:      message.ReplyToID,
-		CreatedAt:      message.CreatedAt,
-	}, nil
+	return s.messageResponse(ctx, message)
+}
+
+// EditMessage replaces the text of one of the caller's text messages, or the
+// caption of a media message, keeping the previous version in the message's
+// edit history. A caption can be cleared; other types cannot be edited.
+// Messages can only be edited within the configured window after they were
+// sent.
+func (s *MessageService) EditMessage(ctx context.Context, messageID pgtype.UUID, content string) (*MessageResponse, error) {
+	actor, err := requireActor(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if !messageID.Valid {
+		return nil, invalidArgument("message ID is required")
+	}
+
+	var response MessageResponse
+	var edited event.Event
+	var mentioned []pgtype.UUID
+	err = s.tx.InTx(ctx, func(q *storage.Queries) error {
+		edited = event.Event{}
+		mentioned = nil
+		authz := authorizer{queries: q}
+		current, participant, err := authz.requireMessageAccess(ctx, messageID, actor.UserID)
+		if err != nil {
+			return err
+		}
+		if current.SenderID != actor.UserID {
+			return permissionDenied("only the sender can edit a message")
+		}
+		if current.DeletedAt.Valid {
+			return conflict("message has been deleted")
+		}
+		if s.editWindow > 0 && time.Since(current.CreatedAt.Time) > s.editWindow {
+			return permissionDenied("messages can only be edited within %s of being sent", s.editWindow)
+		}
+		switch {
+		case current.MessageType == MessageTypeText:
+			if _, err := validatePayload(MessageTypeText, content, nil); err != nil {
+				return err
+			}
+		case isMediaType(current.MessageType):
+			// Media keep their attachment; only the caption changes
+		default:
+			return invalidArgument("%s messages cannot be edited", current.MessageType)
+		}
+		if current.Content.String == content {
+			responses := []MessageResponse{newMessageResponse(current)}
+			if err := attachMentions(ctx, q, responses); err != nil {
+				return err
+			}
+			response = responses[0]
+			return nil
+		}
+
+		if _, err := q.CreateMessageEdit(ctx, storage.CreateMessageEditParams{
+			MessageID:       current.ID,
+			PreviousContent: current.Content,
+		}); err != nil {
+			return storageError(err, "failed to record message edit")
+		}
+
+		message, err := q.UpdateMessageContent(ctx, storage.UpdateMessageContentParams{
+			ID:      current.ID,
+			Content: pgtype.Text{String: content, Valid: content != ""},
+		})
+		if err != nil {
+			return storageError(err, "failed to update message content")
+		}
+
+		// The new content may mention different participants
+		mentions, added, err := replaceMentions(ctx, q, participant, message)
+		if err != nil {
+			return err
+		}
+		mentioned = added
+
+		response = newMessageResponse(message)
+		response.Mentions = mentions
+
+		edited, err = s.events.record(ctx, q, event.MessageEdited, message.ConversationID, response)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if edited.Type != "" {
+		s.events.publish(ctx, edited)
+		s.events.notify(ctx, mentioned, event.Mentioned, response.ConversationID, response)
+	}
+
+	return &response, nil
 }
 
-func (s *MessageService) DeleteMessage(ctx context.Context, messageID pgtype.UUID) error {
+// GetMessageEdits returns the previous versions of a message, oldest first.
+func (s *MessageService) GetMessageEdits(ctx context.Context, messageID pgtype.UUID) ([]MessageEditResponse, error) {
+	actor, err := requireActor(ctx)
+	if err != nil {
+		return nil, err
+	}
 	if !messageID.Valid {
-		return fmt.Errorf("message ID is required")
+		return nil, invalidArgument("message ID is required")
 	}
 
-	if err := s.queries.DeleteMessage(ctx, messageID); err != nil {
-		return fmt.Errorf("failed to delete message: %w", err)
+	if _, _, err := s.authz.requireMessageAccess(ctx, messageID, actor.UserID); err != nil {
+		return nil, err
+	}
+
+	edits, err := s.queries.ListMessageEdits(ctx, messageID)
+	if err != nil {
+		return nil, storageError(err, "failed to get message edits")
+	}
+
+	responses := []MessageEditResponse{}
+	for _, edit := range edits {
+		responses = append(responses, MessageEditResponse{
+			MessageID:       edit.MessageID,
+			PreviousContent: edit.PreviousContent.String,
+			EditedAt:        edit.EditedAt,
+		})
+	}
+
+	return responses, nil
+}
+
+// DeleteMessage removes a message. DeleteForMe hides it from the caller
+// only. DeleteForEveryone, allowed for the sender and for admins and the
+// owner, replaces it with a tombstone: the row keeps its place in history and
+// the replies pointing at it, but its content, edit history and media are
+// wiped.
+func (s *MessageService) DeleteMessage(ctx context.Context, messageID pgtype.UUID, mode DeleteMode) error {
+	actor, err := requireActor(ctx)
+	if err != nil {
+		return err
+	}
+	if !messageID.Valid {
+		return invalidArgument("message ID is required")
+	}
+
+	switch mode {
+	case DeleteForMe:
+		// Only the caller's other devices learn the message is gone
+		var hidden event.Event
+		err := s.tx.InTx(ctx, func(q *storage.Queries) error {
+			authz := authorizer{queries: q}
+			message, _, err := authz.requireMessageAccess(ctx, messageID, actor.UserID)
+			if err != nil {
+				return err
+			}
+
+			if err := q.HideMessage(ctx, storage.HideMessageParams{
+				UserID:    actor.UserID,
+				MessageID: messageID,
+			}); err != nil {
+				return storageError(err, "failed to hide message")
+			}
+
+			hidden, err = s.events.recordPrivate(ctx, q, []pgtype.UUID{actor.UserID}, event.MessageHidden, message.ConversationID, newHiddenMessageResponse(message))
+			return err
+		})
+		if err != nil {
+			return err
+		}
+
+		s.events.publish(ctx, hidden)
+
+		return nil
+	case DeleteForEveryone:
+	default:
+		return invalidArgument("delete mode must be %q or %q", DeleteForMe, DeleteForEveryone)
+	}
+
+	var deleted event.Event
+	err = s.tx.InTx(ctx, func(q *storage.Queries) error {
+		deleted = event.Event{}
+		authz := authorizer{queries: q}
+		current, participant, err := authz.requireMessageAccess(ctx, messageID, actor.UserID)
+		if err != nil {
+			return err
+		}
+		if current.SenderID != actor.UserID && roleRank(participant.Role.String) < roleRank(RoleAdmin) {
+			return permissionDenied("cannot delete another participant's message")
+		}
+		if current.DeletedAt.Valid {
+			return nil
+		}
+
+		if err := q.DeleteMediaByMessage(ctx, messageID); err != nil {
+			return storageError(err, "failed to delete message media")
+		}
+
+		if err := q.DeleteMessageEdits(ctx, messageID); err != nil {
+			return storageError(err, "failed to delete message edits")
+		}
+
+		message, err := q.TombstoneMessage(ctx, messageID)
+		if err != nil {
+			return storageError(err, "failed to delete message")
+		}
+
+		deleted, err = s.events.record(ctx, q, event.MessageDeleted, message.ConversationID, newMessageResponse(message))
+		return err
+	})
+	if err != nil {
+		return err
+	}
+
+	if deleted.Type != "" {
+		s.events.publish(ctx, deleted)
 	}
 
 	return nil
 }
 
 func (s *MessageService) CountConversationMessages(ctx context.Context, conversationID pgtype.UUID) (int64, error) {
+	actor, err := requireActor(ctx)
+	if err != nil {
+		return 0, err
+	}
 	if !conversationID.Valid {
-		return 0, fmt.Errorf("conversation ID is required")
+		return 0, invalidArgument("conversation ID is required")
+	}
+
+	if _, err := s.authz.requireParticipant(ctx, conversationID, actor.UserID); err != nil {
+		return 0, err
 	}
 
 	count, err := s.queries.CountConversationMessages(ctx, conversationID)
 	if err != nil {
-		return 0, fmt.Errorf("failed to count conversation messages: %w", err)
+		return 0, storageError(err, "failed to count conversation messages")
 	}
 
 	return count, nil
 }
 
-func (s *MessageService) AddReaction(ctx context.Context, messageID, userID pgtype.UUID, reaction string) error {
-	if !messageID.Valid || !userID.Valid {
-		return fmt.Errorf("message ID and user ID are required")
+func (s *MessageService) AddReaction(ctx context.Context, messageID pgtype.UUID, reaction string) error {
+	actor, err := requireActor(ctx)
+	if err != nil {
+		return err
+	}
+	if !messageID.Valid {
+		return invalidArgument("message ID is required")
 	}
 	if reaction == "" {
-		return fmt.Errorf("reaction is required")
+		return invalidArgument("reaction is required")
 	}
 
-	// Check if user has already reacted
-	hasReacted, err := s.queries.HasUserReactedToMessage(ctx, storage.HasUserReactedToMessageParams{
-		MessageID: messageID,
-		UserID:    userID,
-	})
+	message, _, err := s.authz.requireMessageAccess(ctx, messageID, actor.UserID)
 	if err != nil {
-		return fmt.Errorf("failed to check existing reaction: %w", err)
+		return err
+	}
+	if message.DeletedAt.Valid {
+		return conflict("message has been deleted")
 	}
 
-	if hasReacted {
-		// Update existing reaction
-		_, err := s.queries.UpdateMessageReaction(ctx, storage.UpdateMessageReactionParams{
+	var added event.Event
+	err = s.tx.InTx(ctx, func(q *storage.Queries) error {
+		// Check if user has already reacted
+		hasReacted, err := q.HasUserReactedToMessage(ctx, storage.HasUserReactedToMessageParams{
 			MessageID: messageID,
-			UserID:    userID,
-			Reaction:  reaction,
+			UserID:    actor.UserID,
 		})
 		if err != nil {
-			return fmt.Errorf("failed to update reaction: %w", err)
+			return storageError(err, "failed to check existing reaction")
 		}
 
-		return nil
-	}
+		var stored storage.MessageReaction
+		if hasReacted {
+			// Update existing reaction
+			stored, err = q.UpdateMessageReaction(ctx, storage.UpdateMessageReactionParams{
+				MessageID: messageID,
+				UserID:    actor.UserID,
+				Reaction:  reaction,
+			})
+			if err != nil {
+				return storageError(err, "failed to update reaction")
+			}
+		} else {
+			// Add new reaction
+			params := storage.AddMessageReactionParams{
+				MessageID: messageID,
+				UserID:    actor.UserID,
+				Reaction:  reaction,
+			}
 
-	// Add new reaction
-	params := storage.AddMessageReactionParams{
-		MessageID: messageID,
-		UserID:    userID,
-		Reaction:  reaction,
-	}
+			stored, err = q.AddMessageReaction(ctx, params)
+			if err != nil {
+				return storageError(err, "failed to add reaction")
+			}
+		}
 
-	if _, err := s.queries.AddMessageReaction(ctx, params); err != nil {
-		return fmt.Errorf("failed to add reaction: %w", err)
+		added, err = s.events.record(ctx, q, event.ReactionAdded, message.ConversationID, ReactionEventResponse{
+			MessageID: stored.MessageID,
+			UserID:    stored.UserID,
+			Reaction:  stored.Reaction,
+			ReactedAt: stored.ReactedAt,
+		})
+		return err
+	})
+	if err != nil {
+		return err
 	}
 
+	s.events.publish(ctx, added)
+
 	return nil
 }
 
-func (s *MessageService) RemoveReaction(ctx context.Context, messageID, userID pgtype.UUID) error {
-	if !messageID.Valid || !userID.Valid {
-		return fmt.Errorf("message ID and user ID are required")
+func (s *MessageService) RemoveReaction(ctx context.Context, messageID pgtype.UUID) error {
+	actor, err := requireActor(ctx)
+	if err != nil {
+		return err
+	}
+	if !messageID.Valid {
+		return invalidArgument("message ID is required")
+	}
+
+	message, _, err := s.authz.requireMessageAccess(ctx, messageID, actor.UserID)
+	if err != nil {
+		return err
 	}
 
-	if err := s.queries.RemoveMessageReaction(ctx, storage.RemoveMessageReactionParams{
-		MessageID: messageID,
-		UserID:    userID,
-	}); err != nil {
-		return fmt.Errorf("failed to remove reaction: %w", err)
+	var removed event.Event
+	err = s.tx.InTx(ctx, func(q *storage.Queries) error {
+		if err := q.RemoveMessageReaction(ctx, storage.RemoveMessageReactionParams{
+			MessageID: messageID,
+			UserID:    actor.UserID,
+		}); err != nil {
+			return storageError(err, "failed to remove reaction")
+		}
+
+		removed, err = s.events.record(ctx, q, event.ReactionRemoved, message.ConversationID, ReactionEventResponse{
+			MessageID: messageID,
+			UserID:    actor.UserID,
+		})
+		return err
+	})
+	if err != nil {
+		return err
 	}
 
+	s.events.publish(ctx, removed)
+
 	return nil
 }
 
 func (s *MessageService) GetMessageReactions(ctx context.Context, messageID pgtype.UUID) ([]MessageReactionResponse, error) {
+	actor, err := requireActor(ctx)
+	if err != nil {
+		return nil, err
+	}
 	if !messageID.Valid {
-		return nil, fmt.Errorf("message ID is required")
+		return nil, invalidArgument("message ID is required")
+	}
+
+	if _, _, err := s.authz.requireMessageAccess(ctx, messageID, actor.UserID); err != nil {
+		return nil, err
 	}
 
 	reactions, err := s.queries.ListMessageReactionsWithDetails(ctx, messageID)
 	if err != nil {
-		return nil, fmt.Errorf("failed to get message reactions: %w", err)
+		return nil, storageError(err, "failed to get message reactions")
 	}
 
 	var responses []MessageReactionResponse
@@ -278,10 +1022,25 @@ func (s *MessageService) GetMessageReactions(ctx context.Context, messageID pgty
 }
 
 type MessageReactionResponse struct {
-	MessageID   pgtype.UUID
-	UserID      pgtype.UUID
-	Reaction    string
-	ReactedAt   pgtype.Timestamptz
-	PhoneNumber string
-	DisplayName string
+	MessageID   pgtype.UUID        `json:"message_id"`
+	UserID      pgtype.UUID        `json:"user_id"`
+	Reaction    string             `json:"reaction"`
+	ReactedAt   pgtype.Timestamptz `json:"reacted_at"`
+	PhoneNumber string             `json:"phone_number"`
+	DisplayName string             `json:"display_name"`
+}
+
+type ReactionEventResponse struct {
+	MessageID pgtype.UUID        `json:"message_id"`
+	UserID    pgtype.UUID        `json:"user_id"`
+	Reaction  string             `json:"reaction,omitempty"`
+	ReactedAt pgtype.Timestamptz `json:"reacted_at"`
+}
+
+// MessageEditResponse is a version of a message that was replaced at
+// EditedAt.
+type MessageEditResponse struct {
+	MessageID       pgtype.UUID        `json:"message_id"`
+	PreviousContent string             `json:"previous_content"`
+	EditedAt        pgtype.Timestamptz `json:"edited_at"`
 }
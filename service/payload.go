@@ -0,0 +1,243 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"strings"
+	"time"
+
+	"github.com/felipedavid/chatting/storage"
+	"github.com/jackc/pgx/v5/pgtype"
+)
+
+const (
+	MessageTypeText     = "text"
+	MessageTypeImage    = "image"
+	MessageTypeVideo    = "video"
+	MessageTypeAudio    = "audio"
+	MessageTypeDocument = "document"
+	MessageTypeLocation = "location"
+	MessageTypeContact  = "contact"
+	MessageTypePoll     = "poll"
+	MessageTypeSticker  = "sticker"
+	MessageTypeSystem   = "system"
+)
+
+const (
+	minPollOptions = 2
+	maxPollOptions = 12
+)
+
+// MediaPayload describes the attachment of an image, video, audio or
+// document message. The message content, if any, is its caption.
+type MediaPayload struct {
+	URL        string `json:"url"`
+	MimeType   string `json:"mime_type"`
+	Size       int64  `json:"size,omitempty"`
+	FileName   string `json:"file_name,omitempty"`
+	Width      int32  `json:"width,omitempty"`
+	Height     int32  `json:"height,omitempty"`
+	DurationMS int64  `json:"duration_ms,omitempty"`
+}
+
+type LocationPayload struct {
+	Latitude  float64 `json:"latitude"`
+	Longitude float64 `json:"longitude"`
+	Name      string  `json:"name,omitempty"`
+	Address   string  `json:"address,omitempty"`
+}
+
+// ContactPayload is a shared contact card. UserID is set when the contact
+// has an account.
+type ContactPayload struct {
+	DisplayName  string      `json:"display_name"`
+	PhoneNumbers []string    `json:"phone_numbers"`
+	UserID       pgtype.UUID `json:"user_id"`
+}
+
+// PollPayload is a poll's question and options. Votes refer to options by
+// their index. A poll with ClosesAt stops accepting votes at that time.
+type PollPayload struct {
+	Question       string     `json:"question"`
+	Options        []string   `json:"options"`
+	MultipleChoice bool       `json:"multiple_choice"`
+	ClosesAt       *time.Time `json:"closes_at,omitempty"`
+}
+
+type StickerPayload struct {
+	PackID    string `json:"pack_id"`
+	StickerID string `json:"sticker_id"`
+	URL       string `json:"url,omitempty"`
+}
+
+// Actions announced by system messages.
+const (
+	SystemParticipantAdded   = "participant_added"
+	SystemParticipantRemoved = "participant_removed"
+	SystemParticipantLeft    = "participant_left"
+)
+
+// SystemPayload describes a change announced in the conversation, such as
+// a participant joining. ActorID made the change and UserID is the user it
+// concerns. System messages are only created by the server and have no
+// sender.
+type SystemPayload struct {
+	Action  string      `json:"action"`
+	ActorID pgtype.UUID `json:"actor_id"`
+	UserID  pgtype.UUID `json:"user_id"`
+}
+
+// isMediaType reports whether messages of the given type carry an
+// attachment that is also recorded in the media table.
+func isMediaType(messageType string) bool {
+	switch messageType {
+	case MessageTypeImage, MessageTypeVideo, MessageTypeAudio, MessageTypeDocument:
+		return true
+	}
+	return false
+}
+
+// recordMedia adds the attachment described by a media message's payload to
+// the media table, so it is copied and deleted along with the message.
+func recordMedia(ctx context.Context, q *storage.Queries, message storage.Message) error {
+	var p MediaPayload
+	if err := json.Unmarshal(message.Payload, &p); err != nil {
+		return invalidArgument("invalid payload: %v", err)
+	}
+
+	if _, err := q.CreateMedia(ctx, storage.CreateMediaParams{
+		MessageID: message.ID,
+		FileUrl:   p.URL,
+		MimeType:  p.MimeType,
+		FileSize:  pgtype.Int8{Int64: p.Size, Valid: p.Size > 0},
+	}); err != nil {
+		return storageError(err, "failed to record message media")
+	}
+
+	return nil
+}
+
+// validatePayload checks that content and payload are valid for a message
+// of the given type and returns the payload re-encoded in canonical form,
+// or nil for types that have none.
+func validatePayload(messageType, content string, payload json.RawMessage) (json.RawMessage, error) {
+	switch messageType {
+	case MessageTypeText:
+		if content == "" {
+			return nil, invalidArgument("message content is required")
+		}
+		if len(payload) > 0 && string(payload) != "null" {
+			return nil, invalidArgument("text messages do not take a payload")
+		}
+		return nil, nil
+	case MessageTypeSystem:
+		return nil, permissionDenied("system messages cannot be sent")
+	}
+
+	switch messageType {
+	case MessageTypeImage, MessageTypeVideo, MessageTypeAudio, MessageTypeDocument:
+		var p MediaPayload
+		if err := decodePayload(payload, &p); err != nil {
+			return nil, err
+		}
+		if p.URL == "" {
+			return nil, invalidArgument("media url is required")
+		}
+		if p.MimeType == "" {
+			return nil, invalidArgument("media mime type is required")
+		}
+		if p.Size < 0 || p.Width < 0 || p.Height < 0 || p.DurationMS < 0 {
+			return nil, invalidArgument("media dimensions must not be negative")
+		}
+		return encodePayload(p)
+	case MessageTypeLocation:
+		var p LocationPayload
+		if err := decodePayload(payload, &p); err != nil {
+			return nil, err
+		}
+		if p.Latitude < -90 || p.Latitude > 90 {
+			return nil, invalidArgument("latitude must be between -90 and 90")
+		}
+		if p.Longitude < -180 || p.Longitude > 180 {
+			return nil, invalidArgument("longitude must be between -180 and 180")
+		}
+		return encodePayload(p)
+	case MessageTypeContact:
+		var p ContactPayload
+		if err := decodePayload(payload, &p); err != nil {
+			return nil, err
+		}
+		if strings.TrimSpace(p.DisplayName) == "" {
+			return nil, invalidArgument("contact display name is required")
+		}
+		if len(p.PhoneNumbers) == 0 {
+			return nil, invalidArgument("contact requires at least one phone number")
+		}
+		for _, number := range p.PhoneNumbers {
+			if strings.TrimSpace(number) == "" {
+				return nil, invalidArgument("contact phone numbers must not be empty")
+			}
+		}
+		return encodePayload(p)
+	case MessageTypePoll:
+		var p PollPayload
+		if err := decodePayload(payload, &p); err != nil {
+			return nil, err
+		}
+		if strings.TrimSpace(p.Question) == "" {
+			return nil, invalidArgument("poll question is required")
+		}
+		if len(p.Options) < minPollOptions || len(p.Options) > maxPollOptions {
+			return nil, invalidArgument("polls must have between %d and %d options", minPollOptions, maxPollOptions)
+		}
+		seen := make(map[string]bool)
+		for i, option := range p.Options {
+			option = strings.TrimSpace(option)
+			p.Options[i] = option
+			if option == "" {
+				return nil, invalidArgument("poll options must not be empty")
+			}
+			if seen[option] {
+				return nil, invalidArgument("poll options must be unique")
+			}
+			seen[option] = true
+		}
+		if p.ClosesAt != nil && !p.ClosesAt.After(time.Now()) {
+			return nil, invalidArgument("poll close time must be in the future")
+		}
+		return encodePayload(p)
+	case MessageTypeSticker:
+		var p StickerPayload
+		if err := decodePayload(payload, &p); err != nil {
+			return nil, err
+		}
+		if p.PackID == "" || p.StickerID == "" {
+			return nil, invalidArgument("sticker pack ID and sticker ID are required")
+		}
+		return encodePayload(p)
+	default:
+		return nil, invalidArgument("unsupported message type %q", messageType)
+	}
+}
+
+func decodePayload(payload json.RawMessage, v any) error {
+	if len(payload) == 0 || string(payload) == "null" {
+		return invalidArgument("payload is required")
+	}
+
+	dec := json.NewDecoder(bytes.NewReader(payload))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(v); err != nil {
+		return invalidArgument("invalid payload: %v", err)
+	}
+	return nil
+}
+
+func encodePayload(v any) (json.RawMessage, error) {
+	payload, err := json.Marshal(v)
+	if err != nil {
+		return nil, invalidArgument("invalid payload: %v", err)
+	}
+	return payload, nil
+}
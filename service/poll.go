@@ -0,0 +1,299 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"slices"
+	"time"
+
+	"github.com/felipedavid/chatting/event"
+	"github.com/felipedavid/chatting/storage"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgtype"
+)
+
+// PollResultsResponse holds a poll's tallies, indexed like its options.
+// MyVotes is only filled in for the caller and never broadcast.
+type PollResultsResponse struct {
+	MessageID      pgtype.UUID        `json:"message_id"`
+	ConversationID pgtype.UUID        `json:"conversation_id"`
+	MultipleChoice bool               `json:"multiple_choice"`
+	Tallies        []int64            `json:"tallies"`
+	TotalVoters    int64              `json:"total_voters"`
+	Closed         bool               `json:"closed"`
+	ClosesAt       pgtype.Timestamptz `json:"closes_at"`
+	ClosedAt       pgtype.Timestamptz `json:"closed_at"`
+	MyVotes        []int32            `json:"my_votes,omitempty"`
+}
+
+// createPoll records the poll described by a poll message's payload.
+func createPoll(ctx context.Context, q *storage.Queries, message storage.Message) error {
+	var p PollPayload
+	if err := json.Unmarshal(message.Payload, &p); err != nil {
+		return invalidArgument("invalid payload: %v", err)
+	}
+
+	params := storage.CreatePollParams{
+		MessageID:      message.ID,
+		MultipleChoice: p.MultipleChoice,
+		OptionCount:    int32(len(p.Options)),
+	}
+	if p.ClosesAt != nil {
+		params.ClosesAt = pgtype.Timestamptz{Time: *p.ClosesAt, Valid: true}
+	}
+
+	if _, err := q.CreatePoll(ctx, params); err != nil {
+		return storageError(err, "failed to create poll")
+	}
+
+	return nil
+}
+
+// pollClosed reports whether a poll no longer accepts votes, either because
+// it was closed or because its close time has passed, and since when.
+func pollClosed(poll storage.Poll) (bool, pgtype.Timestamptz) {
+	if poll.ClosedAt.Valid {
+		return true, poll.ClosedAt
+	}
+	if poll.ClosesAt.Valid && !poll.ClosesAt.Time.After(time.Now()) {
+		return true, poll.ClosesAt
+	}
+	return false, pgtype.Timestamptz{}
+}
+
+func getPoll(ctx context.Context, q *storage.Queries, message storage.Message) (storage.Poll, error) {
+	if message.DeletedAt.Valid {
+		return storage.Poll{}, conflict("message has been deleted")
+	}
+
+	poll, err := q.GetPoll(ctx, message.ID)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return storage.Poll{}, notFound("poll not found")
+		}
+		return storage.Poll{}, storageError(err, "failed to get poll")
+	}
+
+	return poll, nil
+}
+
+func pollResults(ctx context.Context, q *storage.Queries, message storage.Message, poll storage.Poll) (PollResultsResponse, error) {
+	counts, err := q.CountPollVotes(ctx, poll.MessageID)
+	if err != nil {
+		return PollResultsResponse{}, storageError(err, "failed to count poll votes")
+	}
+
+	voters, err := q.CountPollVoters(ctx, poll.MessageID)
+	if err != nil {
+		return PollResultsResponse{}, storageError(err, "failed to count poll voters")
+	}
+
+	results := PollResultsResponse{
+		MessageID:      poll.MessageID,
+		ConversationID: message.ConversationID,
+		MultipleChoice: poll.MultipleChoice,
+		Tallies:        make([]int64, poll.OptionCount),
+		TotalVoters:    voters,
+		ClosesAt:       poll.ClosesAt,
+	}
+	results.Closed, results.ClosedAt = pollClosed(poll)
+
+	for _, count := range counts {
+		if count.OptionIndex < poll.OptionCount {
+			results.Tallies[count.OptionIndex] = count.Votes
+		}
+	}
+
+	return results, nil
+}
+
+// GetPollResults returns the current tallies of a poll along with the
+// caller's own votes.
+func (s *MessageService) GetPollResults(ctx context.Context, messageID pgtype.UUID) (*PollResultsResponse, error) {
+	actor, err := requireActor(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if !messageID.Valid {
+		return nil, invalidArgument("message ID is required")
+	}
+
+	message, _, err := s.authz.requireMessageAccess(ctx, messageID, actor.UserID)
+	if err != nil {
+		return nil, err
+	}
+
+	poll, err := getPoll(ctx, s.queries, message)
+	if err != nil {
+		return nil, err
+	}
+
+	results, err := pollResults(ctx, s.queries, message, poll)
+	if err != nil {
+		return nil, err
+	}
+
+	results.MyVotes, err = s.queries.ListUserPollVotes(ctx, storage.ListUserPollVotesParams{
+		MessageID: messageID,
+		UserID:    actor.UserID,
+	})
+	if err != nil {
+		return nil, storageError(err, "failed to get poll votes")
+	}
+
+	return &results, nil
+}
+
+// VotePoll replaces the caller's votes on a poll with the given options.
+// Single-choice polls take exactly one option.
+func (s *MessageService) VotePoll(ctx context.Context, messageID pgtype.UUID, options []int32) (*PollResultsResponse, error) {
+	actor, err := requireActor(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if !messageID.Valid {
+		return nil, invalidArgument("message ID is required")
+	}
+
+	var choices []int32
+	for _, option := range options {
+		if !slices.Contains(choices, option) {
+			choices = append(choices, option)
+		}
+	}
+	slices.Sort(choices)
+	if len(choices) == 0 {
+		return nil, invalidArgument("at least one option is required")
+	}
+
+	return s.changePollVotes(ctx, messageID, actor.UserID, choices)
+}
+
+// RetractPollVote removes all of the caller's votes on a poll.
+func (s *MessageService) RetractPollVote(ctx context.Context, messageID pgtype.UUID) (*PollResultsResponse, error) {
+	actor, err := requireActor(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if !messageID.Valid {
+		return nil, invalidArgument("message ID is required")
+	}
+
+	return s.changePollVotes(ctx, messageID, actor.UserID, nil)
+}
+
+func (s *MessageService) changePollVotes(ctx context.Context, messageID, userID pgtype.UUID, choices []int32) (*PollResultsResponse, error) {
+	var results PollResultsResponse
+	var updated event.Event
+	err := s.tx.InTx(ctx, func(q *storage.Queries) error {
+		authz := authorizer{queries: q}
+		message, _, err := authz.requireMessageAccess(ctx, messageID, userID)
+		if err != nil {
+			return err
+		}
+
+		poll, err := getPoll(ctx, q, message)
+		if err != nil {
+			return err
+		}
+		if closed, _ := pollClosed(poll); closed {
+			return conflict("poll is closed")
+		}
+		if len(choices) > 1 && !poll.MultipleChoice {
+			return invalidArgument("poll allows a single option")
+		}
+		for _, choice := range choices {
+			if choice < 0 || choice >= poll.OptionCount {
+				return invalidArgument("poll option %d does not exist", choice)
+			}
+		}
+
+		if err := q.DeleteUserPollVotes(ctx, storage.DeleteUserPollVotesParams{
+			MessageID: messageID,
+			UserID:    userID,
+		}); err != nil {
+			return storageError(err, "failed to clear poll votes")
+		}
+
+		if len(choices) > 0 {
+			if err := q.CreatePollVotes(ctx, storage.CreatePollVotesParams{
+				MessageID:     messageID,
+				UserID:        userID,
+				OptionIndexes: choices,
+			}); err != nil {
+				return storageError(err, "failed to record poll votes")
+			}
+		}
+
+		results, err = pollResults(ctx, q, message, poll)
+		if err != nil {
+			return err
+		}
+
+		updated, err = s.events.record(ctx, q, event.PollUpdated, results.ConversationID, results)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	s.events.publish(ctx, updated)
+
+	results.MyVotes = choices
+	return &results, nil
+}
+
+// ClosePoll stops a poll from accepting votes, freezing its results. The
+// poll's sender and admins can close it; closing a closed poll is a no-op.
+func (s *MessageService) ClosePoll(ctx context.Context, messageID pgtype.UUID) (*PollResultsResponse, error) {
+	actor, err := requireActor(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if !messageID.Valid {
+		return nil, invalidArgument("message ID is required")
+	}
+
+	var results PollResultsResponse
+	var closed event.Event
+	err = s.tx.InTx(ctx, func(q *storage.Queries) error {
+		closed = event.Event{}
+		authz := authorizer{queries: q}
+		message, participant, err := authz.requireMessageAccess(ctx, messageID, actor.UserID)
+		if err != nil {
+			return err
+		}
+		if message.SenderID != actor.UserID && roleRank(participant.Role.String) < roleRank(RoleAdmin) {
+			return permissionDenied("only the sender or an admin can close a poll")
+		}
+
+		poll, err := getPoll(ctx, q, message)
+		if err != nil {
+			return err
+		}
+		alreadyClosed := poll.ClosedAt.Valid
+		if !alreadyClosed {
+			if poll, err = q.ClosePoll(ctx, messageID); err != nil {
+				return storageError(err, "failed to close poll")
+			}
+		}
+
+		results, err = pollResults(ctx, q, message, poll)
+		if err != nil || alreadyClosed {
+			return err
+		}
+
+		closed, err = s.events.record(ctx, q, event.PollUpdated, results.ConversationID, results)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if closed.Type != "" {
+		s.events.publish(ctx, closed)
+	}
+
+	return &results, nil
+}
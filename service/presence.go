@@ -0,0 +1,275 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"time"
+
+	"github.com/felipedavid/chatting/event"
+	"github.com/felipedavid/chatting/storage"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgtype"
+)
+
+// Presence visibilities decide who can see whether a user is online and
+// when they were last seen.
+const (
+	PresenceEveryone = "everyone"
+	PresenceContacts = "contacts"
+	PresenceNobody   = "nobody"
+)
+
+const (
+	// presenceTTL is how long a device stays online after its last
+	// heartbeat. It spans a few heartbeats so one late ping does not flap
+	// the user offline.
+	presenceTTL = 90 * time.Second
+
+	presenceSweepInterval = 30 * time.Second
+
+	maxPresenceSubscriptions = 500
+)
+
+// PresenceService tracks which users have a device connected to the gateway
+// and when each user was last seen. Presence changes are published on the
+// event bus with no conversation; the gateway routes them to the sockets
+// subscribed to the user.
+type PresenceService struct {
+	queries   *storage.Queries
+	publisher event.Publisher
+}
+
+func NewPresenceService(queries *storage.Queries, publisher event.Publisher) *PresenceService {
+	return &PresenceService{queries: queries, publisher: publisher}
+}
+
+// PresenceResponse is a user's presence as seen by the caller. LastSeenAt is
+// when one of their devices was last connected.
+type PresenceResponse struct {
+	UserID     pgtype.UUID        `json:"user_id"`
+	Online     bool               `json:"online"`
+	LastSeenAt pgtype.Timestamptz `json:"last_seen_at"`
+}
+
+type PresenceSettingsResponse struct {
+	Visibility string `json:"visibility"`
+}
+
+// revokedPresence tells the sockets watching a user that they must
+// subscribe again to keep receiving the user's presence.
+type revokedPresence struct {
+	UserID pgtype.UUID `json:"user_id"`
+}
+
+// Connect marks the caller's device online, announcing the user as online
+// if none of their other devices was.
+func (s *PresenceService) Connect(ctx context.Context) error {
+	return s.online(ctx)
+}
+
+// Heartbeat renews the caller's device presence. The gateway sends one for
+// every connected device well within presenceTTL.
+func (s *PresenceService) Heartbeat(ctx context.Context) error {
+	return s.online(ctx)
+}
+
+func (s *PresenceService) online(ctx context.Context) error {
+	actor, err := requireActor(ctx)
+	if err != nil {
+		return err
+	}
+
+	wasOnline, err := s.queries.MarkDeviceOnline(ctx, storage.MarkDeviceOnlineParams{
+		UserID:     actor.UserID,
+		TtlSeconds: presenceTTL.Seconds(),
+		DeviceID:   actor.DeviceID,
+	})
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return notFound("device not found")
+		}
+		return storageError(err, "failed to record presence")
+	}
+
+	if !wasOnline {
+		s.publish(ctx, event.PresenceUpdated, PresenceResponse{
+			UserID:     actor.UserID,
+			Online:     true,
+			LastSeenAt: pgtype.Timestamptz{Time: time.Now(), Valid: true},
+		})
+	}
+
+	return nil
+}
+
+// Disconnect marks the caller's device offline, announcing the user as
+// offline if it was their last connected device.
+func (s *PresenceService) Disconnect(ctx context.Context) error {
+	actor, err := requireActor(ctx)
+	if err != nil {
+		return err
+	}
+
+	row, err := s.queries.MarkDeviceOffline(ctx, storage.MarkDeviceOfflineParams{
+		DeviceID: actor.DeviceID,
+		UserID:   actor.UserID,
+	})
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return notFound("user not found")
+		}
+		return storageError(err, "failed to record presence")
+	}
+
+	if !row.Online {
+		s.publish(ctx, event.PresenceUpdated, PresenceResponse{
+			UserID:     actor.UserID,
+			LastSeenAt: row.LastSeenAt,
+		})
+	}
+
+	return nil
+}
+
+// Run expires the presence of devices that stopped sending heartbeats
+// without disconnecting, until ctx is cancelled. Every instance can run it;
+// each lease is expired once.
+func (s *PresenceService) Run(ctx context.Context) {
+	ticker := time.NewTicker(presenceSweepInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			s.expire(ctx)
+		}
+	}
+}
+
+func (s *PresenceService) expire(ctx context.Context) {
+	users, err := s.queries.ExpireDevicePresence(ctx)
+	if err != nil {
+		if ctx.Err() == nil {
+			slog.Warn("failed to expire presence", "error", err)
+		}
+		return
+	}
+
+	for _, user := range users {
+		s.publish(ctx, event.PresenceUpdated, PresenceResponse{
+			UserID:     user.ID,
+			LastSeenAt: user.LastSeenAt,
+		})
+	}
+}
+
+// GetPresence returns a user's presence if their visibility lets the caller
+// see it.
+func (s *PresenceService) GetPresence(ctx context.Context, userID pgtype.UUID) (*PresenceResponse, error) {
+	if !userID.Valid {
+		return nil, invalidArgument("user ID is required")
+	}
+
+	presence, err := s.visiblePresence(ctx, []pgtype.UUID{userID}, false)
+	if err != nil {
+		return nil, err
+	}
+	if len(presence) == 0 {
+		return nil, permissionDenied("presence is not visible")
+	}
+
+	return &presence[0], nil
+}
+
+// SubscribablePresence returns the presence of those of the given users
+// the caller may follow: their contacts whose visibility lets the caller
+// see it. Users left out are not followed.
+func (s *PresenceService) SubscribablePresence(ctx context.Context, userIDs []pgtype.UUID) ([]PresenceResponse, error) {
+	if len(userIDs) > maxPresenceSubscriptions {
+		return nil, invalidArgument("at most %d users can be followed at once", maxPresenceSubscriptions)
+	}
+
+	return s.visiblePresence(ctx, userIDs, true)
+}
+
+func (s *PresenceService) visiblePresence(ctx context.Context, userIDs []pgtype.UUID, contactsOnly bool) ([]PresenceResponse, error) {
+	actor, err := requireActor(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := s.queries.ListVisiblePresence(ctx, storage.ListVisiblePresenceParams{
+		UserIds:      userIDs,
+		ContactsOnly: contactsOnly,
+		ViewerID:     actor.UserID,
+	})
+	if err != nil {
+		return nil, storageError(err, "failed to get presence")
+	}
+
+	presence := []PresenceResponse{}
+	for _, row := range rows {
+		presence = append(presence, PresenceResponse{
+			UserID:     row.ID,
+			Online:     row.Online,
+			LastSeenAt: row.LastSeenAt,
+		})
+	}
+
+	return presence, nil
+}
+
+func (s *PresenceService) GetSettings(ctx context.Context) (*PresenceSettingsResponse, error) {
+	actor, err := requireActor(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	visibility, err := s.queries.GetPresenceVisibility(ctx, actor.UserID)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, notFound("user not found")
+		}
+		return nil, storageError(err, "failed to get presence settings")
+	}
+
+	return &PresenceSettingsResponse{Visibility: visibility}, nil
+}
+
+// UpdateSettings changes who can see the caller's presence. Everyone
+// following the caller has to subscribe again, so the new visibility
+// applies to them too.
+func (s *PresenceService) UpdateSettings(ctx context.Context, visibility string) (*PresenceSettingsResponse, error) {
+	actor, err := requireActor(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	switch visibility {
+	case PresenceEveryone, PresenceContacts, PresenceNobody:
+	default:
+		return nil, invalidArgument("visibility must be %s, %s or %s", PresenceEveryone, PresenceContacts, PresenceNobody)
+	}
+
+	visibility, err = s.queries.UpdatePresenceVisibility(ctx, storage.UpdatePresenceVisibilityParams{
+		ID:                 actor.UserID,
+		PresenceVisibility: visibility,
+	})
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, notFound("user not found")
+		}
+		return nil, storageError(err, "failed to update presence settings")
+	}
+
+	s.publish(ctx, event.PresenceRevoked, revokedPresence{UserID: actor.UserID})
+
+	return &PresenceSettingsResponse{Visibility: visibility}, nil
+}
+
+func (s *PresenceService) publish(ctx context.Context, eventType event.Type, data any) {
+	publishGlobal(ctx, s.publisher, eventType, data)
+}
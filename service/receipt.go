@@ -0,0 +1,349 @@
+package service
+
+import (
+	"context"
+	"errors"
+
+	"github.com/felipedavid/chatting/event"
+	"github.com/felipedavid/chatting/storage"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgtype"
+)
+
+// Ticks summarise a message's receipts for its sender. In groups a message
+// only counts as delivered or read once every recipient has received or
+// read it.
+const (
+	TickSent      = "sent"
+	TickDelivered = "delivered"
+	TickRead      = "read"
+)
+
+// ReceiptService records when messages reach and are read by their
+// recipients. Receipt changes are recorded privately for the senders of the
+// affected messages, so only they receive and sync them.
+type ReceiptService struct {
+	queries *storage.Queries
+	tx      TxRunner
+	events  notifier
+	authz   authorizer
+}
+
+func NewReceiptService(queries *storage.Queries, tx TxRunner, publisher event.Publisher) *ReceiptService {
+	return &ReceiptService{
+		queries: queries,
+		tx:      tx,
+		events:  notifier{publisher: publisher},
+		authz:   authorizer{queries: queries},
+	}
+}
+
+type ReceiptResponse struct {
+	MessageID   pgtype.UUID        `json:"message_id"`
+	UserID      pgtype.UUID        `json:"user_id"`
+	DeliveredAt pgtype.Timestamptz `json:"delivered_at"`
+	ReadAt      pgtype.Timestamptz `json:"read_at"`
+}
+
+// ReceiptRangeResponse reports that a user received or read every message
+// of a conversation up to and including UpToSeq.
+type ReceiptRangeResponse struct {
+	ConversationID pgtype.UUID `json:"conversation_id"`
+	UserID         pgtype.UUID `json:"user_id"`
+	UpToSeq        int64       `json:"up_to_seq"`
+	Status         string      `json:"status"`
+}
+
+type MessageStatusResponse struct {
+	MessageID      pgtype.UUID `json:"message_id"`
+	Status         string      `json:"status"`
+	RecipientCount int64       `json:"recipient_count"`
+	DeliveredCount int64       `json:"delivered_count"`
+	ReadCount      int64       `json:"read_count"`
+}
+
+func newReceiptResponse(receipt storage.MessageReceipt) *ReceiptResponse {
+	return &ReceiptResponse{
+		MessageID:   receipt.MessageID,
+		UserID:      receipt.UserID,
+		DeliveredAt: receipt.DeliveredAt,
+		ReadAt:      receipt.ReadAt,
+	}
+}
+
+// MarkDelivered records that a message reached one of the caller's devices.
+// Only the first delivery is kept.
+func (s *ReceiptService) MarkDelivered(ctx context.Context, messageID pgtype.UUID) (*ReceiptResponse, error) {
+	return s.mark(ctx, messageID, TickDelivered)
+}
+
+// MarkRead records that the caller read a message, which also marks it
+// delivered. Only the first read is kept. It leaves the caller's read
+// watermark, and so their unread counts, alone; see MarkReadUpTo.
+func (s *ReceiptService) MarkRead(ctx context.Context, messageID pgtype.UUID) (*ReceiptResponse, error) {
+	return s.mark(ctx, messageID, TickRead)
+}
+
+func (s *ReceiptService) mark(ctx context.Context, messageID pgtype.UUID, status string) (*ReceiptResponse, error) {
+	actor, err := requireActor(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if !messageID.Valid {
+		return nil, invalidArgument("message ID is required")
+	}
+
+	message, _, err := s.authz.requireMessageAccess(ctx, messageID, actor.UserID)
+	if err != nil {
+		return nil, err
+	}
+	if message.SenderID == actor.UserID {
+		return nil, invalidArgument("receipts are not recorded for your own messages")
+	}
+
+	var receipt storage.MessageReceipt
+	var updated event.Event
+	err = s.tx.InTx(ctx, func(q *storage.Queries) error {
+		updated = event.Event{}
+
+		var err error
+		if status == TickRead {
+			receipt, err = q.MarkMessageAsRead(ctx, storage.MarkMessageAsReadParams{
+				MessageID: messageID,
+				UserID:    actor.UserID,
+			})
+		} else {
+			receipt, err = q.MarkMessageAsDelivered(ctx, storage.MarkMessageAsDeliveredParams{
+				MessageID: messageID,
+				UserID:    actor.UserID,
+			})
+		}
+		if err != nil {
+			if !errors.Is(err, pgx.ErrNoRows) {
+				return storageError(err, "failed to record receipt")
+			}
+
+			// Already recorded; report the receipt as it stands
+			receipt, err = q.GetMessageReceipt(ctx, storage.GetMessageReceiptParams{
+				MessageID: messageID,
+				UserID:    actor.UserID,
+			})
+			if err != nil {
+				return storageError(err, "failed to get receipt")
+			}
+			return nil
+		}
+
+		if !message.SenderID.Valid {
+			return nil
+		}
+
+		updated, err = s.events.recordPrivate(ctx, q, []pgtype.UUID{message.SenderID}, event.ReceiptUpdated, message.ConversationID, newReceiptResponse(receipt))
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if updated.Type != "" {
+		s.events.publish(ctx, updated)
+	}
+
+	return newReceiptResponse(receipt), nil
+}
+
+// MarkDeliveredUpTo records that every message in the conversation up to
+// and including messageID reached the caller and moves their delivered
+// watermark there, so later calls only look at newer messages.
+func (s *ReceiptService) MarkDeliveredUpTo(ctx context.Context, conversationID, messageID pgtype.UUID) (*ReceiptRangeResponse, error) {
+	return s.markUpTo(ctx, conversationID, messageID, TickDelivered)
+}
+
+// MarkReadUpTo records that the caller read every message in the
+// conversation up to and including messageID and moves their read watermark
+// there, clearing the unread and unread mention counts below it.
+func (s *ReceiptService) MarkReadUpTo(ctx context.Context, conversationID, messageID pgtype.UUID) (*ReceiptRangeResponse, error) {
+	return s.markUpTo(ctx, conversationID, messageID, TickRead)
+}
+
+func (s *ReceiptService) markUpTo(ctx context.Context, conversationID, messageID pgtype.UUID, status string) (*ReceiptRangeResponse, error) {
+	actor, err := requireActor(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if !conversationID.Valid {
+		return nil, invalidArgument("conversation ID is required")
+	}
+	if !messageID.Valid {
+		return nil, invalidArgument("message ID is required")
+	}
+
+	message, _, err := s.authz.requireMessageAccess(ctx, messageID, actor.UserID)
+	if err != nil {
+		return nil, err
+	}
+	if message.ConversationID != conversationID {
+		return nil, invalidArgument("message belongs to another conversation")
+	}
+
+	response := &ReceiptRangeResponse{
+		ConversationID: conversationID,
+		UserID:         actor.UserID,
+		UpToSeq:        message.Seq,
+		Status:         status,
+	}
+
+	var updated event.Event
+	err = s.tx.InTx(ctx, func(q *storage.Queries) error {
+		updated = event.Event{}
+
+		var recipients []pgtype.UUID
+		if status == TickRead {
+			authz := authorizer{queries: q}
+			participant, err := authz.requireParticipant(ctx, conversationID, actor.UserID)
+			if err != nil {
+				return err
+			}
+
+			senders, err := markReadUpTo(ctx, q, conversationID, actor.UserID, message.Seq)
+			if err != nil {
+				return err
+			}
+
+			// Nothing changed, so there is nothing to tell anyone
+			if participant.LastReadSeq >= message.Seq && len(senders) == 0 {
+				return nil
+			}
+
+			// The caller's other devices move their watermark too
+			recipients = append(senders, actor.UserID)
+		} else {
+			senders, err := q.MarkMessagesDeliveredUpTo(ctx, storage.MarkMessagesDeliveredUpToParams{
+				UserID:         actor.UserID,
+				ConversationID: conversationID,
+				Seq:            message.Seq,
+			})
+			if err != nil {
+				return storageError(err, "failed to record receipts")
+			}
+
+			if err := q.AdvanceDeliveredWatermark(ctx, storage.AdvanceDeliveredWatermarkParams{
+				Seq:            message.Seq,
+				ConversationID: conversationID,
+				UserID:         actor.UserID,
+			}); err != nil {
+				return storageError(err, "failed to advance delivered watermark")
+			}
+
+			if len(senders) == 0 {
+				return nil
+			}
+
+			recipients = senders
+		}
+
+		var err error
+		updated, err = s.events.recordPrivate(ctx, q, recipients, event.ReceiptsUpdated, conversationID, response)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if updated.Type != "" {
+		s.events.publish(ctx, updated)
+	}
+
+	return response, nil
+}
+
+// markReadUpTo marks every message of the conversation up to seq as read by
+// userID and moves their read watermark there. Messages only count as read
+// below the watermark if they have a receipt, so the two must always move
+// together. It returns the senders of the messages that changed.
+func markReadUpTo(ctx context.Context, q *storage.Queries, conversationID, userID pgtype.UUID, seq int64) ([]pgtype.UUID, error) {
+	senders, err := q.MarkMessagesReadUpTo(ctx, storage.MarkMessagesReadUpToParams{
+		UserID:         userID,
+		ConversationID: conversationID,
+		Seq:            seq,
+	})
+	if err != nil {
+		return nil, storageError(err, "failed to record receipts")
+	}
+
+	if _, err := q.AdvanceReadWatermark(ctx, storage.AdvanceReadWatermarkParams{
+		Seq:            seq,
+		ConversationID: conversationID,
+		UserID:         userID,
+	}); err != nil {
+		return nil, storageError(err, "failed to advance read watermark")
+	}
+
+	return senders, nil
+}
+
+// readUpTo marks everything in the conversation up to seq as read by userID,
+// who has just acted in it and so caught up with it, and records the
+// receipts for the senders of the messages that changed. It returns the
+// recorded events, if any.
+func readUpTo(ctx context.Context, q *storage.Queries, events notifier, conversationID, userID pgtype.UUID, seq int64) ([]event.Event, error) {
+	senders, err := markReadUpTo(ctx, q, conversationID, userID, seq)
+	if err != nil || len(senders) == 0 {
+		return nil, err
+	}
+
+	read, err := events.recordPrivate(ctx, q, senders, event.ReceiptsUpdated, conversationID, ReceiptRangeResponse{
+		ConversationID: conversationID,
+		UserID:         userID,
+		UpToSeq:        seq,
+		Status:         TickRead,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return []event.Event{read}, nil
+}
+
+// GetMessageStatus returns the ticks of one of the caller's messages along
+// with the counts they are derived from.
+func (s *ReceiptService) GetMessageStatus(ctx context.Context, messageID pgtype.UUID) (*MessageStatusResponse, error) {
+	actor, err := requireActor(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if !messageID.Valid {
+		return nil, invalidArgument("message ID is required")
+	}
+
+	message, _, err := s.authz.requireMessageAccess(ctx, messageID, actor.UserID)
+	if err != nil {
+		return nil, err
+	}
+	if message.SenderID != actor.UserID {
+		return nil, permissionDenied("only the sender can see a message's status")
+	}
+
+	counts, err := s.queries.GetMessageReceiptCounts(ctx, messageID)
+	if err != nil {
+		return nil, storageError(err, "failed to count receipts")
+	}
+
+	response := &MessageStatusResponse{
+		MessageID:      messageID,
+		Status:         TickSent,
+		RecipientCount: counts.RecipientCount,
+		DeliveredCount: counts.DeliveredCount,
+		ReadCount:      counts.ReadCount,
+	}
+	if counts.RecipientCount > 0 {
+		switch {
+		case counts.ReadCount == counts.RecipientCount:
+			response.Status = TickRead
+		case counts.DeliveredCount == counts.RecipientCount:
+			response.Status = TickDelivered
+		}
+	}
+
+	return response, nil
+}
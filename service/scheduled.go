@@ -0,0 +1,357 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"time"
+
+	"github.com/felipedavid/chatting/storage"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgtype"
+)
+
+const (
+	ScheduledPending   = "pending"
+	ScheduledSent      = "sent"
+	ScheduledFailed    = "failed"
+	ScheduledCancelled = "cancelled"
+)
+
+const (
+	scheduledDispatchInterval = 5 * time.Second
+	scheduledDispatchBatch    = 50
+
+	// scheduledClaimLease is how long a claimed message is reserved for the
+	// instance sending it. It must comfortably exceed the time one send
+	// takes, or a slow send could be attempted twice; the client message ID
+	// still keeps that from storing a duplicate.
+	scheduledClaimLease = time.Minute
+
+	// maxScheduledAttempts bounds the retries of a message whose send keeps
+	// failing for reasons other than the request itself being invalid.
+	maxScheduledAttempts = 5
+)
+
+// ScheduledMessageService stores messages to be sent later and dispatches
+// them once they are due.
+type ScheduledMessageService struct {
+	queries  *storage.Queries
+	authz    authorizer
+	messages *MessageService
+}
+
+func NewScheduledMessageService(queries *storage.Queries, messages *MessageService) *ScheduledMessageService {
+	return &ScheduledMessageService{
+		queries:  queries,
+		authz:    authorizer{queries: queries},
+		messages: messages,
+	}
+}
+
+type ScheduleMessageRequest struct {
+	ConversationID pgtype.UUID     `json:"conversation_id"`
+	Content        string          `json:"content"`
+	MessageType    string          `json:"message_type"`
+	Payload        json.RawMessage `json:"payload"`
+	ReplyToID      pgtype.UUID     `json:"reply_to_id"`
+	ThreadRootID   pgtype.UUID     `json:"thread_root_id"`
+	SendAt         time.Time       `json:"send_at"`
+}
+
+// UpdateScheduledMessageRequest replaces what a pending scheduled message
+// will send and when.
+type UpdateScheduledMessageRequest struct {
+	Content     string          `json:"content"`
+	MessageType string          `json:"message_type"`
+	Payload     json.RawMessage `json:"payload"`
+	SendAt      time.Time       `json:"send_at"`
+}
+
+type ScheduledMessageResponse struct {
+	ID             pgtype.UUID        `json:"id"`
+	ConversationID pgtype.UUID        `json:"conversation_id"`
+	Content        string             `json:"content"`
+	MessageType    string             `json:"message_type"`
+	Payload        json.RawMessage    `json:"payload,omitempty"`
+	ReplyToID      pgtype.UUID        `json:"reply_to_id"`
+	ThreadRootID   pgtype.UUID        `json:"thread_root_id"`
+	SendAt         pgtype.Timestamptz `json:"send_at"`
+	Status         string             `json:"status"`
+	MessageID      pgtype.UUID        `json:"message_id"`
+	LastError      string             `json:"last_error,omitempty"`
+	CreatedAt      pgtype.Timestamptz `json:"created_at"`
+	UpdatedAt      pgtype.Timestamptz `json:"updated_at"`
+}
+
+func newScheduledMessageResponse(scheduled storage.ScheduledMessage) ScheduledMessageResponse {
+	return ScheduledMessageResponse{
+		ID:             scheduled.ID,
+		ConversationID: scheduled.ConversationID,
+		Content:        scheduled.Content.String,
+		MessageType:    scheduled.MessageType,
+		Payload:        scheduled.Payload,
+		ReplyToID:      scheduled.ReplyToID,
+		ThreadRootID:   scheduled.ThreadRootID,
+		SendAt:         scheduled.SendAt,
+		Status:         scheduled.Status,
+		MessageID:      scheduled.MessageID,
+		LastError:      scheduled.LastError.String,
+		CreatedAt:      scheduled.CreatedAt,
+		UpdatedAt:      scheduled.UpdatedAt,
+	}
+}
+
+// ScheduleMessage stores a message to be sent on the caller's behalf, from
+// their current device, at req.SendAt. It is validated now and again when
+// it is sent.
+func (s *ScheduledMessageService) ScheduleMessage(ctx context.Context, req ScheduleMessageRequest) (*ScheduledMessageResponse, error) {
+	actor, err := requireActor(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if !req.ConversationID.Valid {
+		return nil, invalidArgument("conversation ID is required")
+	}
+	if !req.SendAt.After(time.Now()) {
+		return nil, invalidArgument("send time must be in the future")
+	}
+	if req.MessageType == "" {
+		req.MessageType = MessageTypeText
+	}
+	payload, err := validatePayload(req.MessageType, req.Content, req.Payload)
+	if err != nil {
+		return nil, err
+	}
+
+	if _, err := s.authz.requireParticipant(ctx, req.ConversationID, actor.UserID); err != nil {
+		return nil, err
+	}
+	if err := checkReplyTarget(ctx, s.queries, req.ConversationID, actor.UserID, req.ReplyToID); err != nil {
+		return nil, err
+	}
+
+	scheduled, err := s.queries.CreateScheduledMessage(ctx, storage.CreateScheduledMessageParams{
+		ConversationID: req.ConversationID,
+		UserID:         actor.UserID,
+		DeviceID:       actor.DeviceID,
+		Content:        pgtype.Text{String: req.Content, Valid: req.Content != ""},
+		MessageType:    req.MessageType,
+		Payload:        payload,
+		ReplyToID:      req.ReplyToID,
+		ThreadRootID:   req.ThreadRootID,
+		SendAt:         pgtype.Timestamptz{Time: req.SendAt, Valid: true},
+	})
+	if err != nil {
+		return nil, storageError(err, "failed to schedule message")
+	}
+
+	response := newScheduledMessageResponse(scheduled)
+	return &response, nil
+}
+
+// ListScheduledMessages returns the caller's pending scheduled messages,
+// soonest first, optionally only those for one conversation.
+func (s *ScheduledMessageService) ListScheduledMessages(ctx context.Context, conversationID pgtype.UUID) ([]ScheduledMessageResponse, error) {
+	actor, err := requireActor(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	scheduled, err := s.queries.ListPendingScheduledMessages(ctx, storage.ListPendingScheduledMessagesParams{
+		UserID:         actor.UserID,
+		ConversationID: conversationID,
+	})
+	if err != nil {
+		return nil, storageError(err, "failed to list scheduled messages")
+	}
+
+	responses := []ScheduledMessageResponse{}
+	for _, message := range scheduled {
+		responses = append(responses, newScheduledMessageResponse(message))
+	}
+
+	return responses, nil
+}
+
+func (s *ScheduledMessageService) UpdateScheduledMessage(ctx context.Context, id pgtype.UUID, req UpdateScheduledMessageRequest) (*ScheduledMessageResponse, error) {
+	actor, err := requireActor(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if !req.SendAt.After(time.Now()) {
+		return nil, invalidArgument("send time must be in the future")
+	}
+	if req.MessageType == "" {
+		req.MessageType = MessageTypeText
+	}
+	payload, err := validatePayload(req.MessageType, req.Content, req.Payload)
+	if err != nil {
+		return nil, err
+	}
+
+	if _, err := s.requirePending(ctx, id, actor.UserID); err != nil {
+		return nil, err
+	}
+
+	scheduled, err := s.queries.UpdateScheduledMessage(ctx, storage.UpdateScheduledMessageParams{
+		ID:          id,
+		Content:     pgtype.Text{String: req.Content, Valid: req.Content != ""},
+		MessageType: req.MessageType,
+		Payload:     payload,
+		SendAt:      pgtype.Timestamptz{Time: req.SendAt, Valid: true},
+	})
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, conflict("scheduled message is already being sent")
+		}
+		return nil, storageError(err, "failed to update scheduled message")
+	}
+
+	response := newScheduledMessageResponse(scheduled)
+	return &response, nil
+}
+
+func (s *ScheduledMessageService) CancelScheduledMessage(ctx context.Context, id pgtype.UUID) error {
+	actor, err := requireActor(ctx)
+	if err != nil {
+		return err
+	}
+
+	if _, err := s.requirePending(ctx, id, actor.UserID); err != nil {
+		return err
+	}
+
+	if _, err := s.queries.CancelScheduledMessage(ctx, id); err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return conflict("scheduled message is already being sent")
+		}
+		return storageError(err, "failed to cancel scheduled message")
+	}
+
+	return nil
+}
+
+// requirePending returns the caller's scheduled message if it has not been
+// sent, failed or been cancelled yet.
+func (s *ScheduledMessageService) requirePending(ctx context.Context, id, userID pgtype.UUID) (storage.ScheduledMessage, error) {
+	if !id.Valid {
+		return storage.ScheduledMessage{}, invalidArgument("scheduled message ID is required")
+	}
+
+	scheduled, err := s.queries.GetScheduledMessage(ctx, id)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return storage.ScheduledMessage{}, notFound("scheduled message not found")
+		}
+		return storage.ScheduledMessage{}, storageError(err, "failed to get scheduled message")
+	}
+	// Other users' scheduled messages are indistinguishable from missing ones
+	if scheduled.UserID != userID {
+		return storage.ScheduledMessage{}, notFound("scheduled message not found")
+	}
+	if scheduled.Status != ScheduledPending {
+		return storage.ScheduledMessage{}, conflict("scheduled message is already %s", scheduled.Status)
+	}
+
+	return scheduled, nil
+}
+
+// Run sends scheduled messages as they become due until ctx is done.
+//
+// Delivery is at least once: a message is only marked sent after it was
+// stored, and a claim abandoned by a crash expires and is retried. Every
+// attempt sends with the same client message ID, so a retry of a message
+// that was in fact stored returns it instead of sending it again.
+func (s *ScheduledMessageService) Run(ctx context.Context) error {
+	ticker := time.NewTicker(scheduledDispatchInterval)
+	defer ticker.Stop()
+
+	for {
+		s.dispatchDue(ctx)
+
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+		}
+	}
+}
+
+func (s *ScheduledMessageService) dispatchDue(ctx context.Context) {
+	for ctx.Err() == nil {
+		due, err := s.queries.ClaimDueScheduledMessages(ctx, storage.ClaimDueScheduledMessagesParams{
+			LeaseSeconds: scheduledClaimLease.Seconds(),
+			Limit:        scheduledDispatchBatch,
+		})
+		if err != nil {
+			slog.Warn("failed to claim scheduled messages", "error", err)
+			return
+		}
+
+		for _, scheduled := range due {
+			s.dispatch(ctx, scheduled)
+		}
+
+		if len(due) < scheduledDispatchBatch {
+			return
+		}
+	}
+}
+
+// dispatch sends one claimed message through CreateMessage, acting as the
+// user and device that scheduled it, so it gets the same checks as any other
+// send.
+func (s *ScheduledMessageService) dispatch(ctx context.Context, scheduled storage.ScheduledMessage) {
+	actorCtx := WithActor(ctx, Actor{UserID: scheduled.UserID, DeviceID: scheduled.DeviceID})
+
+	message, err := s.messages.CreateMessage(actorCtx, CreateMessageRequest{
+		ConversationID:  scheduled.ConversationID,
+		ClientMessageID: "scheduled:" + scheduled.ID.String(),
+		Content:         scheduled.Content.String,
+		MessageType:     scheduled.MessageType,
+		Payload:         scheduled.Payload,
+		ReplyToID:       scheduled.ReplyToID,
+		ThreadRootID:    scheduled.ThreadRootID,
+	})
+	if err == nil {
+		if err := s.queries.MarkScheduledMessageSent(ctx, storage.MarkScheduledMessageSentParams{
+			ID:        scheduled.ID,
+			MessageID: message.ID,
+		}); err != nil {
+			// The claim expires and the retry finds the stored message
+			slog.Warn("failed to mark scheduled message sent", "id", scheduled.ID, "error", err)
+		}
+		return
+	}
+
+	// Errors from the service layer mean the message can no longer be sent,
+	// for instance because the user left the conversation. Conflicts and
+	// storage failures may clear up on a later attempt.
+	var serr *Error
+	permanent := errors.As(err, &serr) && !errors.Is(err, ErrConflict)
+
+	// The error is shown to the user, so it must not carry internal details
+	lastError := pgtype.Text{String: "failed to send message", Valid: true}
+	if serr != nil {
+		lastError.String = serr.Message
+	}
+	if !permanent && scheduled.Attempts < maxScheduledAttempts {
+		slog.Warn("failed to send scheduled message", "id", scheduled.ID, "attempt", scheduled.Attempts, "error", err)
+		if err := s.queries.RecordScheduledMessageError(ctx, storage.RecordScheduledMessageErrorParams{
+			ID:        scheduled.ID,
+			LastError: lastError,
+		}); err != nil {
+			slog.Warn("failed to record scheduled message error", "id", scheduled.ID, "error", err)
+		}
+		return
+	}
+
+	if err := s.queries.MarkScheduledMessageFailed(ctx, storage.MarkScheduledMessageFailedParams{
+		ID:        scheduled.ID,
+		LastError: lastError,
+	}); err != nil {
+		slog.Warn("failed to mark scheduled message failed", "id", scheduled.ID, "error", err)
+	}
+}
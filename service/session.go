@@ -0,0 +1,258 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/felipedavid/chatting/auth"
+	"github.com/felipedavid/chatting/event"
+	"github.com/felipedavid/chatting/storage"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgtype"
+)
+
+const refreshTokenTTL = 30 * 24 * time.Hour
+
+type SessionService struct {
+	queries      *storage.Queries
+	tx           TxRunner
+	verification *VerificationService
+	tokens       *auth.TokenManager
+	publisher    event.Publisher
+}
+
+func NewSessionService(queries *storage.Queries, tx TxRunner, verification *VerificationService, tokens *auth.TokenManager, publisher event.Publisher) *SessionService {
+	return &SessionService{queries: queries, tx: tx, verification: verification, tokens: tokens, publisher: publisher}
+}
+
+// revokedSession tells the gateway to close the sockets of a session, or of
+// every session of the device if SessionID is empty.
+type revokedSession struct {
+	UserID    pgtype.UUID `json:"user_id"`
+	DeviceID  pgtype.UUID `json:"device_id"`
+	SessionID pgtype.UUID `json:"session_id"`
+}
+
+type LoginRequest struct {
+	PhoneNumber string `json:"phone_number"`
+	Code        string `json:"code"`
+	DisplayName string `json:"display_name"`
+	DeviceName  string `json:"device_name"`
+	DeviceType  string `json:"device_type"`
+	PublicKey   string `json:"public_key"`
+}
+
+type TokenResponse struct {
+	AccessToken           string    `json:"access_token"`
+	AccessTokenExpiresAt  time.Time `json:"access_token_expires_at"`
+	RefreshToken          string    `json:"refresh_token"`
+	RefreshTokenExpiresAt time.Time `json:"refresh_token_expires_at"`
+}
+
+type LoginResponse struct {
+	User     UserResponse  `json:"user"`
+	DeviceID pgtype.UUID   `json:"device_id"`
+	Created  bool          `json:"created"`
+	Tokens   TokenResponse `json:"tokens"`
+}
+
+// Login verifies the one-time code, registers the device if it is new and
+// opens a session bound to it.
+func (s *SessionService) Login(ctx context.Context, req LoginRequest) (*LoginResponse, error) {
+	if req.PublicKey == "" {
+		return nil, invalidArgument("device public key is required")
+	}
+
+	verified, err := s.verification.VerifyCode(ctx, VerifyCodeRequest{
+		PhoneNumber: req.PhoneNumber,
+		Code:        req.Code,
+		DisplayName: req.DisplayName,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	refreshToken, err := auth.NewRefreshToken()
+	if err != nil {
+		return nil, err
+	}
+
+	var device storage.UserDevice
+	var session storage.Session
+	err = s.tx.InTx(ctx, func(q *storage.Queries) error {
+		device, err = q.GetUserDeviceByUserAndKey(ctx, storage.GetUserDeviceByUserAndKeyParams{
+			UserID:    verified.User.ID,
+			PublicKey: req.PublicKey,
+		})
+		if errors.Is(err, pgx.ErrNoRows) {
+			device, err = q.CreateUserDevice(ctx, storage.CreateUserDeviceParams{
+				UserID:     verified.User.ID,
+				DeviceName: pgtype.Text{String: req.DeviceName, Valid: req.DeviceName != ""},
+				DeviceType: pgtype.Text{String: req.DeviceType, Valid: req.DeviceType != ""},
+				PublicKey:  req.PublicKey,
+			})
+			if err != nil {
+				return storageError(err, "failed to register device")
+			}
+		} else if err != nil {
+			return storageError(err, "failed to get device")
+		}
+
+		session, err = q.CreateSession(ctx, storage.CreateSessionParams{
+			UserID:           device.UserID,
+			DeviceID:         device.ID,
+			RefreshTokenHash: auth.HashRefreshToken(refreshToken),
+			ExpiresAt:        pgtype.Timestamptz{Time: time.Now().Add(refreshTokenTTL), Valid: true},
+		})
+		if err != nil {
+			return storageError(err, "failed to create session")
+		}
+
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	tokens, err := s.issueTokens(session, refreshToken)
+	if err != nil {
+		return nil, err
+	}
+
+	return &LoginResponse{
+		User:     verified.User,
+		DeviceID: device.ID,
+		Created:  verified.Created,
+		Tokens:   *tokens,
+	}, nil
+}
+
+// Refresh exchanges a refresh token for a new token pair. The presented
+// refresh token is rotated and cannot be used again; presenting it again
+// while it is being rotated revokes the session.
+func (s *SessionService) Refresh(ctx context.Context, refreshToken string) (*TokenResponse, error) {
+	if refreshToken == "" {
+		return nil, invalidArgument("refresh token is required")
+	}
+
+	session, err := s.queries.GetSessionByRefreshTokenHash(ctx, auth.HashRefreshToken(refreshToken))
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, unauthenticated("invalid refresh token")
+		}
+		return nil, storageError(err, "failed to get session")
+	}
+	if session.RevokedAt.Valid || time.Now().After(session.ExpiresAt.Time) {
+		return nil, unauthenticated("session has expired")
+	}
+
+	newRefreshToken, err := auth.NewRefreshToken()
+	if err != nil {
+		return nil, err
+	}
+
+	rotated, err := s.queries.RotateSessionRefreshToken(ctx, storage.RotateSessionRefreshTokenParams{
+		NewRefreshTokenHash: auth.HashRefreshToken(newRefreshToken),
+		ExpiresAt:           pgtype.Timestamptz{Time: time.Now().Add(refreshTokenTTL), Valid: true},
+		ID:                  session.ID,
+		RefreshTokenHash:    session.RefreshTokenHash,
+	})
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			// Another request rotated this token first, so it has been used
+			// twice and may have been stolen. End the session for both
+			// holders.
+			if err := s.queries.RevokeSession(ctx, session.ID); err != nil {
+				return nil, storageError(err, "failed to revoke session")
+			}
+			publishGlobal(ctx, s.publisher, event.SessionRevoked, revokedSession{
+				UserID:    session.UserID,
+				DeviceID:  session.DeviceID,
+				SessionID: session.ID,
+			})
+			return nil, unauthenticated("refresh token has already been used")
+		}
+		return nil, storageError(err, "failed to rotate refresh token")
+	}
+
+	return s.issueTokens(rotated, newRefreshToken)
+}
+
+// Authenticate resolves an access token to the actor it was issued to. The
+// session is looked up on every call so revocations take effect immediately.
+func (s *SessionService) Authenticate(ctx context.Context, accessToken string) (Actor, error) {
+	claims, err := s.tokens.Parse(accessToken)
+	if err != nil {
+		return Actor{}, err
+	}
+
+	session, err := s.queries.GetActiveSession(ctx, claims.SessionID)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return Actor{}, auth.ErrInvalidToken
+		}
+		return Actor{}, storageError(err, "failed to get session")
+	}
+	if session.UserID != claims.UserID || session.DeviceID != claims.DeviceID {
+		return Actor{}, auth.ErrInvalidToken
+	}
+
+	return Actor{
+		UserID:    session.UserID,
+		DeviceID:  session.DeviceID,
+		SessionID: session.ID,
+	}, nil
+}
+
+// CheckSession reports whether the caller's session is still active, for
+// connections that outlive the request they were authenticated with.
+func (s *SessionService) CheckSession(ctx context.Context) error {
+	actor, err := requireActor(ctx)
+	if err != nil {
+		return err
+	}
+
+	if _, err := s.queries.GetActiveSession(ctx, actor.SessionID); err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return unauthenticated("session has been revoked")
+		}
+		return storageError(err, "failed to get session")
+	}
+
+	return nil
+}
+
+// Logout revokes the caller's current session and closes its sockets.
+func (s *SessionService) Logout(ctx context.Context) error {
+	actor, err := requireActor(ctx)
+	if err != nil {
+		return err
+	}
+
+	if err := s.queries.RevokeSession(ctx, actor.SessionID); err != nil {
+		return storageError(err, "failed to revoke session")
+	}
+
+	publishGlobal(ctx, s.publisher, event.SessionRevoked, revokedSession{
+		UserID:    actor.UserID,
+		DeviceID:  actor.DeviceID,
+		SessionID: actor.SessionID,
+	})
+
+	return nil
+}
+
+func (s *SessionService) issueTokens(session storage.Session, refreshToken string) (*TokenResponse, error) {
+	accessToken, accessExpiresAt, err := s.tokens.Issue(session.UserID, session.DeviceID, session.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	return &TokenResponse{
+		AccessToken:           accessToken,
+		AccessTokenExpiresAt:  accessExpiresAt,
+		RefreshToken:          refreshToken,
+		RefreshTokenExpiresAt: session.ExpiresAt.Time,
+	}, nil
+}
@@ -0,0 +1,115 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+
+	"github.com/felipedavid/chatting/storage"
+	"github.com/jackc/pgx/v5/pgtype"
+)
+
+const (
+	defaultSyncPageSize = 200
+	maxSyncPageSize     = 1000
+)
+
+// SyncService lets a device catch up on everything that happened in its
+// conversations while it was offline.
+type SyncService struct {
+	queries *storage.Queries
+}
+
+func NewSyncService(queries *storage.Queries) *SyncService {
+	return &SyncService{queries: queries}
+}
+
+type SyncRequest struct {
+	// Checkpoint acknowledges every event up to and including it. The
+	// device's stored checkpoint never moves backwards.
+	Checkpoint int64
+	Limit      int32
+}
+
+type SyncResponse struct {
+	Events []SyncEventResponse `json:"events"`
+	// Checkpoint is the value to send with the next request once these
+	// events have been applied.
+	Checkpoint int64 `json:"checkpoint"`
+	HasMore    bool  `json:"has_more"`
+}
+
+type SyncEventResponse struct {
+	ID             int64              `json:"id"`
+	Type           string             `json:"type"`
+	ConversationID pgtype.UUID        `json:"conversation_id"`
+	Data           json.RawMessage    `json:"data"`
+	CreatedAt      pgtype.Timestamptz `json:"created_at"`
+}
+
+// Sync advances the caller's device checkpoint and returns the next page of
+// events after it: new messages, edits, reactions, polls, receipts and
+// membership changes across every conversation the user is part of, and the
+// deletion of conversations they were part of. Receipts only reach the
+// senders of the messages they are for.
+func (s *SyncService) Sync(ctx context.Context, req SyncRequest) (*SyncResponse, error) {
+	actor, err := requireActor(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if req.Checkpoint < 0 {
+		return nil, invalidArgument("checkpoint must not be negative")
+	}
+
+	if req.Limit <= 0 {
+		req.Limit = defaultSyncPageSize
+	}
+	if req.Limit > maxSyncPageSize {
+		req.Limit = maxSyncPageSize
+	}
+
+	device, err := s.queries.AdvanceUserDeviceSyncCheckpoint(ctx, storage.AdvanceUserDeviceSyncCheckpointParams{
+		SyncCheckpoint: req.Checkpoint,
+		ID:             actor.DeviceID,
+	})
+	if err != nil {
+		return nil, storageError(err, "failed to advance sync checkpoint")
+	}
+
+	// Event IDs are allocated before commit, so events are paged by the
+	// sync position they get once every transaction that could still record
+	// an older one has finished.
+	if err := s.queries.SettleConversationEvents(ctx); err != nil {
+		return nil, storageError(err, "failed to settle sync events")
+	}
+
+	events, err := s.queries.ListSyncEvents(ctx, storage.ListSyncEventsParams{
+		Checkpoint: device.SyncCheckpoint,
+		UserID:     actor.UserID,
+		Limit:      req.Limit + 1,
+	})
+	if err != nil {
+		return nil, storageError(err, "failed to list sync events")
+	}
+
+	response := &SyncResponse{
+		Events:     []SyncEventResponse{},
+		Checkpoint: device.SyncCheckpoint,
+	}
+	if len(events) > int(req.Limit) {
+		events = events[:req.Limit]
+		response.HasMore = true
+	}
+
+	for _, e := range events {
+		response.Events = append(response.Events, SyncEventResponse{
+			ID:             e.SyncPosition.Int64,
+			Type:           e.EventType,
+			ConversationID: e.ConversationID,
+			Data:           e.Payload,
+			CreatedAt:      e.CreatedAt,
+		})
+		response.Checkpoint = e.SyncPosition.Int64
+	}
+
+	return response, nil
+}
@@ -0,0 +1,272 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+
+	"github.com/felipedavid/chatting/event"
+	"github.com/felipedavid/chatting/storage"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgtype"
+)
+
+type ListThreadRequest struct {
+	After string
+	Limit int32
+}
+
+// ThreadPage is a thread's root message followed by one page of its replies,
+// oldest first. NextCursor continues after the last reply on the page.
+type ThreadPage struct {
+	Root       MessageResponse   `json:"root"`
+	Replies    []MessageResponse `json:"replies"`
+	Following  bool              `json:"following"`
+	NextCursor string            `json:"next_cursor,omitempty"`
+	HasMore    bool              `json:"has_more"`
+}
+
+type ThreadSummaryResponse struct {
+	RootID      pgtype.UUID        `json:"root_id"`
+	ReplyCount  int32              `json:"reply_count"`
+	LastReplyAt pgtype.Timestamptz `json:"last_reply_at"`
+}
+
+// resolveThreadRoot returns the root of the thread messageID belongs to, or
+// the message itself when it is not a reply in a thread.
+func resolveThreadRoot(ctx context.Context, q *storage.Queries, messageID pgtype.UUID) (storage.Message, error) {
+	message, err := q.GetMessage(ctx, messageID)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return storage.Message{}, notFound("thread root not found")
+		}
+		return storage.Message{}, storageError(err, "failed to get thread root")
+	}
+	if !message.ThreadRootID.Valid {
+		return message, nil
+	}
+
+	root, err := q.GetMessage(ctx, message.ThreadRootID)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return storage.Message{}, notFound("thread root not found")
+		}
+		return storage.Message{}, storageError(err, "failed to get thread root")
+	}
+
+	return root, nil
+}
+
+// createThreadReply stores a reply in the thread rooted at rootID, updates
+// the root's reply summary and makes the replier and the root's sender follow
+// the thread. It returns the reply and the updated root.
+func createThreadReply(ctx context.Context, q *storage.Queries, params storage.CreateMessageParams, rootID pgtype.UUID) (storage.Message, storage.Message, error) {
+	root, err := resolveThreadRoot(ctx, q, rootID)
+	if err != nil {
+		return storage.Message{}, storage.Message{}, err
+	}
+	if root.ConversationID != params.ConversationID {
+		return storage.Message{}, storage.Message{}, invalidArgument("thread root belongs to another conversation")
+	}
+	if root.DeletedAt.Valid {
+		return storage.Message{}, storage.Message{}, conflict("thread root has been deleted")
+	}
+
+	params.ThreadRootID = root.ID
+	message, err := q.CreateMessage(ctx, params)
+	if err != nil {
+		return storage.Message{}, storage.Message{}, storageError(err, "failed to create message")
+	}
+
+	root, err = q.IncrementThreadReplies(ctx, storage.IncrementThreadRepliesParams{
+		ID:                root.ID,
+		ThreadLastReplyAt: message.CreatedAt,
+	})
+	if err != nil {
+		return storage.Message{}, storage.Message{}, storageError(err, "failed to update thread summary")
+	}
+
+	for _, userID := range []pgtype.UUID{message.SenderID, root.SenderID} {
+		if !userID.Valid {
+			continue
+		}
+		if err := q.FollowThread(ctx, storage.FollowThreadParams{
+			MessageID: root.ID,
+			UserID:    userID,
+		}); err != nil {
+			return storage.Message{}, storage.Message{}, storageError(err, "failed to follow thread")
+		}
+	}
+
+	return message, root, nil
+}
+
+func newThreadSummaryResponse(root storage.Message) ThreadSummaryResponse {
+	return ThreadSummaryResponse{
+		RootID:      root.ID,
+		ReplyCount:  root.ThreadReplyCount,
+		LastReplyAt: root.ThreadLastReplyAt,
+	}
+}
+
+// notifyThreadFollowers sends a reply to the followers of its thread, other
+// than its sender, so they notice it even when the conversation is busy.
+// Every participant learns about the root's new summary from its own event.
+func (s *MessageService) notifyThreadFollowers(ctx context.Context, root storage.Message, reply MessageResponse) {
+	followers, err := s.queries.ListThreadFollowers(ctx, root.ID)
+	if err != nil {
+		slog.Warn("failed to list thread followers", "message_id", root.ID, "error", err)
+		return
+	}
+
+	var recipients []pgtype.UUID
+	for _, userID := range followers {
+		if userID != reply.SenderID {
+			recipients = append(recipients, userID)
+		}
+	}
+
+	s.events.notify(ctx, recipients, event.ThreadReply, root.ConversationID, reply)
+}
+
+// GetThread returns the thread that messageID roots or belongs to, with a
+// page of its replies oldest first.
+func (s *MessageService) GetThread(ctx context.Context, messageID pgtype.UUID, req ListThreadRequest) (*ThreadPage, error) {
+	actor, err := requireActor(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if !messageID.Valid {
+		return nil, invalidArgument("message ID is required")
+	}
+
+	if _, _, err := s.authz.requireMessageAccess(ctx, messageID, actor.UserID); err != nil {
+		return nil, err
+	}
+
+	root, err := resolveThreadRoot(ctx, s.queries, messageID)
+	if err != nil {
+		return nil, err
+	}
+
+	if req.Limit <= 0 {
+		req.Limit = 50
+	}
+	if req.Limit > maxPageSize {
+		req.Limit = maxPageSize
+	}
+
+	params := storage.ListThreadRepliesParams{
+		ThreadRootID: root.ID,
+		UserID:       actor.UserID,
+		Limit:        req.Limit + 1,
+	}
+	if req.After != "" {
+		c, err := decodeCursor(req.After)
+		if err != nil {
+			return nil, err
+		}
+		params.CreatedAt, params.ID = c.CreatedAt, c.ID
+	}
+
+	// Fetch one extra row to learn whether another page follows
+	replies, err := s.queries.ListThreadReplies(ctx, params)
+	if err != nil {
+		return nil, storageError(err, "failed to get thread replies")
+	}
+
+	following, err := s.queries.IsFollowingThread(ctx, storage.IsFollowingThreadParams{
+		MessageID: root.ID,
+		UserID:    actor.UserID,
+	})
+	if err != nil {
+		return nil, storageError(err, "failed to check thread follow")
+	}
+
+	rootResponse, err := s.messageResponse(ctx, root)
+	if err != nil {
+		return nil, err
+	}
+
+	page := &ThreadPage{
+		Root:      *rootResponse,
+		Replies:   []MessageResponse{},
+		Following: following,
+	}
+	if len(replies) > int(req.Limit) {
+		replies = replies[:req.Limit]
+		page.HasMore = true
+	}
+
+	for _, reply := range replies {
+		page.Replies = append(page.Replies, newMessageResponse(reply))
+	}
+	if err := attachMentions(ctx, s.queries, page.Replies); err != nil {
+		return nil, err
+	}
+
+	if len(replies) > 0 {
+		last := replies[len(replies)-1]
+		page.NextCursor = encodeCursor(last.CreatedAt, last.ID)
+	}
+
+	return page, nil
+}
+
+// FollowThread subscribes the caller to reply notifications for the thread
+// that messageID roots or belongs to.
+func (s *MessageService) FollowThread(ctx context.Context, messageID pgtype.UUID) error {
+	actor, err := requireActor(ctx)
+	if err != nil {
+		return err
+	}
+	if !messageID.Valid {
+		return invalidArgument("message ID is required")
+	}
+
+	if _, _, err := s.authz.requireMessageAccess(ctx, messageID, actor.UserID); err != nil {
+		return err
+	}
+
+	root, err := resolveThreadRoot(ctx, s.queries, messageID)
+	if err != nil {
+		return err
+	}
+
+	if err := s.queries.FollowThread(ctx, storage.FollowThreadParams{
+		MessageID: root.ID,
+		UserID:    actor.UserID,
+	}); err != nil {
+		return storageError(err, "failed to follow thread")
+	}
+
+	return nil
+}
+
+func (s *MessageService) UnfollowThread(ctx context.Context, messageID pgtype.UUID) error {
+	actor, err := requireActor(ctx)
+	if err != nil {
+		return err
+	}
+	if !messageID.Valid {
+		return invalidArgument("message ID is required")
+	}
+
+	if _, _, err := s.authz.requireMessageAccess(ctx, messageID, actor.UserID); err != nil {
+		return err
+	}
+
+	root, err := resolveThreadRoot(ctx, s.queries, messageID)
+	if err != nil {
+		return err
+	}
+
+	if err := s.queries.UnfollowThread(ctx, storage.UnfollowThreadParams{
+		MessageID: root.ID,
+		UserID:    actor.UserID,
+	}); err != nil {
+		return storageError(err, "failed to unfollow thread")
+	}
+
+	return nil
+}
@@ -0,0 +1,83 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/rand/v2"
+	"time"
+
+	"github.com/felipedavid/chatting/storage"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+const (
+	maxTxAttempts = 5
+	txRetryDelay  = 10 * time.Millisecond
+)
+
+// TxRunner runs fn as a single unit of work. fn may be called more than once,
+// so it must not have side effects outside the queries it is given.
+type TxRunner interface {
+	InTx(ctx context.Context, fn func(q *storage.Queries) error) error
+}
+
+// PoolTxRunner runs units of work in serializable transactions on a pgxpool,
+// retrying the ones Postgres aborts because of concurrent updates.
+type PoolTxRunner struct {
+	pool    *pgxpool.Pool
+	queries *storage.Queries
+}
+
+func NewPoolTxRunner(pool *pgxpool.Pool, queries *storage.Queries) *PoolTxRunner {
+	return &PoolTxRunner{pool: pool, queries: queries}
+}
+
+func (r *PoolTxRunner) InTx(ctx context.Context, fn func(q *storage.Queries) error) error {
+	var err error
+	for attempt := 1; attempt <= maxTxAttempts; attempt++ {
+		err = r.runOnce(ctx, fn)
+		if err == nil || !isRetryable(err) {
+			return err
+		}
+
+		// Back off with jitter so the transactions that collided do not
+		// collide again.
+		delay := txRetryDelay*time.Duration(attempt) + rand.N(txRetryDelay)
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(delay):
+		}
+	}
+
+	return err
+}
+
+func (r *PoolTxRunner) runOnce(ctx context.Context, fn func(q *storage.Queries) error) error {
+	tx, err := r.pool.BeginTx(ctx, pgx.TxOptions{IsoLevel: pgx.Serializable})
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	if err := fn(r.queries.WithTx(tx)); err != nil {
+		return err
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return storageError(err, "failed to commit transaction")
+	}
+
+	return nil
+}
+
+func isRetryable(err error) bool {
+	var pgErr *pgconn.PgError
+	if !errors.As(err, &pgErr) {
+		return false
+	}
+	return pgErr.Code == pgSerializationFailure || pgErr.Code == pgDeadlockDetected
+}
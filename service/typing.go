@@ -0,0 +1,310 @@
+package service
+
+import (
+	"context"
+	"log/slog"
+	"sync"
+	"time"
+
+	"github.com/felipedavid/chatting/event"
+	"github.com/felipedavid/chatting/storage"
+	"github.com/jackc/pgx/v5/pgtype"
+)
+
+// Activities a typing indicator can show.
+const (
+	ActivityTyping    = "typing"
+	ActivityRecording = "recording"
+)
+
+const (
+	// typingTimeout is how long an indicator lasts unless the device renews
+	// it. Clients should expire indicators after it too, in case the stop
+	// signal is lost.
+	typingTimeout = 6 * time.Second
+
+	// Each device may send typingBurst signals at once and earns another
+	// one every typingRefill.
+	typingBurst  = 5
+	typingRefill = time.Second
+)
+
+// TypingService fans out ephemeral typing and recording indicators. They
+// live only in the memory of the instance the typing device is connected
+// to and are never stored.
+type TypingService struct {
+	queries *storage.Queries
+	events  notifier
+	authz   authorizer
+	limiter *signalLimiter
+
+	mu     sync.Mutex
+	active map[typingKey]*typingState
+}
+
+func NewTypingService(queries *storage.Queries, publisher event.Publisher) *TypingService {
+	return &TypingService{
+		queries: queries,
+		events:  notifier{publisher: publisher},
+		authz:   authorizer{queries: queries},
+		limiter: newSignalLimiter(typingBurst, typingRefill),
+		active:  make(map[typingKey]*typingState),
+	}
+}
+
+// TypingIndicator tells participants that a user started or stopped an
+// activity in the conversation. An active indicator lasts TimeoutMS unless
+// renewed.
+type TypingIndicator struct {
+	ConversationID pgtype.UUID `json:"conversation_id"`
+	UserID         pgtype.UUID `json:"user_id"`
+	Activity       string      `json:"activity"`
+	Active         bool        `json:"active"`
+	TimeoutMS      int64       `json:"timeout_ms,omitempty"`
+}
+
+type typingKey struct {
+	deviceID       pgtype.UUID
+	conversationID pgtype.UUID
+}
+
+type typingState struct {
+	userID      pgtype.UUID
+	activity    string
+	publishedAt time.Time
+	timer       *time.Timer
+}
+
+// StartTyping shows the caller as typing or recording in a conversation
+// until they stop, or for typingTimeout after their last start.
+func (s *TypingService) StartTyping(ctx context.Context, conversationID pgtype.UUID, activity string) error {
+	actor, err := requireActor(ctx)
+	if err != nil {
+		return err
+	}
+	if !conversationID.Valid {
+		return invalidArgument("conversation ID is required")
+	}
+	if activity == "" {
+		activity = ActivityTyping
+	}
+	if activity != ActivityTyping && activity != ActivityRecording {
+		return invalidArgument("activity must be %s or %s", ActivityTyping, ActivityRecording)
+	}
+	if !s.limiter.allow(actor.DeviceID) {
+		return rateLimited("too many typing signals")
+	}
+
+	if _, err := s.authz.requireParticipant(ctx, conversationID, actor.UserID); err != nil {
+		return err
+	}
+
+	key := typingKey{deviceID: actor.DeviceID, conversationID: conversationID}
+
+	s.mu.Lock()
+	state := s.active[key]
+	if state == nil || state.activity != activity {
+		if state != nil {
+			state.timer.Stop()
+		}
+		state = &typingState{userID: actor.UserID, activity: activity}
+		state.timer = time.AfterFunc(typingTimeout, func() { s.expire(key, state) })
+		s.active[key] = state
+	} else {
+		state.timer.Reset(typingTimeout)
+	}
+
+	// Renewals are only passed on once receivers would otherwise be close to
+	// expiring the indicator themselves
+	publish := time.Since(state.publishedAt) >= typingTimeout/2
+	if publish {
+		state.publishedAt = time.Now()
+	}
+	s.mu.Unlock()
+
+	if publish {
+		s.send(ctx, TypingIndicator{
+			ConversationID: conversationID,
+			UserID:         actor.UserID,
+			Activity:       activity,
+			Active:         true,
+			TimeoutMS:      typingTimeout.Milliseconds(),
+		})
+	}
+
+	return nil
+}
+
+// StopTyping clears the caller's indicator in a conversation. Stopping an
+// indicator that is not shown does nothing.
+func (s *TypingService) StopTyping(ctx context.Context, conversationID pgtype.UUID) error {
+	actor, err := requireActor(ctx)
+	if err != nil {
+		return err
+	}
+	if !conversationID.Valid {
+		return invalidArgument("conversation ID is required")
+	}
+	if !s.limiter.allow(actor.DeviceID) {
+		return rateLimited("too many typing signals")
+	}
+
+	key := typingKey{deviceID: actor.DeviceID, conversationID: conversationID}
+
+	s.mu.Lock()
+	state := s.active[key]
+	if state != nil {
+		state.timer.Stop()
+		delete(s.active, key)
+	}
+	s.mu.Unlock()
+
+	if state != nil {
+		s.stopped(ctx, key, state)
+	}
+
+	return nil
+}
+
+// StopDevice clears every indicator of the caller's device, for when it
+// disconnects.
+func (s *TypingService) StopDevice(ctx context.Context) {
+	actor, ok := ActorFromContext(ctx)
+	if !ok {
+		return
+	}
+
+	stopped := make(map[typingKey]*typingState)
+
+	s.mu.Lock()
+	for key, state := range s.active {
+		if key.deviceID == actor.DeviceID {
+			state.timer.Stop()
+			delete(s.active, key)
+			stopped[key] = state
+		}
+	}
+	s.mu.Unlock()
+
+	for key, state := range stopped {
+		s.stopped(ctx, key, state)
+	}
+}
+
+// expire clears an indicator its device did not renew in time.
+func (s *TypingService) expire(key typingKey, state *typingState) {
+	s.mu.Lock()
+	current := s.active[key] == state
+	if current {
+		delete(s.active, key)
+	}
+	s.mu.Unlock()
+
+	if current {
+		s.stopped(context.Background(), key, state)
+	}
+}
+
+// stopped clears an indicator for the other participants, unless another
+// device of the same user still shows one in the conversation.
+func (s *TypingService) stopped(ctx context.Context, key typingKey, state *typingState) {
+	s.mu.Lock()
+	for other, otherState := range s.active {
+		if other.conversationID == key.conversationID && otherState.userID == state.userID {
+			s.mu.Unlock()
+			return
+		}
+	}
+	s.mu.Unlock()
+
+	s.send(ctx, TypingIndicator{
+		ConversationID: key.conversationID,
+		UserID:         state.userID,
+		Activity:       state.activity,
+	})
+}
+
+// send delivers an indicator to the other participants of its
+// conversation. The typing user's own devices do not need it.
+func (s *TypingService) send(ctx context.Context, indicator TypingIndicator) {
+	participants, err := s.queries.ListConversationParticipants(ctx, indicator.ConversationID)
+	if err != nil {
+		slog.Warn("failed to list participants", "conversation_id", indicator.ConversationID, "error", err)
+		return
+	}
+
+	var recipients []pgtype.UUID
+	for _, participant := range participants {
+		if participant.UserID != indicator.UserID {
+			recipients = append(recipients, participant.UserID)
+		}
+	}
+
+	s.events.notify(ctx, recipients, event.TypingUpdated, indicator.ConversationID, indicator)
+}
+
+// signalLimiter is a token bucket per device for signals that are cheap to
+// send but fanned out to every participant.
+type signalLimiter struct {
+	burst  float64
+	refill time.Duration
+
+	mu      sync.Mutex
+	buckets map[pgtype.UUID]*signalBucket
+	pruned  time.Time
+}
+
+type signalBucket struct {
+	tokens  float64
+	updated time.Time
+}
+
+func newSignalLimiter(burst int, refill time.Duration) *signalLimiter {
+	return &signalLimiter{
+		burst:   float64(burst),
+		refill:  refill,
+		buckets: make(map[pgtype.UUID]*signalBucket),
+		pruned:  time.Now(),
+	}
+}
+
+// allow takes a token from the device's bucket, reporting false if it is
+// empty.
+func (l *signalLimiter) allow(deviceID pgtype.UUID) bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := time.Now()
+	l.prune(now)
+
+	b := l.buckets[deviceID]
+	if b == nil {
+		b = &signalBucket{tokens: l.burst, updated: now}
+		l.buckets[deviceID] = b
+	}
+
+	b.tokens = min(l.burst, b.tokens+float64(now.Sub(b.updated))/float64(l.refill))
+	b.updated = now
+
+	if b.tokens < 1 {
+		return false
+	}
+	b.tokens--
+	return true
+}
+
+// prune drops the buckets that have refilled completely, which behave just
+// like missing ones, at most once per full refill.
+func (l *signalLimiter) prune(now time.Time) {
+	full := time.Duration(l.burst) * l.refill
+	if now.Sub(l.pruned) < full {
+		return
+	}
+	l.pruned = now
+
+	for deviceID, b := range l.buckets {
+		if now.Sub(b.updated) >= full {
+			delete(l.buckets, deviceID)
+		}
+	}
+}
@@ -2,44 +2,42 @@ package service
 
 import (
 	"context"
-	"fmt"
+	"errors"
 
+	"github.com/felipedavid/chatting/event"
 	"github.com/felipedavid/chatting/storage"
 	"github.com/jackc/pgx/v5"
 	"github.com/jackc/pgx/v5/pgtype"
 )
 
 type UserService struct {
-	queries *storage.Queries
+	queries   *storage.Queries
+	tx        TxRunner
+	events    notifier
+	publisher event.Publisher
 }
 
-func NewUserService(queries *storage.Queries) *UserService {
-	return &UserService{queries: queries}
+func NewUserService(queries *storage.Queries, tx TxRunner, publisher event.Publisher) *UserService {
+	return &UserService{queries: queries, tx: tx, events: notifier{publisher: publisher}, publisher: publisher}
 }
 
 type CreateUserRequest struct {
-	PhoneNumber string
-	DisplayName string
-	About       string
+	PhoneNumber string `json:"phone_number"`
+	DisplayName string `json:"display_name"`
+	About       string `json:"about"`
 }
 
 type UserResponse struct {
-	ID          pgtype.UUID
-	PhoneNumber string
-	DisplayName string
-	About       string
-	CreatedAt   pgtype.Timestamptz
+	ID          pgtype.UUID        `json:"id"`
+	PhoneNumber string             `json:"phone_number"`
+	DisplayName string             `json:"display_name"`
+	About       string             `json:"about"`
+	CreatedAt   pgtype.Timestamptz `json:"created_at"`
 }
 
 func (s *UserService) CreateUser(ctx context.Context, req CreateUserRequest) (*UserResponse, error) {
 	if req.PhoneNumber == "" {
-		return nil, fmt.Errorf("phone number is required")
-	}
-
-	// Check if user already exists
-	existingUser, err := s.queries.GetUserByPhoneNumber(ctx, req.PhoneNumber)
-	if err == nil && existingUser.ID.Valid {
-		return nil, fmt.Errorf("user with phone number %s already exists", req.PhoneNumber)
+		return nil, invalidArgument("phone number is required")
 	}
 
 	params := storage.CreateUserParams{
@@ -48,9 +46,26 @@ func (s *UserService) CreateUser(ctx context.Context, req CreateUserRequest) (*U
 		About:       pgtype.Text{String: req.About, Valid: req.About != ""},
 	}
 
-	user, err := s.queries.CreateUser(ctx, params)
+	var user storage.User
+	err := s.tx.InTx(ctx, func(q *storage.Queries) error {
+		// Check if user already exists
+		_, err := q.GetUserByPhoneNumber(ctx, req.PhoneNumber)
+		if err == nil {
+			return alreadyExists("user with phone number %s already exists", req.PhoneNumber)
+		}
+		if !errors.Is(err, pgx.ErrNoRows) {
+			return storageError(err, "failed to get user by phone number")
+		}
+
+		user, err = q.CreateUser(ctx, params)
+		if err != nil {
+			return storageError(err, "failed to create user")
+		}
+
+		return nil
+	})
 	if err != nil {
-		return nil, fmt.Errorf("failed to create user: %w", err)
+		return nil, err
 	}
 
 	return &UserResponse{
@@ -65,10 +80,10 @@ func (s *UserService) CreateUser(ctx context.Context, req CreateUserRequest) (*U
 func (s *UserService) GetUser(ctx context.Context, userID pgtype.UUID) (*UserResponse, error) {
 	user, err := s.queries.GetUser(ctx, userID)
 	if err != nil {
-		if err == pgx.ErrNoRows {
-			return nil, fmt.Errorf("user not found")
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, notFound("user not found")
 		}
-		return nil, fmt.Errorf("failed to get user: %w", err)
+		return nil, storageError(err, "failed to get user")
 	}
 
 	return &UserResponse{
@@ -82,15 +97,15 @@ func (s *UserService) GetUser(ctx context.Context, userID pgtype.UUID) (*UserRes
 
 func (s *UserService) GetUserByPhoneNumber(ctx context.Context, phoneNumber string) (*UserResponse, error) {
 	if phoneNumber == "" {
-		return nil, fmt.Errorf("phone number is required")
+		return nil, invalidArgument("phone number is required")
 	}
 
 	user, err := s.queries.GetUserByPhoneNumber(ctx, phoneNumber)
 	if err != nil {
-		if err == pgx.ErrNoRows {
-			return nil, fmt.Errorf("user not found")
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, notFound("user not found")
 		}
-		return nil, fmt.Errorf("failed to get user by phone number: %w", err)
+		return nil, storageError(err, "failed to get user by phone number")
 	}
 
 	return &UserResponse{
@@ -105,7 +120,7 @@ func (s *UserService) GetUserByPhoneNumber(ctx context.Context, phoneNumber stri
 func (s *UserService) ListUsers(ctx context.Context) ([]UserResponse, error) {
 	users, err := s.queries.ListUsers(ctx)
 	if err != nil {
-		return nil, fmt.Errorf("failed to list users: %w", err)
+		return nil, storageError(err, "failed to list users")
 	}
 
 	var responses []UserResponse
@@ -122,10 +137,185 @@ func (s *UserService) ListUsers(ctx context.Context) ([]UserResponse, error) {
 	return responses, nil
 }
 
-func (s *UserService) DeleteUser(ctx context.Context, userID pgtype.UUID) error {
-	err := s.queries.DeleteUser(ctx, userID)
+// DeleteUser deletes the caller's account along with its devices and sessions,
+// closing their sockets. They leave every conversation, handing the ones they
+// own to the longest-standing admin or, failing that, member.
+func (s *UserService) DeleteUser(ctx context.Context) error {
+	actor, err := requireActor(ctx)
+	if err != nil {
+		return err
+	}
+
+	var removed []event.Event
+	var devices []storage.UserDevice
+	err = s.tx.InTx(ctx, func(q *storage.Queries) error {
+		removed = nil
+
+		memberships, err := q.ListUserConversations(ctx, actor.UserID)
+		if err != nil {
+			return storageError(err, "failed to list user conversations")
+		}
+
+		for _, membership := range memberships {
+			if membership.Role.String == RoleOwner {
+				if err := transferOwnership(ctx, q, membership.ConversationID, actor.UserID); err != nil {
+					return err
+				}
+			}
+
+			e, err := s.events.record(ctx, q, event.ParticipantRemoved, membership.ConversationID, ParticipantResponse{
+				ConversationID: membership.ConversationID,
+				UserID:         actor.UserID,
+			})
+			if err != nil {
+				return err
+			}
+			removed = append(removed, e)
+		}
+
+		devices, err = q.ListUserDevices(ctx, actor.UserID)
+		if err != nil {
+			return storageError(err, "failed to list devices")
+		}
+
+		if err := q.ClearConversationCreator(ctx, actor.UserID); err != nil {
+			return storageError(err, "failed to clear conversation creator")
+		}
+
+		// Participants, devices and sessions go with the user
+		if err := q.DeleteUser(ctx, actor.UserID); err != nil {
+			return storageError(err, "failed to delete user")
+		}
+
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	s.events.publish(ctx, removed...)
+	for _, device := range devices {
+		publishGlobal(ctx, s.publisher, event.SessionRevoked, revokedSession{
+			UserID:   actor.UserID,
+			DeviceID: device.ID,
+		})
+	}
+
+	return nil
+}
+
+// transferOwnership makes the admin who joined first, or else the member who
+// did, the owner of a conversation its owner is leaving. A conversation with
+// no one else left keeps no owner.
+func transferOwnership(ctx context.Context, q *storage.Queries, conversationID, ownerID pgtype.UUID) error {
+	participants, err := q.ListConversationParticipants(ctx, conversationID)
+	if err != nil {
+		return storageError(err, "failed to list participants")
+	}
+
+	var successor pgtype.UUID
+	for _, participant := range participants {
+		if participant.UserID == ownerID {
+			continue
+		}
+		if participant.Role.String == RoleAdmin {
+			successor = participant.UserID
+			break
+		}
+		if !successor.Valid {
+			successor = participant.UserID
+		}
+	}
+	if !successor.Valid {
+		return nil
+	}
+
+	if _, err := q.UpdateParticipantRole(ctx, storage.UpdateParticipantRoleParams{
+		ConversationID: conversationID,
+		UserID:         successor,
+		Role:           pgtype.Text{String: RoleOwner, Valid: true},
+	}); err != nil {
+		return storageError(err, "failed to transfer ownership")
+	}
+
+	return nil
+}
+
+func (s *UserService) ListUserDevices(ctx context.Context) ([]UserDeviceResponse, error) {
+	actor, err := requireActor(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	devices, err := s.queries.ListUserDevices(ctx, actor.UserID)
+	if err != nil {
+		return nil, storageError(err, "failed to list devices")
+	}
+
+	var responses []UserDeviceResponse
+	for _, device := range devices {
+		responses = append(responses, UserDeviceResponse{
+			ID:         device.ID,
+			UserID:     device.UserID,
+			DeviceName: device.DeviceName.String,
+			DeviceType: device.DeviceType.String,
+			CreatedAt:  device.CreatedAt,
+		})
+	}
+
+	return responses, nil
+}
+
+// DeleteUserDevice unregisters one of the caller's devices and revokes every
+// session bound to it.
+func (s *UserService) DeleteUserDevice(ctx context.Context, deviceID pgtype.UUID) error {
+	actor, err := requireActor(ctx)
+	if err != nil {
+		return err
+	}
+	if !deviceID.Valid {
+		return invalidArgument("device ID is required")
+	}
+
+	device, err := s.queries.GetUserDevice(ctx, deviceID)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return notFound("device not found")
+		}
+		return storageError(err, "failed to get device")
+	}
+	if device.UserID != actor.UserID {
+		return notFound("device not found")
+	}
+
+	err = s.tx.InTx(ctx, func(q *storage.Queries) error {
+		if err := q.RevokeDeviceSessions(ctx, deviceID); err != nil {
+			return storageError(err, "failed to revoke device sessions")
+		}
+
+		if err := q.DeleteUserDevice(ctx, deviceID); err != nil {
+			return storageError(err, "failed to delete device")
+		}
+
+		return nil
+	})
 	if err != nil {
-		return fmt.Errorf("failed to delete user: %w", err)
+		return err
 	}
+
+	// Close the device's open sockets along with its sessions
+	publishGlobal(ctx, s.publisher, event.SessionRevoked, revokedSession{
+		UserID:   actor.UserID,
+		DeviceID: deviceID,
+	})
+
 	return nil
 }
+
+type UserDeviceResponse struct {
+	ID         pgtype.UUID        `json:"id"`
+	UserID     pgtype.UUID        `json:"user_id"`
+	DeviceName string             `json:"device_name"`
+	DeviceType string             `json:"device_type"`
+	CreatedAt  pgtype.Timestamptz `json:"created_at"`
+}
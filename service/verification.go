@@ -0,0 +1,192 @@
+package service
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/subtle"
+	"errors"
+	"fmt"
+	"math/big"
+	"regexp"
+	"time"
+
+	"github.com/felipedavid/chatting/auth"
+	"github.com/felipedavid/chatting/sms"
+	"github.com/felipedavid/chatting/storage"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgtype"
+)
+
+const (
+	verificationCodeDigits = 6
+	verificationCodeTTL    = 10 * time.Minute
+	maxVerificationTries   = 5
+	maxCodesPerWindow      = 5
+	codeRequestWindow      = time.Hour
+)
+
+// phoneNumberPattern accepts E.164 numbers, e.g. +5511912345678.
+var phoneNumberPattern = regexp.MustCompile(`^\+[1-9][0-9]{7,14}$`)
+
+type VerificationService struct {
+	queries *storage.Queries
+	tx      TxRunner
+	sender  sms.Sender
+	tokens  *auth.TokenManager
+}
+
+func NewVerificationService(queries *storage.Queries, tx TxRunner, sender sms.Sender, tokens *auth.TokenManager) *VerificationService {
+	return &VerificationService{queries: queries, tx: tx, sender: sender, tokens: tokens}
+}
+
+type VerifyCodeRequest struct {
+	PhoneNumber string `json:"phone_number"`
+	Code        string `json:"code"`
+	DisplayName string `json:"display_name"`
+}
+
+type VerifyCodeResponse struct {
+	User    UserResponse `json:"user"`
+	Created bool         `json:"created"`
+}
+
+// RequestCode sends a one-time code to phoneNumber. Only the most recent code
+// for a number can be verified.
+func (s *VerificationService) RequestCode(ctx context.Context, phoneNumber string) error {
+	if !phoneNumberPattern.MatchString(phoneNumber) {
+		return invalidArgument("phone number must be in E.164 format")
+	}
+
+	code, err := generateVerificationCode()
+	if err != nil {
+		return err
+	}
+
+	// Counting and inserting in one transaction keeps concurrent requests
+	// from all slipping under the limit
+	err = s.tx.InTx(ctx, func(q *storage.Queries) error {
+		recent, err := q.CountRecentPhoneVerifications(ctx, storage.CountRecentPhoneVerificationsParams{
+			PhoneNumber: phoneNumber,
+			CreatedAt:   pgtype.Timestamptz{Time: time.Now().Add(-codeRequestWindow), Valid: true},
+		})
+		if err != nil {
+			return storageError(err, "failed to count recent verifications")
+		}
+		if recent >= maxCodesPerWindow {
+			return rateLimited("too many verification codes requested, try again later")
+		}
+
+		if _, err := q.CreatePhoneVerification(ctx, storage.CreatePhoneVerificationParams{
+			PhoneNumber: phoneNumber,
+			CodeHash:    s.tokens.HashCode(phoneNumber, code),
+			ExpiresAt:   pgtype.Timestamptz{Time: time.Now().Add(verificationCodeTTL), Valid: true},
+		}); err != nil {
+			return storageError(err, "failed to create verification")
+		}
+
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	body := fmt.Sprintf("Your verification code is %s", code)
+	if err := s.sender.Send(ctx, phoneNumber, body); err != nil {
+		return fmt.Errorf("failed to send verification code: %w", err)
+	}
+
+	return nil
+}
+
+// VerifyCode checks a code sent by RequestCode and returns the user that owns
+// the phone number, registering it on first login.
+func (s *VerificationService) VerifyCode(ctx context.Context, req VerifyCodeRequest) (*VerifyCodeResponse, error) {
+	if req.PhoneNumber == "" || req.Code == "" {
+		return nil, invalidArgument("phone number and code are required")
+	}
+
+	verification, err := s.queries.GetLatestPhoneVerification(ctx, req.PhoneNumber)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, notFound("no verification code was requested for this phone number")
+		}
+		return nil, storageError(err, "failed to get verification")
+	}
+
+	if verification.VerifiedAt.Valid {
+		return nil, invalidArgument("verification code has already been used")
+	}
+	if time.Now().After(verification.ExpiresAt.Time) {
+		return nil, invalidArgument("verification code has expired")
+	}
+	if verification.Attempts >= maxVerificationTries {
+		return nil, rateLimited("too many failed attempts, request a new code")
+	}
+
+	// Count the attempt before comparing so concurrent guesses cannot exceed
+	// the limit.
+	verification, err = s.queries.IncrementPhoneVerificationAttempts(ctx, verification.ID)
+	if err != nil {
+		return nil, storageError(err, "failed to record verification attempt")
+	}
+	if verification.Attempts > maxVerificationTries {
+		return nil, rateLimited("too many failed attempts, request a new code")
+	}
+
+	expected := []byte(verification.CodeHash)
+	actual := []byte(s.tokens.HashCode(req.PhoneNumber, req.Code))
+	if subtle.ConstantTimeCompare(expected, actual) != 1 {
+		return nil, invalidArgument("invalid verification code")
+	}
+
+	if _, err := s.queries.MarkPhoneVerificationVerified(ctx, verification.ID); err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, invalidArgument("verification code has already been used")
+		}
+		return nil, storageError(err, "failed to mark verification as verified")
+	}
+
+	var user storage.User
+	var created bool
+	err = s.tx.InTx(ctx, func(q *storage.Queries) error {
+		created = false
+		user, err = q.GetUserByPhoneNumber(ctx, req.PhoneNumber)
+		if errors.Is(err, pgx.ErrNoRows) {
+			user, err = q.CreateUser(ctx, storage.CreateUserParams{
+				PhoneNumber: req.PhoneNumber,
+				DisplayName: pgtype.Text{String: req.DisplayName, Valid: req.DisplayName != ""},
+			})
+			if err != nil {
+				return storageError(err, "failed to create user")
+			}
+			created = true
+		} else if err != nil {
+			return storageError(err, "failed to get user by phone number")
+		}
+
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return &VerifyCodeResponse{
+		User: UserResponse{
+			ID:          user.ID,
+			PhoneNumber: user.PhoneNumber,
+			DisplayName: user.DisplayName.String,
+			About:       user.About.String,
+			CreatedAt:   user.CreatedAt,
+		},
+		Created: created,
+	}, nil
+}
+
+func generateVerificationCode() (string, error) {
+	n, err := rand.Int(rand.Reader, big.NewInt(1_000_000))
+	if err != nil {
+		return "", fmt.Errorf("failed to generate verification code: %w", err)
+	}
+
+	return fmt.Sprintf("%0*d", verificationCodeDigits, n), nil
+}
@@ -0,0 +1,58 @@
+package sms
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"os"
+	"sync"
+	"time"
+)
+
+// Sender delivers a text message to a phone number. Production deployments
+// plug in a provider; the senders below are stand-ins for local development.
+type Sender interface {
+	Send(ctx context.Context, to, body string) error
+}
+
+// LogSender writes every message to the logger instead of sending it.
+type LogSender struct {
+	logger *slog.Logger
+}
+
+func NewLogSender(logger *slog.Logger) *LogSender {
+	return &LogSender{logger: logger}
+}
+
+func (s *LogSender) Send(ctx context.Context, to, body string) error {
+	s.logger.Info("SMS", "to", to, "body", body)
+	return nil
+}
+
+// FileSender appends every message to a file, which is handy for scripted
+// local flows that need to read the code back.
+type FileSender struct {
+	mu   sync.Mutex
+	path string
+}
+
+func NewFileSender(path string) *FileSender {
+	return &FileSender{path: path}
+}
+
+func (s *FileSender) Send(ctx context.Context, to, body string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	f, err := os.OpenFile(s.path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0o600)
+	if err != nil {
+		return fmt.Errorf("failed to open SMS file: %w", err)
+	}
+	defer f.Close()
+
+	if _, err := fmt.Fprintf(f, "%s\t%s\t%s\n", time.Now().Format(time.RFC3339), to, body); err != nil {
+		return fmt.Errorf("failed to write SMS: %w", err)
+	}
+
+	return nil
+}
@@ -209,7 +209,7 @@ func (q *Queries) IsUserContact(ctx context.Context, arg IsUserContactParams) (b
 }
 
 const listContactSuggestions = `-- name: ListContactSuggestions :many
-SELECT u.id, u.phone_number, u.display_name, u.about, u.created_at, 'mutual' as suggestion_type
+SELECT u.id, u.phone_number, u.display_name, u.about, u.created_at, u.last_seen_at, u.presence_visibility, 'mutual' as suggestion_type
 FROM users u
 WHERE u.id IN (
   SELECT contact_id FROM contacts c2 WHERE c2.user_id IN (
@@ -221,12 +221,14 @@ LIMIT 10
 `
 
 type ListContactSuggestionsRow struct {
-	ID             pgtype.UUID
-	PhoneNumber    string
-	DisplayName    pgtype.Text
-	About          pgtype.Text
-	CreatedAt      pgtype.Timestamptz
-	SuggestionType string
+	ID                 pgtype.UUID
+	PhoneNumber        string
+	DisplayName        pgtype.Text
+	About              pgtype.Text
+	CreatedAt          pgtype.Timestamptz
+	LastSeenAt         pgtype.Timestamptz
+	PresenceVisibility string
+	SuggestionType     string
 }
 
 func (q *Queries) ListContactSuggestions(ctx context.Context, userID pgtype.UUID) ([]ListContactSuggestionsRow, error) {
@@ -244,6 +246,8 @@ func (q *Queries) ListContactSuggestions(ctx context.Context, userID pgtype.UUID
 			&i.DisplayName,
 			&i.About,
 			&i.CreatedAt,
+			&i.LastSeenAt,
+			&i.PresenceVisibility,
 			&i.SuggestionType,
 		); err != nil {
 			return nil, err
@@ -13,11 +13,13 @@ import (
 
 const addConversationParticipant = `-- name: AddConversationParticipant :one
 INSERT INTO conversation_participants (
-  conversation_id, user_id, role
+  conversation_id, user_id, role, last_read_seq, last_delivered_seq
 ) VALUES (
-  $1, $2, $3
+  $1, $2, $3,
+  COALESCE((SELECT last_message_seq FROM conversations WHERE id = $1), 0),
+  COALESCE((SELECT last_message_seq FROM conversations WHERE id = $1), 0)
 )
-RETURNING conversation_id, user_id, role, joined_at
+RETURNING conversation_id, user_id, role, joined_at, last_read_seq, unread_mention_count, muted_until, pinned_at, archived_at, last_delivered_seq
 `
 
 type AddConversationParticipantParams struct {
@@ -26,6 +28,8 @@ type AddConversationParticipantParams struct {
 	Role           pgtype.Text
 }
 
+// New participants start with everything already sent marked as delivered
+// and read.
 func (q *Queries) AddConversationParticipant(ctx context.Context, arg AddConversationParticipantParams) (ConversationParticipant, error) {
 	row := q.db.QueryRow(ctx, addConversationParticipant, arg.ConversationID, arg.UserID, arg.Role)
 	var i ConversationParticipant
@@ -34,6 +38,73 @@ func (q *Queries) AddConversationParticipant(ctx context.Context, arg AddConvers
 		&i.UserID,
 		&i.Role,
 		&i.JoinedAt,
+		&i.LastReadSeq,
+		&i.UnreadMentionCount,
+		&i.MutedUntil,
+		&i.PinnedAt,
+		&i.ArchivedAt,
+		&i.LastDeliveredSeq,
+	)
+	return i, err
+}
+
+const advanceDeliveredWatermark = `-- name: AdvanceDeliveredWatermark :exec
+UPDATE conversation_participants
+SET last_delivered_seq = GREATEST(last_delivered_seq, $1::bigint)
+WHERE conversation_id = $2 AND user_id = $3
+`
+
+type AdvanceDeliveredWatermarkParams struct {
+	Seq            int64
+	ConversationID pgtype.UUID
+	UserID         pgtype.UUID
+}
+
+// Moves the participant's delivered watermark forward to seq, never back.
+func (q *Queries) AdvanceDeliveredWatermark(ctx context.Context, arg AdvanceDeliveredWatermarkParams) error {
+	_, err := q.db.Exec(ctx, advanceDeliveredWatermark, arg.Seq, arg.ConversationID, arg.UserID)
+	return err
+}
+
+const advanceReadWatermark = `-- name: AdvanceReadWatermark :one
+UPDATE conversation_participants cp
+SET last_read_seq = GREATEST(cp.last_read_seq, $1::bigint),
+    last_delivered_seq = GREATEST(cp.last_delivered_seq, $1::bigint),
+    unread_mention_count = (
+      SELECT COUNT(*) FROM message_mentions mm
+      JOIN messages m ON m.id = mm.message_id
+      WHERE mm.user_id = cp.user_id
+        AND mm.conversation_id = cp.conversation_id
+        AND m.seq > GREATEST(cp.last_read_seq, $1::bigint)
+        AND m.deleted_at IS NULL
+    )::int
+WHERE cp.conversation_id = $2 AND cp.user_id = $3
+RETURNING conversation_id, user_id, role, joined_at, last_read_seq, unread_mention_count, muted_until, pinned_at, archived_at, last_delivered_seq
+`
+
+type AdvanceReadWatermarkParams struct {
+	Seq            int64
+	ConversationID pgtype.UUID
+	UserID         pgtype.UUID
+}
+
+// Moves the participant's watermark forward to seq, never back, and recounts
+// the mentions of them that are still above it. What they read has also
+// reached them, so the delivered watermark follows.
+func (q *Queries) AdvanceReadWatermark(ctx context.Context, arg AdvanceReadWatermarkParams) (ConversationParticipant, error) {
+	row := q.db.QueryRow(ctx, advanceReadWatermark, arg.Seq, arg.ConversationID, arg.UserID)
+	var i ConversationParticipant
+	err := row.Scan(
+		&i.ConversationID,
+		&i.UserID,
+		&i.Role,
+		&i.JoinedAt,
+		&i.LastReadSeq,
+		&i.UnreadMentionCount,
+		&i.MutedUntil,
+		&i.PinnedAt,
+		&i.ArchivedAt,
+		&i.LastDeliveredSeq,
 	)
 	return i, err
 }
@@ -51,7 +122,7 @@ func (q *Queries) CountConversationParticipants(ctx context.Context, conversatio
 }
 
 const getConversationParticipant = `-- name: GetConversationParticipant :one
-SELECT conversation_id, user_id, role, joined_at FROM conversation_participants
+SELECT conversation_id, user_id, role, joined_at, last_read_seq, unread_mention_count, muted_until, pinned_at, archived_at, last_delivered_seq FROM conversation_participants
 WHERE conversation_id = $1 AND user_id = $2 LIMIT 1
 `
 
@@ -68,12 +139,18 @@ func (q *Queries) GetConversationParticipant(ctx context.Context, arg GetConvers
 		&i.UserID,
 		&i.Role,
 		&i.JoinedAt,
+		&i.LastReadSeq,
+		&i.UnreadMentionCount,
+		&i.MutedUntil,
+		&i.PinnedAt,
+		&i.ArchivedAt,
+		&i.LastDeliveredSeq,
 	)
 	return i, err
 }
 
 const getConversationParticipantWithDetails = `-- name: GetConversationParticipantWithDetails :one
-SELECT cp.conversation_id, cp.user_id, cp.role, cp.joined_at, u.phone_number, u.display_name
+SELECT cp.conversation_id, cp.user_id, cp.role, cp.joined_at, cp.last_read_seq, cp.unread_mention_count, cp.muted_until, cp.pinned_at, cp.archived_at, cp.last_delivered_seq, u.phone_number, u.display_name
 FROM conversation_participants cp
 JOIN users u ON cp.user_id = u.id
 WHERE cp.conversation_id = $1 AND cp.user_id = $2 LIMIT 1
@@ -85,12 +162,18 @@ type GetConversationParticipantWithDetailsParams struct {
 }
 
 type GetConversationParticipantWithDetailsRow struct {
-	ConversationID pgtype.UUID
-	UserID         pgtype.UUID
-	Role           pgtype.Text
-	JoinedAt       pgtype.Timestamptz
-	PhoneNumber    string
-	DisplayName    pgtype.Text
+	ConversationID     pgtype.UUID
+	UserID             pgtype.UUID
+	Role               pgtype.Text
+	JoinedAt           pgtype.Timestamptz
+	LastReadSeq        int64
+	UnreadMentionCount int32
+	MutedUntil         pgtype.Timestamptz
+	PinnedAt           pgtype.Timestamptz
+	ArchivedAt         pgtype.Timestamptz
+	LastDeliveredSeq   int64
+	PhoneNumber        string
+	DisplayName        pgtype.Text
 }
 
 func (q *Queries) GetConversationParticipantWithDetails(ctx context.Context, arg GetConversationParticipantWithDetailsParams) (GetConversationParticipantWithDetailsRow, error) {
@@ -101,12 +184,34 @@ func (q *Queries) GetConversationParticipantWithDetails(ctx context.Context, arg
 		&i.UserID,
 		&i.Role,
 		&i.JoinedAt,
+		&i.LastReadSeq,
+		&i.UnreadMentionCount,
+		&i.MutedUntil,
+		&i.PinnedAt,
+		&i.ArchivedAt,
+		&i.LastDeliveredSeq,
 		&i.PhoneNumber,
 		&i.DisplayName,
 	)
 	return i, err
 }
 
+const incrementUnreadMentions = `-- name: IncrementUnreadMentions :exec
+UPDATE conversation_participants
+SET unread_mention_count = unread_mention_count + 1
+WHERE conversation_id = $1 AND user_id = ANY($2::uuid[])
+`
+
+type IncrementUnreadMentionsParams struct {
+	ConversationID pgtype.UUID
+	UserIds        []pgtype.UUID
+}
+
+func (q *Queries) IncrementUnreadMentions(ctx context.Context, arg IncrementUnreadMentionsParams) error {
+	_, err := q.db.Exec(ctx, incrementUnreadMentions, arg.ConversationID, arg.UserIds)
+	return err
+}
+
 const isUserInConversation = `-- name: IsUserInConversation :one
 SELECT EXISTS(
   SELECT 1 FROM conversation_participants
@@ -127,7 +232,7 @@ func (q *Queries) IsUserInConversation(ctx context.Context, arg IsUserInConversa
 }
 
 const listConversationParticipants = `-- name: ListConversationParticipants :many
-SELECT conversation_id, user_id, role, joined_at FROM conversation_participants
+SELECT conversation_id, user_id, role, joined_at, last_read_seq, unread_mention_count, muted_until, pinned_at, archived_at, last_delivered_seq FROM conversation_participants
 WHERE conversation_id = $1
 ORDER BY joined_at ASC
 `
@@ -146,6 +251,12 @@ func (q *Queries) ListConversationParticipants(ctx context.Context, conversation
 			&i.UserID,
 			&i.Role,
 			&i.JoinedAt,
+			&i.LastReadSeq,
+			&i.UnreadMentionCount,
+			&i.MutedUntil,
+			&i.PinnedAt,
+			&i.ArchivedAt,
+			&i.LastDeliveredSeq,
 		); err != nil {
 			return nil, err
 		}
@@ -158,7 +269,7 @@ func (q *Queries) ListConversationParticipants(ctx context.Context, conversation
 }
 
 const listConversationParticipantsWithDetails = `-- name: ListConversationParticipantsWithDetails :many
-SELECT cp.conversation_id, cp.user_id, cp.role, cp.joined_at, u.phone_number, u.display_name
+SELECT cp.conversation_id, cp.user_id, cp.role, cp.joined_at, cp.last_read_seq, cp.unread_mention_count, cp.muted_until, cp.pinned_at, cp.archived_at, cp.last_delivered_seq, u.phone_number, u.display_name
 FROM conversation_participants cp
 JOIN users u ON cp.user_id = u.id
 WHERE cp.conversation_id = $1
@@ -166,12 +277,18 @@ ORDER BY cp.joined_at ASC
 `
 
 type ListConversationParticipantsWithDetailsRow struct {
-	ConversationID pgtype.UUID
-	UserID         pgtype.UUID
-	Role           pgtype.Text
-	JoinedAt       pgtype.Timestamptz
-	PhoneNumber    string
-	DisplayName    pgtype.Text
+	ConversationID     pgtype.UUID
+	UserID             pgtype.UUID
+	Role               pgtype.Text
+	JoinedAt           pgtype.Timestamptz
+	LastReadSeq        int64
+	UnreadMentionCount int32
+	MutedUntil         pgtype.Timestamptz
+	PinnedAt           pgtype.Timestamptz
+	ArchivedAt         pgtype.Timestamptz
+	LastDeliveredSeq   int64
+	PhoneNumber        string
+	DisplayName        pgtype.Text
 }
 
 func (q *Queries) ListConversationParticipantsWithDetails(ctx context.Context, conversationID pgtype.UUID) ([]ListConversationParticipantsWithDetailsRow, error) {
@@ -188,6 +305,12 @@ func (q *Queries) ListConversationParticipantsWithDetails(ctx context.Context, c
 			&i.UserID,
 			&i.Role,
 			&i.JoinedAt,
+			&i.LastReadSeq,
+			&i.UnreadMentionCount,
+			&i.MutedUntil,
+			&i.PinnedAt,
+			&i.ArchivedAt,
+			&i.LastDeliveredSeq,
 			&i.PhoneNumber,
 			&i.DisplayName,
 		); err != nil {
@@ -201,41 +324,177 @@ func (q *Queries) ListConversationParticipantsWithDetails(ctx context.Context, c
 	return items, nil
 }
 
-const listUserConversations = `-- name: ListUserConversations :many
-SELECT cp.conversation_id, cp.user_id, cp.role, cp.joined_at, c.title, c.is_group, c.created_at as conversation_created_at
+const listInbox = `-- name: ListInbox :many
+SELECT c.id, c.is_group, c.title,
+       other.user_id AS other_user_id,
+       other.display_name AS other_display_name,
+       other.phone_number AS other_phone_number,
+       lm.id AS last_message_id,
+       lm.sender_id AS last_message_sender_id,
+       sender.display_name AS last_message_sender_name,
+       lm.content AS last_message_content,
+       lm.message_type AS last_message_type,
+       lm.created_at AS last_message_at,
+       lm.deleted_at AS last_message_deleted_at,
+       (c.last_message_seq - cp.last_read_seq - (
+         SELECT COUNT(*) FROM hidden_messages h
+         JOIN messages hm ON hm.id = h.message_id
+         WHERE h.user_id = cp.user_id
+           AND hm.conversation_id = cp.conversation_id
+           AND hm.seq > cp.last_read_seq
+       ))::bigint AS unread_count,
+       cp.unread_mention_count, cp.muted_until, cp.pinned_at, cp.archived_at,
+       GREATEST(lm.created_at, cp.joined_at)::timestamptz AS activity_at
 FROM conversation_participants cp
-JOIN conversations c ON cp.conversation_id = c.id
+JOIN conversations c ON c.id = cp.conversation_id
+LEFT JOIN LATERAL (
+  SELECT m.id, m.conversation_id, m.sender_id, m.content, m.message_type, m.reply_to_id, m.created_at, m.seq, m.sender_device_id, m.client_message_id, m.edited_at, m.deleted_at, m.forward_count, m.thread_root_id, m.thread_reply_count, m.thread_last_reply_at, m.payload FROM messages m
+  WHERE m.conversation_id = c.id
+    AND NOT EXISTS (
+      SELECT 1 FROM hidden_messages h
+      WHERE h.message_id = m.id AND h.user_id = cp.user_id
+    )
+  ORDER BY m.seq DESC
+  LIMIT 1
+) lm ON true
+LEFT JOIN users sender ON sender.id = lm.sender_id
+LEFT JOIN LATERAL (
+  SELECT u.id AS user_id, u.display_name, u.phone_number
+  FROM conversation_participants op
+  JOIN users u ON u.id = op.user_id
+  WHERE op.conversation_id = c.id AND op.user_id != cp.user_id
+  LIMIT 1
+) other ON NOT c.is_group
 WHERE cp.user_id = $1
-ORDER BY cp.joined_at DESC
+  AND ($2::timestamptz IS NULL
+    OR (GREATEST(lm.created_at, cp.joined_at), c.id) < ($2::timestamptz, $3::uuid))
+ORDER BY activity_at DESC, c.id DESC
+LIMIT $4
 `
 
-type ListUserConversationsRow struct {
-	ConversationID        pgtype.UUID
-	UserID                pgtype.UUID
-	Role                  pgtype.Text
-	JoinedAt              pgtype.Timestamptz
-	Title                 pgtype.Text
+type ListInboxParams struct {
+	UserID     pgtype.UUID
+	ActivityAt pgtype.Timestamptz
+	ID         pgtype.UUID
+	Limit      int32
+}
+
+type ListInboxRow struct {
+	ID                    pgtype.UUID
 	IsGroup               bool
-	ConversationCreatedAt pgtype.Timestamptz
+	Title                 pgtype.Text
+	OtherUserID           pgtype.UUID
+	OtherDisplayName      pgtype.Text
+	OtherPhoneNumber      pgtype.Text
+	LastMessageID         pgtype.UUID
+	LastMessageSenderID   pgtype.UUID
+	LastMessageSenderName pgtype.Text
+	LastMessageContent    pgtype.Text
+	LastMessageType       pgtype.Text
+	LastMessageAt         pgtype.Timestamptz
+	LastMessageDeletedAt  pgtype.Timestamptz
+	UnreadCount           int64
+	UnreadMentionCount    int32
+	MutedUntil            pgtype.Timestamptz
+	PinnedAt              pgtype.Timestamptz
+	ArchivedAt            pgtype.Timestamptz
+	ActivityAt            pgtype.Timestamptz
 }
 
-func (q *Queries) ListUserConversations(ctx context.Context, userID pgtype.UUID) ([]ListUserConversationsRow, error) {
-	rows, err := q.db.Query(ctx, listUserConversations, userID)
+// Conversations are ordered by their latest activity for the user: the last
+// message they can see, or when they joined if that is newer. 1:1
+// conversations carry the other participant. Messages the user deleted for
+// themselves are neither previewed nor counted as unread. A NULL cursor
+// starts from the most recent conversation.
+func (q *Queries) ListInbox(ctx context.Context, arg ListInboxParams) ([]ListInboxRow, error) {
+	rows, err := q.db.Query(ctx, listInbox,
+		arg.UserID,
+		arg.ActivityAt,
+		arg.ID,
+		arg.Limit,
+	)
 	if err != nil {
 		return nil, err
 	}
 	defer rows.Close()
-	var items []ListUserConversationsRow
+	var items []ListInboxRow
 	for rows.Next() {
-		var i ListUserConversationsRow
+		var i ListInboxRow
 		if err := rows.Scan(
-			&i.ConversationID,
-			&i.UserID,
-			&i.Role,
-			&i.JoinedAt,
-			&i.Title,
+			&i.ID,
 			&i.IsGroup,
-			&i.ConversationCreatedAt,
+			&i.Title,
+			&i.OtherUserID,
+			&i.OtherDisplayName,
+			&i.OtherPhoneNumber,
+			&i.LastMessageID,
+			&i.LastMessageSenderID,
+			&i.LastMessageSenderName,
+			&i.LastMessageContent,
+			&i.LastMessageType,
+			&i.LastMessageAt,
+			&i.LastMessageDeletedAt,
+			&i.UnreadCount,
+			&i.UnreadMentionCount,
+			&i.MutedUntil,
+			&i.PinnedAt,
+			&i.ArchivedAt,
+			&i.ActivityAt,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const listUnreadCounts = `-- name: ListUnreadCounts :many
+SELECT conversation_id, unread_count, unread_mention_count
+FROM (
+  SELECT cp.conversation_id,
+         (c.last_message_seq - cp.last_read_seq - (
+           SELECT COUNT(*) FROM hidden_messages h
+           JOIN messages hm ON hm.id = h.message_id
+           WHERE h.user_id = cp.user_id
+             AND hm.conversation_id = cp.conversation_id
+             AND hm.seq > cp.last_read_seq
+         ))::bigint AS unread_count,
+         cp.unread_mention_count,
+         c.last_message_seq
+  FROM conversation_participants cp
+  JOIN conversations c ON c.id = cp.conversation_id
+  WHERE cp.user_id = $1
+    AND (c.last_message_seq > cp.last_read_seq OR cp.unread_mention_count > 0)
+) counts
+WHERE unread_count > 0 OR unread_mention_count > 0
+ORDER BY last_message_seq DESC
+`
+
+type ListUnreadCountsRow struct {
+	ConversationID     pgtype.UUID
+	UnreadCount        int64
+	UnreadMentionCount int32
+}
+
+// Unread counts come from the watermark, less the messages above it the
+// user deleted for themselves, so conversations with nothing unread are
+// skipped without touching messages.
+func (q *Queries) ListUnreadCounts(ctx context.Context, userID pgtype.UUID) ([]ListUnreadCountsRow, error) {
+	rows, err := q.db.Query(ctx, listUnreadCounts, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []ListUnreadCountsRow
+	for rows.Next() {
+		var i ListUnreadCountsRow
+		if err := rows.Scan(
+			&i.ConversationID,
+			&i.UnreadCount,
+			&i.UnreadMentionCount,
 		); err != nil {
 			return nil, err
 		}
@@ -247,52 +506,53 @@ func (q *Queries) ListUserConversations(ctx context.Context, userID pgtype.UUID)
 	return items, nil
 }
 
-const listUserConversationsWithLastMessage = `-- name: ListUserConversationsWithLastMessage :many
-SELECT cp.conversation_id, cp.user_id, cp.role, cp.joined_at, c.title, c.is_group, c.created_at as conversation_created_at,
-       m.content as last_message_content, m.created_at as last_message_at
+const listUserConversations = `-- name: ListUserConversations :many
+SELECT cp.conversation_id, cp.user_id, cp.role, cp.joined_at, cp.last_read_seq, cp.unread_mention_count, cp.muted_until, cp.pinned_at, cp.archived_at, cp.last_delivered_seq, c.title, c.is_group, c.created_at as conversation_created_at
 FROM conversation_participants cp
 JOIN conversations c ON cp.conversation_id = c.id
-LEFT JOIN messages m ON m.conversation_id = c.id
 WHERE cp.user_id = $1
-  AND m.created_at = (
-    SELECT MAX(created_at) 
-    FROM messages 
-    WHERE conversation_id = c.id
-  )
-ORDER BY m.created_at DESC
+ORDER BY cp.joined_at DESC
 `
 
-type ListUserConversationsWithLastMessageRow struct {
+type ListUserConversationsRow struct {
 	ConversationID        pgtype.UUID
 	UserID                pgtype.UUID
 	Role                  pgtype.Text
 	JoinedAt              pgtype.Timestamptz
+	LastReadSeq           int64
+	UnreadMentionCount    int32
+	MutedUntil            pgtype.Timestamptz
+	PinnedAt              pgtype.Timestamptz
+	ArchivedAt            pgtype.Timestamptz
+	LastDeliveredSeq      int64
 	Title                 pgtype.Text
 	IsGroup               bool
 	ConversationCreatedAt pgtype.Timestamptz
-	LastMessageContent    pgtype.Text
-	LastMessageAt         pgtype.Timestamptz
 }
 
-func (q *Queries) ListUserConversationsWithLastMessage(ctx context.Context, userID pgtype.UUID) ([]ListUserConversationsWithLastMessageRow, error) {
-	rows, err := q.db.Query(ctx, listUserConversationsWithLastMessage, userID)
+func (q *Queries) ListUserConversations(ctx context.Context, userID pgtype.UUID) ([]ListUserConversationsRow, error) {
+	rows, err := q.db.Query(ctx, listUserConversations, userID)
 	if err != nil {
 		return nil, err
 	}
 	defer rows.Close()
-	var items []ListUserConversationsWithLastMessageRow
+	var items []ListUserConversationsRow
 	for rows.Next() {
-		var i ListUserConversationsWithLastMessageRow
+		var i ListUserConversationsRow
 		if err := rows.Scan(
 			&i.ConversationID,
 			&i.UserID,
 			&i.Role,
 			&i.JoinedAt,
+			&i.LastReadSeq,
+			&i.UnreadMentionCount,
+			&i.MutedUntil,
+			&i.PinnedAt,
+			&i.ArchivedAt,
+			&i.LastDeliveredSeq,
 			&i.Title,
 			&i.IsGroup,
 			&i.ConversationCreatedAt,
-			&i.LastMessageContent,
-			&i.LastMessageAt,
 		); err != nil {
 			return nil, err
 		}
@@ -304,6 +564,31 @@ func (q *Queries) ListUserConversationsWithLastMessage(ctx context.Context, user
 	return items, nil
 }
 
+const recountUnreadMentions = `-- name: RecountUnreadMentions :exec
+UPDATE conversation_participants cp
+SET unread_mention_count = (
+      SELECT COUNT(*) FROM message_mentions mm
+      JOIN messages m ON m.id = mm.message_id
+      WHERE mm.user_id = cp.user_id
+        AND mm.conversation_id = cp.conversation_id
+        AND m.seq > cp.last_read_seq
+        AND m.deleted_at IS NULL
+    )::int
+WHERE cp.conversation_id = $1 AND cp.user_id = ANY($2::uuid[])
+`
+
+type RecountUnreadMentionsParams struct {
+	ConversationID pgtype.UUID
+	UserIds        []pgtype.UUID
+}
+
+// Recounts the mentions of each user that are above their watermark, for
+// when a message's mentions change.
+func (q *Queries) RecountUnreadMentions(ctx context.Context, arg RecountUnreadMentionsParams) error {
+	_, err := q.db.Exec(ctx, recountUnreadMentions, arg.ConversationID, arg.UserIds)
+	return err
+}
+
 const removeAllConversationParticipants = `-- name: RemoveAllConversationParticipants :exec
 DELETE FROM conversation_participants
 WHERE conversation_id = $1
@@ -329,11 +614,107 @@ func (q *Queries) RemoveConversationParticipant(ctx context.Context, arg RemoveC
 	return err
 }
 
+const setConversationArchived = `-- name: SetConversationArchived :one
+UPDATE conversation_participants
+SET archived_at = CASE WHEN $1::bool THEN COALESCE(archived_at, NOW()) END
+WHERE conversation_id = $2 AND user_id = $3
+RETURNING conversation_id, user_id, role, joined_at, last_read_seq, unread_mention_count, muted_until, pinned_at, archived_at, last_delivered_seq
+`
+
+type SetConversationArchivedParams struct {
+	Archived       bool
+	ConversationID pgtype.UUID
+	UserID         pgtype.UUID
+}
+
+func (q *Queries) SetConversationArchived(ctx context.Context, arg SetConversationArchivedParams) (ConversationParticipant, error) {
+	row := q.db.QueryRow(ctx, setConversationArchived, arg.Archived, arg.ConversationID, arg.UserID)
+	var i ConversationParticipant
+	err := row.Scan(
+		&i.ConversationID,
+		&i.UserID,
+		&i.Role,
+		&i.JoinedAt,
+		&i.LastReadSeq,
+		&i.UnreadMentionCount,
+		&i.MutedUntil,
+		&i.PinnedAt,
+		&i.ArchivedAt,
+		&i.LastDeliveredSeq,
+	)
+	return i, err
+}
+
+const setConversationMuted = `-- name: SetConversationMuted :one
+UPDATE conversation_participants
+SET muted_until = $1
+WHERE conversation_id = $2 AND user_id = $3
+RETURNING conversation_id, user_id, role, joined_at, last_read_seq, unread_mention_count, muted_until, pinned_at, archived_at, last_delivered_seq
+`
+
+type SetConversationMutedParams struct {
+	MutedUntil     pgtype.Timestamptz
+	ConversationID pgtype.UUID
+	UserID         pgtype.UUID
+}
+
+// A NULL muted_until unmutes the conversation; 'infinity' mutes it until
+// the user unmutes it.
+func (q *Queries) SetConversationMuted(ctx context.Context, arg SetConversationMutedParams) (ConversationParticipant, error) {
+	row := q.db.QueryRow(ctx, setConversationMuted, arg.MutedUntil, arg.ConversationID, arg.UserID)
+	var i ConversationParticipant
+	err := row.Scan(
+		&i.ConversationID,
+		&i.UserID,
+		&i.Role,
+		&i.JoinedAt,
+		&i.LastReadSeq,
+		&i.UnreadMentionCount,
+		&i.MutedUntil,
+		&i.PinnedAt,
+		&i.ArchivedAt,
+		&i.LastDeliveredSeq,
+	)
+	return i, err
+}
+
+const setConversationPinned = `-- name: SetConversationPinned :one
+UPDATE conversation_participants
+SET pinned_at = CASE WHEN $1::bool THEN COALESCE(pinned_at, NOW()) END
+WHERE conversation_id = $2 AND user_id = $3
+RETURNING conversation_id, user_id, role, joined_at, last_read_seq, unread_mention_count, muted_until, pinned_at, archived_at, last_delivered_seq
+`
+
+type SetConversationPinnedParams struct {
+	Pinned         bool
+	ConversationID pgtype.UUID
+	UserID         pgtype.UUID
+}
+
+// Pinning a pinned conversation keeps its original pin time.
+func (q *Queries) SetConversationPinned(ctx context.Context, arg SetConversationPinnedParams) (ConversationParticipant, error) {
+	row := q.db.QueryRow(ctx, setConversationPinned, arg.Pinned, arg.ConversationID, arg.UserID)
+	var i ConversationParticipant
+	err := row.Scan(
+		&i.ConversationID,
+		&i.UserID,
+		&i.Role,
+		&i.JoinedAt,
+		&i.LastReadSeq,
+		&i.UnreadMentionCount,
+		&i.MutedUntil,
+		&i.PinnedAt,
+		&i.ArchivedAt,
+		&i.LastDeliveredSeq,
+	)
+	return i, err
+}
+
 const updateParticipantRole = `-- name: UpdateParticipantRole :one
 UPDATE conversation_participants
 SET role = $3
 WHERE conversation_id = $1 AND user_id = $2
-RETURNING conversation_id, user_id, role, joined_at
+RETURNING conversation_id, user_id, role, joined_at, last_read_seq, unread_mention_count, muted_until, pinned_at, archived_at, last_delivered_seq
 `
 
 type UpdateParticipantRoleParams struct {
@@ -350,6 +731,12 @@ func (q *Queries) UpdateParticipantRole(ctx context.Context, arg UpdateParticipa
 		&i.UserID,
 		&i.Role,
 		&i.JoinedAt,
+		&i.LastReadSeq,
+		&i.UnreadMentionCount,
+		&i.MutedUntil,
+		&i.PinnedAt,
+		&i.ArchivedAt,
+		&i.LastDeliveredSeq,
 	)
 	return i, err
 }
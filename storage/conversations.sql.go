@@ -11,13 +11,25 @@ import (
 	"github.com/jackc/pgx/v5/pgtype"
 )
 
+const clearConversationCreator = `-- name: ClearConversationCreator :exec
+UPDATE conversations
+SET created_by = NULL
+WHERE created_by = $1
+`
+
+// Forgets a user who is being deleted as the creator of their conversations.
+func (q *Queries) ClearConversationCreator(ctx context.Context, createdBy pgtype.UUID) error {
+	_, err := q.db.Exec(ctx, clearConversationCreator, createdBy)
+	return err
+}
+
 const createConversation = `-- name: CreateConversation :one
 INSERT INTO conversations (
   is_group, title, created_by
 ) VALUES (
   $1, $2, $3
 )
-RETURNING id, is_group, title, created_by, created_at
+RETURNING id, is_group, title, created_by, created_at, last_message_seq
 `
 
 type CreateConversationParams struct {
@@ -35,6 +47,7 @@ func (q *Queries) CreateConversation(ctx context.Context, arg CreateConversation
 		&i.Title,
 		&i.CreatedBy,
 		&i.CreatedAt,
+		&i.LastMessageSeq,
 	)
 	return i, err
 }
@@ -50,7 +63,7 @@ func (q *Queries) DeleteConversation(ctx context.Context, id pgtype.UUID) error
 }
 
 const getConversation = `-- name: GetConversation :one
-SELECT id, is_group, title, created_by, created_at FROM conversations
+SELECT id, is_group, title, created_by, created_at, last_message_seq FROM conversations
 WHERE id = $1 LIMIT 1
 `
 
@@ -63,25 +76,27 @@ func (q *Queries) GetConversation(ctx context.Context, id pgtype.UUID) (Conversa
 		&i.Title,
 		&i.CreatedBy,
 		&i.CreatedAt,
+		&i.LastMessageSeq,
 	)
 	return i, err
 }
 
 const getConversationByIdWithCreator = `-- name: GetConversationByIdWithCreator :one
-SELECT c.id, c.is_group, c.title, c.created_by, c.created_at, u.phone_number as creator_phone, u.display_name as creator_name
+SELECT c.id, c.is_group, c.title, c.created_by, c.created_at, c.last_message_seq, u.phone_number as creator_phone, u.display_name as creator_name
 FROM conversations c
 LEFT JOIN users u ON c.created_by = u.id
 WHERE c.id = $1 LIMIT 1
 `
 
 type GetConversationByIdWithCreatorRow struct {
-	ID           pgtype.UUID
-	IsGroup      bool
-	Title        pgtype.Text
-	CreatedBy    pgtype.UUID
-	CreatedAt    pgtype.Timestamptz
-	CreatorPhone pgtype.Text
-	CreatorName  pgtype.Text
+	ID             pgtype.UUID
+	IsGroup        bool
+	Title          pgtype.Text
+	CreatedBy      pgtype.UUID
+	CreatedAt      pgtype.Timestamptz
+	LastMessageSeq int64
+	CreatorPhone   pgtype.Text
+	CreatorName    pgtype.Text
 }
 
 func (q *Queries) GetConversationByIdWithCreator(ctx context.Context, id pgtype.UUID) (GetConversationByIdWithCreatorRow, error) {
@@ -93,6 +108,7 @@ func (q *Queries) GetConversationByIdWithCreator(ctx context.Context, id pgtype.
 		&i.Title,
 		&i.CreatedBy,
 		&i.CreatedAt,
+		&i.LastMessageSeq,
 		&i.CreatorPhone,
 		&i.CreatorName,
 	)
@@ -100,7 +116,7 @@ func (q *Queries) GetConversationByIdWithCreator(ctx context.Context, id pgtype.
 }
 
 const listConversations = `-- name: ListConversations :many
-SELECT id, is_group, title, created_by, created_at FROM conversations
+SELECT id, is_group, title, created_by, created_at, last_message_seq FROM conversations
 ORDER BY created_at DESC
 `
 
@@ -119,6 +135,7 @@ func (q *Queries) ListConversations(ctx context.Context) ([]Conversation, error)
 			&i.Title,
 			&i.CreatedBy,
 			&i.CreatedAt,
+			&i.LastMessageSeq,
 		); err != nil {
 			return nil, err
 		}
@@ -131,7 +148,7 @@ func (q *Queries) ListConversations(ctx context.Context) ([]Conversation, error)
 }
 
 const listConversationsByCreator = `-- name: ListConversationsByCreator :many
-SELECT id, is_group, title, created_by, created_at FROM conversations
+SELECT id, is_group, title, created_by, created_at, last_message_seq FROM conversations
 WHERE created_by = $1
 ORDER BY created_at DESC
 `
@@ -151,6 +168,7 @@ func (q *Queries) ListConversationsByCreator(ctx context.Context, createdBy pgty
 			&i.Title,
 			&i.CreatedBy,
 			&i.CreatedAt,
+			&i.LastMessageSeq,
 		); err != nil {
 			return nil, err
 		}
@@ -163,7 +181,7 @@ func (q *Queries) ListConversationsByCreator(ctx context.Context, createdBy pgty
 }
 
 const listGroupConversations = `-- name: ListGroupConversations :many
-SELECT id, is_group, title, created_by, created_at FROM conversations
+SELECT id, is_group, title, created_by, created_at, last_message_seq FROM conversations
 WHERE is_group = true
 ORDER BY created_at DESC
 `
@@ -183,6 +201,7 @@ func (q *Queries) ListGroupConversations(ctx context.Context) ([]Conversation, e
 			&i.Title,
 			&i.CreatedBy,
 			&i.CreatedAt,
+			&i.LastMessageSeq,
 		); err != nil {
 			return nil, err
 		}
@@ -195,7 +214,7 @@ func (q *Queries) ListGroupConversations(ctx context.Context) ([]Conversation, e
 }
 
 const searchConversationsByTitle = `-- name: SearchConversationsByTitle :many
-SELECT id, is_group, title, created_by, created_at FROM conversations
+SELECT id, is_group, title, created_by, created_at, last_message_seq FROM conversations
 WHERE title ILIKE '%' || $1 || '%'
 ORDER BY created_at DESC
 LIMIT 20
@@ -216,6 +235,7 @@ func (q *Queries) SearchConversationsByTitle(ctx context.Context, dollar_1 pgtyp
 			&i.Title,
 			&i.CreatedBy,
 			&i.CreatedAt,
+			&i.LastMessageSeq,
 		); err != nil {
 			return nil, err
 		}
@@ -231,7 +251,7 @@ const updateConversation = `-- name: UpdateConversation :one
 UPDATE conversations
 SET title = $2
 WHERE id = $1
-RETURNING id, is_group, title, created_by, created_at
+RETURNING id, is_group, title, created_by, created_at, last_message_seq
 `
 
 type UpdateConversationParams struct {
@@ -248,6 +268,7 @@ func (q *Queries) UpdateConversation(ctx context.Context, arg UpdateConversation
 		&i.Title,
 		&i.CreatedBy,
 		&i.CreatedAt,
+		&i.LastMessageSeq,
 	)
 	return i, err
 }
@@ -256,7 +277,7 @@ const updateConversationTitle = `-- name: UpdateConversationTitle :one
 UPDATE conversations
 SET title = $2
 WHERE id = $1
-RETURNING id, is_group, title, created_by, created_at
+RETURNING id, is_group, title, created_by, created_at, last_message_seq
 `
 
 type UpdateConversationTitleParams struct {
@@ -273,6 +294,7 @@ func (q *Queries) UpdateConversationTitle(ctx context.Context, arg UpdateConvers
 		&i.Title,
 		&i.CreatedBy,
 		&i.CreatedAt,
+		&i.LastMessageSeq,
 	)
 	return i, err
 }
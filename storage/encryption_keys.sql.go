@@ -148,7 +148,7 @@ func (q *Queries) GetEncryptionKeyWithDevice(ctx context.Context, deviceID pgtyp
 }
 
 const listDevicesNeedingKeyRefresh = `-- name: ListDevicesNeedingKeyRefresh :many
-SELECT ud.id, ud.user_id, ud.device_name, ud.device_type, ud.public_key, ud.created_at, u.phone_number, u.display_name
+SELECT ud.id, ud.user_id, ud.device_name, ud.device_type, ud.public_key, ud.created_at, ud.sync_checkpoint, ud.presence_expires_at, u.phone_number, u.display_name
 FROM user_devices ud
 JOIN users u ON ud.user_id = u.id
 WHERE ud.id NOT IN (SELECT device_id FROM encryption_keys)
@@ -156,14 +156,16 @@ ORDER BY ud.created_at DESC
 `
 
 type ListDevicesNeedingKeyRefreshRow struct {
-	ID          pgtype.UUID
-	UserID      pgtype.UUID
-	DeviceName  pgtype.Text
-	DeviceType  pgtype.Text
-	PublicKey   string
-	CreatedAt   pgtype.Timestamptz
-	PhoneNumber string
-	DisplayName pgtype.Text
+	ID                pgtype.UUID
+	UserID            pgtype.UUID
+	DeviceName        pgtype.Text
+	DeviceType        pgtype.Text
+	PublicKey         string
+	CreatedAt         pgtype.Timestamptz
+	SyncCheckpoint    int64
+	PresenceExpiresAt pgtype.Timestamptz
+	PhoneNumber       string
+	DisplayName       pgtype.Text
 }
 
 func (q *Queries) ListDevicesNeedingKeyRefresh(ctx context.Context) ([]ListDevicesNeedingKeyRefreshRow, error) {
@@ -182,6 +184,8 @@ func (q *Queries) ListDevicesNeedingKeyRefresh(ctx context.Context) ([]ListDevic
 			&i.DeviceType,
 			&i.PublicKey,
 			&i.CreatedAt,
+			&i.SyncCheckpoint,
+			&i.PresenceExpiresAt,
 			&i.PhoneNumber,
 			&i.DisplayName,
 		); err != nil {
@@ -11,6 +11,25 @@ import (
 	"github.com/jackc/pgx/v5/pgtype"
 )
 
+const copyMessageMedia = `-- name: CopyMessageMedia :exec
+INSERT INTO media (
+  message_id, file_url, mime_type, file_size
+)
+SELECT $1::uuid, file_url, mime_type, file_size
+FROM media
+WHERE message_id = $2::uuid
+`
+
+type CopyMessageMediaParams struct {
+	TargetMessageID pgtype.UUID
+	SourceMessageID pgtype.UUID
+}
+
+func (q *Queries) CopyMessageMedia(ctx context.Context, arg CopyMessageMediaParams) error {
+	_, err := q.db.Exec(ctx, copyMessageMedia, arg.TargetMessageID, arg.SourceMessageID)
+	return err
+}
+
 const countMediaByMessage = `-- name: CountMediaByMessage :one
 SELECT COUNT(*) FROM media
 WHERE message_id = $1
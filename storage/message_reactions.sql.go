@@ -269,7 +269,7 @@ func (q *Queries) ListMessageReactionsWithDetails(ctx context.Context, messageID
 }
 
 const listMessagesByReaction = `-- name: ListMessagesByReaction :many
-SELECT DISTINCT m.id, m.conversation_id, m.sender_id, m.content, m.message_type, m.reply_to_id, m.created_at, c.title as conversation_title
+SELECT DISTINCT m.id, m.conversation_id, m.sender_id, m.content, m.message_type, m.reply_to_id, m.created_at, m.seq, m.sender_device_id, m.client_message_id, m.edited_at, m.deleted_at, m.forward_count, m.thread_root_id, m.thread_reply_count, m.thread_last_reply_at, m.payload, c.title as conversation_title
 FROM messages m
 JOIN conversations c ON m.conversation_id = c.id
 JOIN message_reactions mr ON m.id = mr.message_id
@@ -286,6 +286,16 @@ type ListMessagesByReactionRow struct {
 	MessageType       string
 	ReplyToID         pgtype.UUID
 	CreatedAt         pgtype.Timestamptz
+	Seq               int64
+	SenderDeviceID    pgtype.UUID
+	ClientMessageID   pgtype.Text
+	EditedAt          pgtype.Timestamptz
+	DeletedAt         pgtype.Timestamptz
+	ForwardCount      int32
+	ThreadRootID      pgtype.UUID
+	ThreadReplyCount  int32
+	ThreadLastReplyAt pgtype.Timestamptz
+	Payload           []byte
 	ConversationTitle pgtype.Text
 }
 
@@ -306,6 +316,16 @@ func (q *Queries) ListMessagesByReaction(ctx context.Context, reaction string) (
 			&i.MessageType,
 			&i.ReplyToID,
 			&i.CreatedAt,
+			&i.Seq,
+			&i.SenderDeviceID,
+			&i.ClientMessageID,
+			&i.EditedAt,
+			&i.DeletedAt,
+			&i.ForwardCount,
+			&i.ThreadRootID,
+			&i.ThreadReplyCount,
+			&i.ThreadLastReplyAt,
+			&i.Payload,
 			&i.ConversationTitle,
 		); err != nil {
 			return nil, err
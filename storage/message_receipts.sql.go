@@ -137,6 +137,39 @@ func (q *Queries) GetMessageReceipt(ctx context.Context, arg GetMessageReceiptPa
 	return i, err
 }
 
+const getMessageReceiptCounts = `-- name: GetMessageReceiptCounts :one
+SELECT
+  COUNT(*) AS recipient_count,
+  COUNT(r.delivered_at) AS delivered_count,
+  COUNT(r.read_at) AS read_count
+FROM messages m
+JOIN conversation_participants cp
+  ON cp.conversation_id = m.conversation_id
+  AND cp.user_id IS DISTINCT FROM m.sender_id
+  AND cp.joined_at <= m.created_at
+LEFT JOIN message_receipts r ON r.message_id = m.id AND r.user_id = cp.user_id
+WHERE m.id = $1
+`
+
+type GetMessageReceiptCountsRow struct {
+	RecipientCount int64
+	DeliveredCount int64
+	ReadCount      int64
+}
+
+// Recipients are the current participants, other than the sender, who had
+// joined by the time the message was sent.
+func (q *Queries) GetMessageReceiptCounts(ctx context.Context, messageID pgtype.UUID) (GetMessageReceiptCountsRow, error) {
+	row := q.db.QueryRow(ctx, getMessageReceiptCounts, messageID)
+	var i GetMessageReceiptCountsRow
+	err := row.Scan(
+		&i.RecipientCount,
+		&i.DeliveredCount,
+		&i.ReadCount,
+	)
+	return i, err
+}
+
 const getMessageReceiptWithDetails = `-- name: GetMessageReceiptWithDetails :one
 SELECT mr.message_id, mr.user_id, mr.delivered_at, mr.read_at, u.phone_number, u.display_name
 FROM message_receipts mr
@@ -173,25 +206,30 @@ func (q *Queries) GetMessageReceiptWithDetails(ctx context.Context, arg GetMessa
 }
 
 const getUnreadMessageCount = `-- name: GetUnreadMessageCount :one
-SELECT COUNT(*) FROM messages m
-WHERE m.conversation_id = $1 
-  AND m.sender_id != $2
-  AND m.id NOT IN (
-    SELECT message_id FROM message_receipts 
-    WHERE user_id = $2 AND read_at IS NOT NULL
-  )
+SELECT (c.last_message_seq - cp.last_read_seq - (
+         SELECT COUNT(*) FROM hidden_messages h
+         JOIN messages hm ON hm.id = h.message_id
+         WHERE h.user_id = cp.user_id
+           AND hm.conversation_id = cp.conversation_id
+           AND hm.seq > cp.last_read_seq
+       ))::bigint AS unread_count
+FROM conversation_participants cp
+JOIN conversations c ON c.id = cp.conversation_id
+WHERE cp.conversation_id = $1 AND cp.user_id = $2
 `
 
 type GetUnreadMessageCountParams struct {
 	ConversationID pgtype.UUID
-	SenderID       pgtype.UUID
+	UserID         pgtype.UUID
 }
 
+// Messages above the watermark the user deleted for themselves are not
+// unread.
 func (q *Queries) GetUnreadMessageCount(ctx context.Context, arg GetUnreadMessageCountParams) (int64, error) {
-	row := q.db.QueryRow(ctx, getUnreadMessageCount, arg.ConversationID, arg.SenderID)
-	var count int64
-	err := row.Scan(&count)
-	return count, err
+	row := q.db.QueryRow(ctx, getUnreadMessageCount, arg.ConversationID, arg.UserID)
+	var unread_count int64
+	err := row.Scan(&unread_count)
+	return unread_count, err
 }
 
 const listMessageReceipts = `-- name: ListMessageReceipts :many
@@ -270,13 +308,16 @@ func (q *Queries) ListMessageReceiptsWithDetails(ctx context.Context, messageID
 }
 
 const listUnreadMessages = `-- name: ListUnreadMessages :many
-SELECT m.id, m.conversation_id, m.sender_id, m.content, m.message_type, m.reply_to_id, m.created_at, c.title as conversation_title
-FROM messages m
-JOIN conversations c ON m.conversation_id = c.id
-WHERE m.id NOT IN (
-  SELECT message_id FROM message_receipts 
-  WHERE user_id = $1 AND read_at IS NOT NULL
-) AND m.sender_id != $1
+SELECT m.id, m.conversation_id, m.sender_id, m.content, m.message_type, m.reply_to_id, m.created_at, m.seq, m.sender_device_id, m.client_message_id, m.edited_at, m.deleted_at, m.forward_count, m.thread_root_id, m.thread_reply_count, m.thread_last_reply_at, m.payload, c.title as conversation_title
+FROM conversation_participants cp
+JOIN conversations c ON c.id = cp.conversation_id
+JOIN messages m
+  ON m.conversation_id = cp.conversation_id AND m.seq > cp.last_read_seq
+WHERE cp.user_id = $1 AND m.sender_id != $1
+  AND NOT EXISTS (
+    SELECT 1 FROM hidden_messages h
+    WHERE h.message_id = m.id AND h.user_id = $1
+  )
 ORDER BY m.created_at DESC
 `
 
@@ -288,6 +329,16 @@ type ListUnreadMessagesRow struct {
 	MessageType       string
 	ReplyToID         pgtype.UUID
 	CreatedAt         pgtype.Timestamptz
+	Seq               int64
+	SenderDeviceID    pgtype.UUID
+	ClientMessageID   pgtype.Text
+	EditedAt          pgtype.Timestamptz
+	DeletedAt         pgtype.Timestamptz
+	ForwardCount      int32
+	ThreadRootID      pgtype.UUID
+	ThreadReplyCount  int32
+	ThreadLastReplyAt pgtype.Timestamptz
+	Payload           []byte
 	ConversationTitle pgtype.Text
 }
 
@@ -308,6 +359,16 @@ func (q *Queries) ListUnreadMessages(ctx context.Context, userID pgtype.UUID) ([
 			&i.MessageType,
 			&i.ReplyToID,
 			&i.CreatedAt,
+			&i.Seq,
+			&i.SenderDeviceID,
+			&i.ClientMessageID,
+			&i.EditedAt,
+			&i.DeletedAt,
+			&i.ForwardCount,
+			&i.ThreadRootID,
+			&i.ThreadReplyCount,
+			&i.ThreadLastReplyAt,
+			&i.Payload,
 			&i.ConversationTitle,
 		); err != nil {
 			return nil, err
@@ -370,8 +431,9 @@ INSERT INTO message_receipts (
 ) VALUES (
   $1, $2, NOW()
 )
-ON CONFLICT (message_id, user_id) 
+ON CONFLICT (message_id, user_id)
 DO UPDATE SET delivered_at = NOW()
+WHERE message_receipts.delivered_at IS NULL
 RETURNING message_id, user_id, delivered_at, read_at
 `
 
@@ -380,6 +442,8 @@ type MarkMessageAsDeliveredParams struct {
 	UserID    pgtype.UUID
 }
 
+// Returns no row if the message was already delivered to the user, so the
+// first delivery time is kept.
 func (q *Queries) MarkMessageAsDelivered(ctx context.Context, arg MarkMessageAsDeliveredParams) (MessageReceipt, error) {
 	row := q.db.QueryRow(ctx, markMessageAsDelivered, arg.MessageID, arg.UserID)
 	var i MessageReceipt
@@ -398,8 +462,10 @@ INSERT INTO message_receipts (
 ) VALUES (
   $1, $2, NOW(), NOW()
 )
-ON CONFLICT (message_id, user_id) 
-DO UPDATE SET read_at = NOW()
+ON CONFLICT (message_id, user_id)
+DO UPDATE SET delivered_at = COALESCE(message_receipts.delivered_at, NOW()),
+              read_at = NOW()
+WHERE message_receipts.read_at IS NULL
 RETURNING message_id, user_id, delivered_at, read_at
 `
 
@@ -408,6 +474,8 @@ type MarkMessageAsReadParams struct {
 	UserID    pgtype.UUID
 }
 
+// Returns no row if the user had already read the message. Reading a
+// message also counts as receiving it.
 func (q *Queries) MarkMessageAsRead(ctx context.Context, arg MarkMessageAsReadParams) (MessageReceipt, error) {
 	row := q.db.QueryRow(ctx, markMessageAsRead, arg.MessageID, arg.UserID)
 	var i MessageReceipt
@@ -420,6 +488,123 @@ func (q *Queries) MarkMessageAsRead(ctx context.Context, arg MarkMessageAsReadPa
 	return i, err
 }
 
+const markMessagesDeliveredUpTo = `-- name: MarkMessagesDeliveredUpTo :many
+WITH marked AS (
+  INSERT INTO message_receipts (
+    message_id, user_id, delivered_at
+  )
+  SELECT m.id, $1::uuid, NOW()
+  FROM messages m
+  WHERE m.conversation_id = $2
+    AND m.seq <= $3
+    AND m.seq > (
+      SELECT cp.last_delivered_seq FROM conversation_participants cp
+      WHERE cp.conversation_id = m.conversation_id AND cp.user_id = $1::uuid
+    )
+    AND m.sender_id IS DISTINCT FROM $1::uuid
+    AND NOT EXISTS (
+      SELECT 1 FROM message_receipts r
+      WHERE r.message_id = m.id AND r.user_id = $1::uuid AND r.delivered_at IS NOT NULL
+    )
+  ON CONFLICT (message_id, user_id)
+  DO UPDATE SET delivered_at = NOW()
+  WHERE message_receipts.delivered_at IS NULL
+  RETURNING message_id
+)
+SELECT DISTINCT m.sender_id
+FROM marked
+JOIN messages m ON m.id = marked.message_id
+WHERE m.sender_id IS NOT NULL
+`
+
+type MarkMessagesDeliveredUpToParams struct {
+	UserID         pgtype.UUID
+	ConversationID pgtype.UUID
+	Seq            int64
+}
+
+// Marks every message between the user's delivered watermark and seq that
+// they did not send as delivered to them and returns the senders of the
+// messages that changed. Run it before advancing the watermark.
+func (q *Queries) MarkMessagesDeliveredUpTo(ctx context.Context, arg MarkMessagesDeliveredUpToParams) ([]pgtype.UUID, error) {
+	rows, err := q.db.Query(ctx, markMessagesDeliveredUpTo, arg.UserID, arg.ConversationID, arg.Seq)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []pgtype.UUID
+	for rows.Next() {
+		var sender_id pgtype.UUID
+		if err := rows.Scan(&sender_id); err != nil {
+			return nil, err
+		}
+		items = append(items, sender_id)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const markMessagesReadUpTo = `-- name: MarkMessagesReadUpTo :many
+WITH marked AS (
+  INSERT INTO message_receipts (
+    message_id, user_id, delivered_at, read_at
+  )
+  SELECT m.id, $1::uuid, NOW(), NOW()
+  FROM messages m
+  WHERE m.conversation_id = $2
+    AND m.seq <= $3
+    AND m.seq > (
+      SELECT cp.last_read_seq FROM conversation_participants cp
+      WHERE cp.conversation_id = m.conversation_id AND cp.user_id = $1::uuid
+    )
+    AND m.sender_id IS DISTINCT FROM $1::uuid
+    AND NOT EXISTS (
+      SELECT 1 FROM message_receipts r
+      WHERE r.message_id = m.id AND r.user_id = $1::uuid AND r.read_at IS NOT NULL
+    )
+  ON CONFLICT (message_id, user_id)
+  DO UPDATE SET delivered_at = COALESCE(message_receipts.delivered_at, NOW()),
+                read_at = NOW()
+  WHERE message_receipts.read_at IS NULL
+  RETURNING message_id
+)
+SELECT DISTINCT m.sender_id
+FROM marked
+JOIN messages m ON m.id = marked.message_id
+WHERE m.sender_id IS NOT NULL
+`
+
+type MarkMessagesReadUpToParams struct {
+	UserID         pgtype.UUID
+	ConversationID pgtype.UUID
+	Seq            int64
+}
+
+// Marks every message between the user's read watermark and seq that they
+// did not send as read by them and returns the senders of the messages that
+// changed. Run it before advancing the watermark.
+func (q *Queries) MarkMessagesReadUpTo(ctx context.Context, arg MarkMessagesReadUpToParams) ([]pgtype.UUID, error) {
+	rows, err := q.db.Query(ctx, markMessagesReadUpTo, arg.UserID, arg.ConversationID, arg.Seq)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []pgtype.UUID
+	for rows.Next() {
+		var sender_id pgtype.UUID
+		if err := rows.Scan(&sender_id); err != nil {
+			return nil, err
+		}
+		items = append(items, sender_id)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
 const updateMessageReceipt = `-- name: UpdateMessageReceipt :one
 UPDATE message_receipts
 SET delivered_at = $3,
@@ -36,22 +36,47 @@ func (q *Queries) CountUserMessages(ctx context.Context, senderID pgtype.UUID) (
 }
 
 const createMessage = `-- name: CreateMessage :one
+WITH next AS (
+  UPDATE conversations
+  SET last_message_seq = last_message_seq + 1
+  WHERE id = $1
+  RETURNING last_message_seq
+)
 INSERT INTO messages (
-  conversation_id, sender_id, content, message_type, reply_to_id
-) VALUES (
-  $1, $2, $3, $4, $5
+  conversation_id, sender_id, content, message_type, reply_to_id, seq,
+  sender_device_id, client_message_id, forward_count, thread_root_id, payload
 )
-RETURNING id, conversation_id, sender_id, content, message_type, reply_to_id, created_at
+SELECT
+  $1::uuid,
+  $2::uuid,
+  $3::text,
+  $4::text,
+  $5::uuid,
+  next.last_message_seq,
+  $6::uuid,
+  $7::text,
+  $8::int,
+  $9::uuid,
+  $10::jsonb
+FROM next
+RETURNING id, conversation_id, sender_id, content, message_type, reply_to_id, created_at, seq, sender_device_id, client_message_id, edited_at, deleted_at, forward_count, thread_root_id, thread_reply_count, thread_last_reply_at, payload
 `
 
 type CreateMessageParams struct {
-	ConversationID pgtype.UUID
-	SenderID       pgtype.UUID
-	Content        pgtype.Text
-	MessageType    string
-	ReplyToID      pgtype.UUID
+	ConversationID  pgtype.UUID
+	SenderID        pgtype.UUID
+	Content         pgtype.Text
+	MessageType     string
+	ReplyToID       pgtype.UUID
+	SenderDeviceID  pgtype.UUID
+	ClientMessageID pgtype.Text
+	ForwardCount    int32
+	ThreadRootID    pgtype.UUID
+	Payload         []byte
 }
 
+// Bumping the conversation's counter locks its row, so concurrent inserts
+// into the same conversation take consecutive sequence numbers.
 func (q *Queries) CreateMessage(ctx context.Context, arg CreateMessageParams) (Message, error) {
 	row := q.db.QueryRow(ctx, createMessage,
 		arg.ConversationID,
@@ -59,6 +84,11 @@ func (q *Queries) CreateMessage(ctx context.Context, arg CreateMessageParams) (M
 		arg.Content,
 		arg.MessageType,
 		arg.ReplyToID,
+		arg.SenderDeviceID,
+		arg.ClientMessageID,
+		arg.ForwardCount,
+		arg.ThreadRootID,
+		arg.Payload,
 	)
 	var i Message
 	err := row.Scan(
@@ -69,6 +99,16 @@ func (q *Queries) CreateMessage(ctx context.Context, arg CreateMessageParams) (M
 		&i.MessageType,
 		&i.ReplyToID,
 		&i.CreatedAt,
+		&i.Seq,
+		&i.SenderDeviceID,
+		&i.ClientMessageID,
+		&i.EditedAt,
+		&i.DeletedAt,
+		&i.ForwardCount,
+		&i.ThreadRootID,
+		&i.ThreadReplyCount,
+		&i.ThreadLastReplyAt,
+		&i.Payload,
 	)
 	return i, err
 }
@@ -94,14 +134,23 @@ func (q *Queries) DeleteMessage(ctx context.Context, id pgtype.UUID) error {
 }
 
 const getLatestConversationMessage = `-- name: GetLatestConversationMessage :one
-SELECT id, conversation_id, sender_id, content, message_type, reply_to_id, created_at FROM messages
+SELECT id, conversation_id, sender_id, content, message_type, reply_to_id, created_at, seq, sender_device_id, client_message_id, edited_at, deleted_at, forward_count, thread_root_id, thread_reply_count, thread_last_reply_at, payload FROM messages
 WHERE conversation_id = $1
+  AND NOT EXISTS (
+    SELECT 1 FROM hidden_messages h
+    WHERE h.message_id = messages.id AND h.user_id = $2
+  )
 ORDER BY created_at DESC
 LIMIT 1
 `
 
-func (q *Queries) GetLatestConversationMessage(ctx context.Context, conversationID pgtype.UUID) (Message, error) {
-	row := q.db.QueryRow(ctx, getLatestConversationMessage, conversationID)
+type GetLatestConversationMessageParams struct {
+	ConversationID pgtype.UUID
+	UserID         pgtype.UUID
+}
+
+func (q *Queries) GetLatestConversationMessage(ctx context.Context, arg GetLatestConversationMessageParams) (Message, error) {
+	row := q.db.QueryRow(ctx, getLatestConversationMessage, arg.ConversationID, arg.UserID)
 	var i Message
 	err := row.Scan(
 		&i.ID,
@@ -111,12 +160,22 @@ func (q *Queries) GetLatestConversationMessage(ctx context.Context, conversation
 		&i.MessageType,
 		&i.ReplyToID,
 		&i.CreatedAt,
+		&i.Seq,
+		&i.SenderDeviceID,
+		&i.ClientMessageID,
+		&i.EditedAt,
+		&i.DeletedAt,
+		&i.ForwardCount,
+		&i.ThreadRootID,
+		&i.ThreadReplyCount,
+		&i.ThreadLastReplyAt,
+		&i.Payload,
 	)
 	return i, err
 }
 
 const getMessage = `-- name: GetMessage :one
-SELECT id, conversation_id, sender_id, content, message_type, reply_to_id, created_at FROM messages
+SELECT id, conversation_id, sender_id, content, message_type, reply_to_id, created_at, seq, sender_device_id, client_message_id, edited_at, deleted_at, forward_count, thread_root_id, thread_reply_count, thread_last_reply_at, payload FROM messages
 WHERE id = $1 LIMIT 1
 `
 
@@ -131,27 +190,83 @@ func (q *Queries) GetMessage(ctx context.Context, id pgtype.UUID) (Message, erro
 		&i.MessageType,
 		&i.ReplyToID,
 		&i.CreatedAt,
+		&i.Seq,
+		&i.SenderDeviceID,
+		&i.ClientMessageID,
+		&i.EditedAt,
+		&i.DeletedAt,
+		&i.ForwardCount,
+		&i.ThreadRootID,
+		&i.ThreadReplyCount,
+		&i.ThreadLastReplyAt,
+		&i.Payload,
+	)
+	return i, err
+}
+
+const getMessageByClientMessageID = `-- name: GetMessageByClientMessageID :one
+SELECT id, conversation_id, sender_id, content, message_type, reply_to_id, created_at, seq, sender_device_id, client_message_id, edited_at, deleted_at, forward_count, thread_root_id, thread_reply_count, thread_last_reply_at, payload FROM messages
+WHERE sender_device_id = $1 AND client_message_id = $2
+LIMIT 1
+`
+
+type GetMessageByClientMessageIDParams struct {
+	SenderDeviceID  pgtype.UUID
+	ClientMessageID pgtype.Text
+}
+
+func (q *Queries) GetMessageByClientMessageID(ctx context.Context, arg GetMessageByClientMessageIDParams) (Message, error) {
+	row := q.db.QueryRow(ctx, getMessageByClientMessageID, arg.SenderDeviceID, arg.ClientMessageID)
+	var i Message
+	err := row.Scan(
+		&i.ID,
+		&i.ConversationID,
+		&i.SenderID,
+		&i.Content,
+		&i.MessageType,
+		&i.ReplyToID,
+		&i.CreatedAt,
+		&i.Seq,
+		&i.SenderDeviceID,
+		&i.ClientMessageID,
+		&i.EditedAt,
+		&i.DeletedAt,
+		&i.ForwardCount,
+		&i.ThreadRootID,
+		&i.ThreadReplyCount,
+		&i.ThreadLastReplyAt,
+		&i.Payload,
 	)
 	return i, err
 }
 
 const getMessageWithDetails = `-- name: GetMessageWithDetails :one
-SELECT m.id, m.conversation_id, m.sender_id, m.content, m.message_type, m.reply_to_id, m.created_at, u.phone_number as sender_phone, u.display_name as sender_name
+SELECT m.id, m.conversation_id, m.sender_id, m.content, m.message_type, m.reply_to_id, m.created_at, m.seq, m.sender_device_id, m.client_message_id, m.edited_at, m.deleted_at, m.forward_count, m.thread_root_id, m.thread_reply_count, m.thread_last_reply_at, m.payload, u.phone_number as sender_phone, u.display_name as sender_name
 FROM messages m
 LEFT JOIN users u ON m.sender_id = u.id
 WHERE m.id = $1 LIMIT 1
 `
 
 type GetMessageWithDetailsRow struct {
-	ID             pgtype.UUID
-	ConversationID pgtype.UUID
-	SenderID       pgtype.UUID
-	Content        pgtype.Text
-	MessageType    string
-	ReplyToID      pgtype.UUID
-	CreatedAt      pgtype.Timestamptz
-	SenderPhone    pgtype.Text
-	SenderName     pgtype.Text
+	ID                pgtype.UUID
+	ConversationID    pgtype.UUID
+	SenderID          pgtype.UUID
+	Content           pgtype.Text
+	MessageType       string
+	ReplyToID         pgtype.UUID
+	CreatedAt         pgtype.Timestamptz
+	Seq               int64
+	SenderDeviceID    pgtype.UUID
+	ClientMessageID   pgtype.Text
+	EditedAt          pgtype.Timestamptz
+	DeletedAt         pgtype.Timestamptz
+	ForwardCount      int32
+	ThreadRootID      pgtype.UUID
+	ThreadReplyCount  int32
+	ThreadLastReplyAt pgtype.Timestamptz
+	Payload           []byte
+	SenderPhone       pgtype.Text
+	SenderName        pgtype.Text
 }
 
 func (q *Queries) GetMessageWithDetails(ctx context.Context, id pgtype.UUID) (GetMessageWithDetailsRow, error) {
@@ -165,6 +280,16 @@ func (q *Queries) GetMessageWithDetails(ctx context.Context, id pgtype.UUID) (Ge
 		&i.MessageType,
 		&i.ReplyToID,
 		&i.CreatedAt,
+		&i.Seq,
+		&i.SenderDeviceID,
+		&i.ClientMessageID,
+		&i.EditedAt,
+		&i.DeletedAt,
+		&i.ForwardCount,
+		&i.ThreadRootID,
+		&i.ThreadReplyCount,
+		&i.ThreadLastReplyAt,
+		&i.Payload,
 		&i.SenderPhone,
 		&i.SenderName,
 	)
@@ -172,18 +297,33 @@ func (q *Queries) GetMessageWithDetails(ctx context.Context, id pgtype.UUID) (Ge
 }
 
 const getMessagesAfterTimestamp = `-- name: GetMessagesAfterTimestamp :many
-SELECT id, conversation_id, sender_id, content, message_type, reply_to_id, created_at FROM messages
-WHERE conversation_id = $1 AND created_at > $2
-ORDER BY created_at ASC
+SELECT id, conversation_id, sender_id, content, message_type, reply_to_id, created_at, seq, sender_device_id, client_message_id, edited_at, deleted_at, forward_count, thread_root_id, thread_reply_count, thread_last_reply_at, payload FROM messages
+WHERE conversation_id = $1
+  AND (created_at, id) > ($2::timestamptz, $3::uuid)
+  AND NOT EXISTS (
+    SELECT 1 FROM hidden_messages h
+    WHERE h.message_id = messages.id AND h.user_id = $4
+  )
+ORDER BY created_at ASC, id ASC
+LIMIT $5
 `
 
 type GetMessagesAfterTimestampParams struct {
 	ConversationID pgtype.UUID
 	CreatedAt      pgtype.Timestamptz
+	ID             pgtype.UUID
+	UserID         pgtype.UUID
+	Limit          int32
 }
 
 func (q *Queries) GetMessagesAfterTimestamp(ctx context.Context, arg GetMessagesAfterTimestampParams) ([]Message, error) {
-	rows, err := q.db.Query(ctx, getMessagesAfterTimestamp, arg.ConversationID, arg.CreatedAt)
+	rows, err := q.db.Query(ctx, getMessagesAfterTimestamp,
+		arg.ConversationID,
+		arg.CreatedAt,
+		arg.ID,
+		arg.UserID,
+		arg.Limit,
+	)
 	if err != nil {
 		return nil, err
 	}
@@ -199,6 +339,16 @@ func (q *Queries) GetMessagesAfterTimestamp(ctx context.Context, arg GetMessages
 			&i.MessageType,
 			&i.ReplyToID,
 			&i.CreatedAt,
+			&i.Seq,
+			&i.SenderDeviceID,
+			&i.ClientMessageID,
+			&i.EditedAt,
+			&i.DeletedAt,
+			&i.ForwardCount,
+			&i.ThreadRootID,
+			&i.ThreadReplyCount,
+			&i.ThreadLastReplyAt,
+			&i.Payload,
 		); err != nil {
 			return nil, err
 		}
@@ -211,20 +361,33 @@ func (q *Queries) GetMessagesAfterTimestamp(ctx context.Context, arg GetMessages
 }
 
 const getMessagesBeforeTimestamp = `-- name: GetMessagesBeforeTimestamp :many
-SELECT id, conversation_id, sender_id, content, message_type, reply_to_id, created_at FROM messages
-WHERE conversation_id = $1 AND created_at < $2
-ORDER BY created_at DESC
-LIMIT $3
+SELECT id, conversation_id, sender_id, content, message_type, reply_to_id, created_at, seq, sender_device_id, client_message_id, edited_at, deleted_at, forward_count, thread_root_id, thread_reply_count, thread_last_reply_at, payload FROM messages
+WHERE conversation_id = $1
+  AND (created_at, id) < ($2::timestamptz, $3::uuid)
+  AND NOT EXISTS (
+    SELECT 1 FROM hidden_messages h
+    WHERE h.message_id = messages.id AND h.user_id = $4
+  )
+ORDER BY created_at DESC, id DESC
+LIMIT $5
 `
 
 type GetMessagesBeforeTimestampParams struct {
 	ConversationID pgtype.UUID
 	CreatedAt      pgtype.Timestamptz
+	ID             pgtype.UUID
+	UserID         pgtype.UUID
 	Limit          int32
 }
 
 func (q *Queries) GetMessagesBeforeTimestamp(ctx context.Context, arg GetMessagesBeforeTimestampParams) ([]Message, error) {
-	rows, err := q.db.Query(ctx, getMessagesBeforeTimestamp, arg.ConversationID, arg.CreatedAt, arg.Limit)
+	rows, err := q.db.Query(ctx, getMessagesBeforeTimestamp,
+		arg.ConversationID,
+		arg.CreatedAt,
+		arg.ID,
+		arg.UserID,
+		arg.Limit,
+	)
 	if err != nil {
 		return nil, err
 	}
@@ -240,6 +403,16 @@ func (q *Queries) GetMessagesBeforeTimestamp(ctx context.Context, arg GetMessage
 			&i.MessageType,
 			&i.ReplyToID,
 			&i.CreatedAt,
+			&i.Seq,
+			&i.SenderDeviceID,
+			&i.ClientMessageID,
+			&i.EditedAt,
+			&i.DeletedAt,
+			&i.ForwardCount,
+			&i.ThreadRootID,
+			&i.ThreadReplyCount,
+			&i.ThreadLastReplyAt,
+			&i.Payload,
 		); err != nil {
 			return nil, err
 		}
@@ -251,8 +424,46 @@ func (q *Queries) GetMessagesBeforeTimestamp(ctx context.Context, arg GetMessage
 	return items, nil
 }
 
+const incrementThreadReplies = `-- name: IncrementThreadReplies :one
+UPDATE messages
+SET thread_reply_count = thread_reply_count + 1,
+    thread_last_reply_at = $2
+WHERE id = $1
+RETURNING id, conversation_id, sender_id, content, message_type, reply_to_id, created_at, seq, sender_device_id, client_message_id, edited_at, deleted_at, forward_count, thread_root_id, thread_reply_count, thread_last_reply_at, payload
+`
+
+type IncrementThreadRepliesParams struct {
+	ID                pgtype.UUID
+	ThreadLastReplyAt pgtype.Timestamptz
+}
+
+func (q *Queries) IncrementThreadReplies(ctx context.Context, arg IncrementThreadRepliesParams) (Message, error) {
+	row := q.db.QueryRow(ctx, incrementThreadReplies, arg.ID, arg.ThreadLastReplyAt)
+	var i Message
+	err := row.Scan(
+		&i.ID,
+		&i.ConversationID,
+		&i.SenderID,
+		&i.Content,
+		&i.MessageType,
+		&i.ReplyToID,
+		&i.CreatedAt,
+		&i.Seq,
+		&i.SenderDeviceID,
+		&i.ClientMessageID,
+		&i.EditedAt,
+		&i.DeletedAt,
+		&i.ForwardCount,
+		&i.ThreadRootID,
+		&i.ThreadReplyCount,
+		&i.ThreadLastReplyAt,
+		&i.Payload,
+	)
+	return i, err
+}
+
 const listConversationMessages = `-- name: ListConversationMessages :many
-SELECT id, conversation_id, sender_id, content, message_type, reply_to_id, created_at FROM messages
+SELECT id, conversation_id, sender_id, content, message_type, reply_to_id, created_at, seq, sender_device_id, client_message_id, edited_at, deleted_at, forward_count, thread_root_id, thread_reply_count, thread_last_reply_at, payload FROM messages
 WHERE conversation_id = $1
 ORDER BY created_at ASC
 `
@@ -274,6 +485,16 @@ func (q *Queries) ListConversationMessages(ctx context.Context, conversationID p
 			&i.MessageType,
 			&i.ReplyToID,
 			&i.CreatedAt,
+			&i.Seq,
+			&i.SenderDeviceID,
+			&i.ClientMessageID,
+			&i.EditedAt,
+			&i.DeletedAt,
+			&i.ForwardCount,
+			&i.ThreadRootID,
+			&i.ThreadReplyCount,
+			&i.ThreadLastReplyAt,
+			&i.Payload,
 		); err != nil {
 			return nil, err
 		}
@@ -286,7 +507,7 @@ func (q *Queries) ListConversationMessages(ctx context.Context, conversationID p
 }
 
 const listConversationMessagesPaginated = `-- name: ListConversationMessagesPaginated :many
-SELECT id, conversation_id, sender_id, content, message_type, reply_to_id, created_at FROM messages
+SELECT id, conversation_id, sender_id, content, message_type, reply_to_id, created_at, seq, sender_device_id, client_message_id, edited_at, deleted_at, forward_count, thread_root_id, thread_reply_count, thread_last_reply_at, payload FROM messages
 WHERE conversation_id = $1
 ORDER BY created_at DESC
 LIMIT $2 OFFSET $3
@@ -315,6 +536,16 @@ func (q *Queries) ListConversationMessagesPaginated(ctx context.Context, arg Lis
 			&i.MessageType,
 			&i.ReplyToID,
 			&i.CreatedAt,
+			&i.Seq,
+			&i.SenderDeviceID,
+			&i.ClientMessageID,
+			&i.EditedAt,
+			&i.DeletedAt,
+			&i.ForwardCount,
+			&i.ThreadRootID,
+			&i.ThreadReplyCount,
+			&i.ThreadLastReplyAt,
+			&i.Payload,
 		); err != nil {
 			return nil, err
 		}
@@ -327,7 +558,7 @@ func (q *Queries) ListConversationMessagesPaginated(ctx context.Context, arg Lis
 }
 
 const listConversationMessagesWithDetails = `-- name: ListConversationMessagesWithDetails :many
-SELECT m.id, m.conversation_id, m.sender_id, m.content, m.message_type, m.reply_to_id, m.created_at, u.phone_number as sender_phone, u.display_name as sender_name
+SELECT m.id, m.conversation_id, m.sender_id, m.content, m.message_type, m.reply_to_id, m.created_at, m.seq, m.sender_device_id, m.client_message_id, m.edited_at, m.deleted_at, m.forward_count, m.thread_root_id, m.thread_reply_count, m.thread_last_reply_at, m.payload, u.phone_number as sender_phone, u.display_name as sender_name
 FROM messages m
 LEFT JOIN users u ON m.sender_id = u.id
 WHERE m.conversation_id = $1
@@ -335,15 +566,25 @@ ORDER BY m.created_at ASC
 `
 
 type ListConversationMessagesWithDetailsRow struct {
-	ID             pgtype.UUID
-	ConversationID pgtype.UUID
-	SenderID       pgtype.UUID
-	Content        pgtype.Text
-	MessageType    string
-	ReplyToID      pgtype.UUID
-	CreatedAt      pgtype.Timestamptz
-	SenderPhone    pgtype.Text
-	SenderName     pgtype.Text
+	ID                pgtype.UUID
+	ConversationID    pgtype.UUID
+	SenderID          pgtype.UUID
+	Content           pgtype.Text
+	MessageType       string
+	ReplyToID         pgtype.UUID
+	CreatedAt         pgtype.Timestamptz
+	Seq               int64
+	SenderDeviceID    pgtype.UUID
+	ClientMessageID   pgtype.Text
+	EditedAt          pgtype.Timestamptz
+	DeletedAt         pgtype.Timestamptz
+	ForwardCount      int32
+	ThreadRootID      pgtype.UUID
+	ThreadReplyCount  int32
+	ThreadLastReplyAt pgtype.Timestamptz
+	Payload           []byte
+	SenderPhone       pgtype.Text
+	SenderName        pgtype.Text
 }
 
 func (q *Queries) ListConversationMessagesWithDetails(ctx context.Context, conversationID pgtype.UUID) ([]ListConversationMessagesWithDetailsRow, error) {
@@ -363,6 +604,16 @@ func (q *Queries) ListConversationMessagesWithDetails(ctx context.Context, conve
 			&i.MessageType,
 			&i.ReplyToID,
 			&i.CreatedAt,
+			&i.Seq,
+			&i.SenderDeviceID,
+			&i.ClientMessageID,
+			&i.EditedAt,
+			&i.DeletedAt,
+			&i.ForwardCount,
+			&i.ThreadRootID,
+			&i.ThreadReplyCount,
+			&i.ThreadLastReplyAt,
+			&i.Payload,
 			&i.SenderPhone,
 			&i.SenderName,
 		); err != nil {
@@ -376,8 +627,63 @@ func (q *Queries) ListConversationMessagesWithDetails(ctx context.Context, conve
 	return items, nil
 }
 
+const listLatestConversationMessages = `-- name: ListLatestConversationMessages :many
+SELECT id, conversation_id, sender_id, content, message_type, reply_to_id, created_at, seq, sender_device_id, client_message_id, edited_at, deleted_at, forward_count, thread_root_id, thread_reply_count, thread_last_reply_at, payload FROM messages
+WHERE conversation_id = $1
+  AND NOT EXISTS (
+    SELECT 1 FROM hidden_messages h
+    WHERE h.message_id = messages.id AND h.user_id = $2
+  )
+ORDER BY created_at DESC, id DESC
+LIMIT $3
+`
+
+type ListLatestConversationMessagesParams struct {
+	ConversationID pgtype.UUID
+	UserID         pgtype.UUID
+	Limit          int32
+}
+
+func (q *Queries) ListLatestConversationMessages(ctx context.Context, arg ListLatestConversationMessagesParams) ([]Message, error) {
+	rows, err := q.db.Query(ctx, listLatestConversationMessages, arg.ConversationID, arg.UserID, arg.Limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []Message
+	for rows.Next() {
+		var i Message
+		if err := rows.Scan(
+			&i.ID,
+			&i.ConversationID,
+			&i.SenderID,
+			&i.Content,
+			&i.MessageType,
+			&i.ReplyToID,
+			&i.CreatedAt,
+			&i.Seq,
+			&i.SenderDeviceID,
+			&i.ClientMessageID,
+			&i.EditedAt,
+			&i.DeletedAt,
+			&i.ForwardCount,
+			&i.ThreadRootID,
+			&i.ThreadReplyCount,
+			&i.ThreadLastReplyAt,
+			&i.Payload,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
 const listMessages = `-- name: ListMessages :many
-SELECT id, conversation_id, sender_id, content, message_type, reply_to_id, created_at FROM messages
+SELECT id, conversation_id, sender_id, content, message_type, reply_to_id, created_at, seq, sender_device_id, client_message_id, edited_at, deleted_at, forward_count, thread_root_id, thread_reply_count, thread_last_reply_at, payload FROM messages
 ORDER BY created_at DESC
 LIMIT $1 OFFSET $2
 `
@@ -404,6 +710,70 @@ func (q *Queries) ListMessages(ctx context.Context, arg ListMessagesParams) ([]M
 			&i.MessageType,
 			&i.ReplyToID,
 			&i.CreatedAt,
+			&i.Seq,
+			&i.SenderDeviceID,
+			&i.ClientMessageID,
+			&i.EditedAt,
+			&i.DeletedAt,
+			&i.ForwardCount,
+			&i.ThreadRootID,
+			&i.ThreadReplyCount,
+			&i.ThreadLastReplyAt,
+			&i.Payload,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const listMessagesAfterSeq = `-- name: ListMessagesAfterSeq :many
+SELECT id, conversation_id, sender_id, content, message_type, reply_to_id, created_at, seq, sender_device_id, client_message_id, edited_at, deleted_at, forward_count, thread_root_id, thread_reply_count, thread_last_reply_at, payload FROM messages
+WHERE conversation_id = $1
+  AND seq > $2
+ORDER BY seq ASC
+LIMIT $3
+`
+
+type ListMessagesAfterSeqParams struct {
+	ConversationID pgtype.UUID
+	Seq            int64
+	Limit          int32
+}
+
+// Messages the user deleted for themselves are included, so they do not
+// look like gaps in the sequence; see ListHiddenMessageIDs.
+func (q *Queries) ListMessagesAfterSeq(ctx context.Context, arg ListMessagesAfterSeqParams) ([]Message, error) {
+	rows, err := q.db.Query(ctx, listMessagesAfterSeq, arg.ConversationID, arg.Seq, arg.Limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []Message
+	for rows.Next() {
+		var i Message
+		if err := rows.Scan(
+			&i.ID,
+			&i.ConversationID,
+			&i.SenderID,
+			&i.Content,
+			&i.MessageType,
+			&i.ReplyToID,
+			&i.CreatedAt,
+			&i.Seq,
+			&i.SenderDeviceID,
+			&i.ClientMessageID,
+			&i.EditedAt,
+			&i.DeletedAt,
+			&i.ForwardCount,
+			&i.ThreadRootID,
+			&i.ThreadReplyCount,
+			&i.ThreadLastReplyAt,
+			&i.Payload,
 		); err != nil {
 			return nil, err
 		}
@@ -416,7 +786,7 @@ func (q *Queries) ListMessages(ctx context.Context, arg ListMessagesParams) ([]M
 }
 
 const listReplyMessages = `-- name: ListReplyMessages :many
-SELECT id, conversation_id, sender_id, content, message_type, reply_to_id, created_at FROM messages
+SELECT id, conversation_id, sender_id, content, message_type, reply_to_id, created_at, seq, sender_device_id, client_message_id, edited_at, deleted_at, forward_count, thread_root_id, thread_reply_count, thread_last_reply_at, payload FROM messages
 WHERE reply_to_id = $1
 ORDER BY created_at ASC
 `
@@ -438,6 +808,82 @@ func (q *Queries) ListReplyMessages(ctx context.Context, replyToID pgtype.UUID)
 			&i.MessageType,
 			&i.ReplyToID,
 			&i.CreatedAt,
+			&i.Seq,
+			&i.SenderDeviceID,
+			&i.ClientMessageID,
+			&i.EditedAt,
+			&i.DeletedAt,
+			&i.ForwardCount,
+			&i.ThreadRootID,
+			&i.ThreadReplyCount,
+			&i.ThreadLastReplyAt,
+			&i.Payload,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const listThreadReplies = `-- name: ListThreadReplies :many
+SELECT id, conversation_id, sender_id, content, message_type, reply_to_id, created_at, seq, sender_device_id, client_message_id, edited_at, deleted_at, forward_count, thread_root_id, thread_reply_count, thread_last_reply_at, payload FROM messages
+WHERE thread_root_id = $1
+  AND ($2::timestamptz IS NULL
+    OR (created_at, id) > ($2::timestamptz, $3::uuid))
+  AND NOT EXISTS (
+    SELECT 1 FROM hidden_messages h
+    WHERE h.message_id = messages.id AND h.user_id = $4
+  )
+ORDER BY created_at ASC, id ASC
+LIMIT $5
+`
+
+type ListThreadRepliesParams struct {
+	ThreadRootID pgtype.UUID
+	CreatedAt    pgtype.Timestamptz
+	ID           pgtype.UUID
+	UserID       pgtype.UUID
+	Limit        int32
+}
+
+// Replies are listed oldest first. A NULL cursor starts from the first reply.
+func (q *Queries) ListThreadReplies(ctx context.Context, arg ListThreadRepliesParams) ([]Message, error) {
+	rows, err := q.db.Query(ctx, listThreadReplies,
+		arg.ThreadRootID,
+		arg.CreatedAt,
+		arg.ID,
+		arg.UserID,
+		arg.Limit,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []Message
+	for rows.Next() {
+		var i Message
+		if err := rows.Scan(
+			&i.ID,
+			&i.ConversationID,
+			&i.SenderID,
+			&i.Content,
+			&i.MessageType,
+			&i.ReplyToID,
+			&i.CreatedAt,
+			&i.Seq,
+			&i.SenderDeviceID,
+			&i.ClientMessageID,
+			&i.EditedAt,
+			&i.DeletedAt,
+			&i.ForwardCount,
+			&i.ThreadRootID,
+			&i.ThreadReplyCount,
+			&i.ThreadLastReplyAt,
+			&i.Payload,
 		); err != nil {
 			return nil, err
 		}
@@ -450,7 +896,7 @@ func (q *Queries) ListReplyMessages(ctx context.Context, replyToID pgtype.UUID)
 }
 
 const listUserMessages = `-- name: ListUserMessages :many
-SELECT m.id, m.conversation_id, m.sender_id, m.content, m.message_type, m.reply_to_id, m.created_at, c.title as conversation_title, c.is_group
+SELECT m.id, m.conversation_id, m.sender_id, m.content, m.message_type, m.reply_to_id, m.created_at, m.seq, m.sender_device_id, m.client_message_id, m.edited_at, m.deleted_at, m.forward_count, m.thread_root_id, m.thread_reply_count, m.thread_last_reply_at, m.payload, c.title as conversation_title, c.is_group
 FROM messages m
 JOIN conversations c ON m.conversation_id = c.id
 WHERE m.sender_id = $1
@@ -465,6 +911,16 @@ type ListUserMessagesRow struct {
 	MessageType       string
 	ReplyToID         pgtype.UUID
 	CreatedAt         pgtype.Timestamptz
+	Seq               int64
+	SenderDeviceID    pgtype.UUID
+	ClientMessageID   pgtype.Text
+	EditedAt          pgtype.Timestamptz
+	DeletedAt         pgtype.Timestamptz
+	ForwardCount      int32
+	ThreadRootID      pgtype.UUID
+	ThreadReplyCount  int32
+	ThreadLastReplyAt pgtype.Timestamptz
+	Payload           []byte
 	ConversationTitle pgtype.Text
 	IsGroup           bool
 }
@@ -486,6 +942,16 @@ func (q *Queries) ListUserMessages(ctx context.Context, senderID pgtype.UUID) ([
 			&i.MessageType,
 			&i.ReplyToID,
 			&i.CreatedAt,
+			&i.Seq,
+			&i.SenderDeviceID,
+			&i.ClientMessageID,
+			&i.EditedAt,
+			&i.DeletedAt,
+			&i.ForwardCount,
+			&i.ThreadRootID,
+			&i.ThreadReplyCount,
+			&i.ThreadLastReplyAt,
+			&i.Payload,
 			&i.ConversationTitle,
 			&i.IsGroup,
 		); err != nil {
@@ -500,7 +966,7 @@ func (q *Queries) ListUserMessages(ctx context.Context, senderID pgtype.UUID) ([
 }
 
 const searchMessages = `-- name: SearchMessages :many
-SELECT m.id, m.conversation_id, m.sender_id, m.content, m.message_type, m.reply_to_id, m.created_at, c.title as conversation_title
+SELECT m.id, m.conversation_id, m.sender_id, m.content, m.message_type, m.reply_to_id, m.created_at, m.seq, m.sender_device_id, m.client_message_id, m.edited_at, m.deleted_at, m.forward_count, m.thread_root_id, m.thread_reply_count, m.thread_last_reply_at, m.payload, c.title as conversation_title
 FROM messages m
 JOIN conversations c ON m.conversation_id = c.id
 WHERE m.content ILIKE '%' || $1 || '%'
@@ -516,6 +982,16 @@ type SearchMessagesRow struct {
 	MessageType       string
 	ReplyToID         pgtype.UUID
 	CreatedAt         pgtype.Timestamptz
+	Seq               int64
+	SenderDeviceID    pgtype.UUID
+	ClientMessageID   pgtype.Text
+	EditedAt          pgtype.Timestamptz
+	DeletedAt         pgtype.Timestamptz
+	ForwardCount      int32
+	ThreadRootID      pgtype.UUID
+	ThreadReplyCount  int32
+	ThreadLastReplyAt pgtype.Timestamptz
+	Payload           []byte
 	ConversationTitle pgtype.Text
 }
 
@@ -536,6 +1012,16 @@ func (q *Queries) SearchMessages(ctx context.Context, dollar_1 pgtype.Text) ([]S
 			&i.MessageType,
 			&i.ReplyToID,
 			&i.CreatedAt,
+			&i.Seq,
+			&i.SenderDeviceID,
+			&i.ClientMessageID,
+			&i.EditedAt,
+			&i.DeletedAt,
+			&i.ForwardCount,
+			&i.ThreadRootID,
+			&i.ThreadReplyCount,
+			&i.ThreadLastReplyAt,
+			&i.Payload,
 			&i.ConversationTitle,
 		); err != nil {
 			return nil, err
@@ -548,11 +1034,46 @@ func (q *Queries) SearchMessages(ctx context.Context, dollar_1 pgtype.Text) ([]S
 	return items, nil
 }
 
+const tombstoneMessage = `-- name: TombstoneMessage :one
+UPDATE messages
+SET content = NULL,
+    payload = NULL,
+    deleted_at = now()
+WHERE id = $1 AND deleted_at IS NULL
+RETURNING id, conversation_id, sender_id, content, message_type, reply_to_id, created_at, seq, sender_device_id, client_message_id, edited_at, deleted_at, forward_count, thread_root_id, thread_reply_count, thread_last_reply_at, payload
+`
+
+func (q *Queries) TombstoneMessage(ctx context.Context, id pgtype.UUID) (Message, error) {
+	row := q.db.QueryRow(ctx, tombstoneMessage, id)
+	var i Message
+	err := row.Scan(
+		&i.ID,
+		&i.ConversationID,
+		&i.SenderID,
+		&i.Content,
+		&i.MessageType,
+		&i.ReplyToID,
+		&i.CreatedAt,
+		&i.Seq,
+		&i.SenderDeviceID,
+		&i.ClientMessageID,
+		&i.EditedAt,
+		&i.DeletedAt,
+		&i.ForwardCount,
+		&i.ThreadRootID,
+		&i.ThreadReplyCount,
+		&i.ThreadLastReplyAt,
+		&i.Payload,
+	)
+	return i, err
+}
+
 const updateMessageContent = `-- name: UpdateMessageContent :one
 UPDATE messages
-SET content = $2
+SET content = $2,
+    edited_at = now()
 WHERE id = $1
-RETURNING id, conversation_id, sender_id, content, message_type, reply_to_id, created_at
+RETURNING id, conversation_id, sender_id, content, message_type, reply_to_id, created_at, seq, sender_device_id, client_message_id, edited_at, deleted_at, forward_count, thread_root_id, thread_reply_count, thread_last_reply_at, payload
 `
 
 type UpdateMessageContentParams struct {
@@ -571,6 +1092,16 @@ func (q *Queries) UpdateMessageContent(ctx context.Context, arg UpdateMessageCon
 		&i.MessageType,
 		&i.ReplyToID,
 		&i.CreatedAt,
+		&i.Seq,
+		&i.SenderDeviceID,
+		&i.ClientMessageID,
+		&i.EditedAt,
+		&i.DeletedAt,
+		&i.ForwardCount,
+		&i.ThreadRootID,
+		&i.ThreadReplyCount,
+		&i.ThreadLastReplyAt,
+		&i.Payload,
 	)
 	return i, err
 }
@@ -15,18 +15,41 @@ type Contact struct {
 }
 
 type Conversation struct {
-	ID        pgtype.UUID
-	IsGroup   bool
-	Title     pgtype.Text
-	CreatedBy pgtype.UUID
-	CreatedAt pgtype.Timestamptz
+	ID             pgtype.UUID
+	IsGroup        bool
+	Title          pgtype.Text
+	CreatedBy      pgtype.UUID
+	CreatedAt      pgtype.Timestamptz
+	LastMessageSeq int64
 }
 
-type ConversationParticipant struct {
+type ConversationEvent struct {
+	ID             int64
 	ConversationID pgtype.UUID
-	UserID         pgtype.UUID
-	Role           pgtype.Text
-	JoinedAt       pgtype.Timestamptz
+	EventType      string
+	Payload        []byte
+	CreatedAt      pgtype.Timestamptz
+	Private        bool
+	XactID         int64
+	SyncPosition   pgtype.Int8
+}
+
+type ConversationEventSubject struct {
+	EventID int64
+	UserID  pgtype.UUID
+}
+
+type ConversationParticipant struct {
+	ConversationID     pgtype.UUID
+	UserID             pgtype.UUID
+	Role               pgtype.Text
+	JoinedAt           pgtype.Timestamptz
+	LastReadSeq        int64
+	UnreadMentionCount int32
+	MutedUntil         pgtype.Timestamptz
+	PinnedAt           pgtype.Timestamptz
+	ArchivedAt         pgtype.Timestamptz
+	LastDeliveredSeq   int64
 }
 
 type EncryptionKey struct {
@@ -36,6 +59,12 @@ type EncryptionKey struct {
 	PrekeySignature string
 }
 
+type HiddenMessage struct {
+	UserID    pgtype.UUID
+	MessageID pgtype.UUID
+	HiddenAt  pgtype.Timestamptz
+}
+
 type Medium struct {
 	ID         pgtype.UUID
 	MessageID  pgtype.UUID
@@ -46,12 +75,36 @@ type Medium struct {
 }
 
 type Message struct {
-	ID             pgtype.UUID
+	ID                pgtype.UUID
+	ConversationID    pgtype.UUID
+	SenderID          pgtype.UUID
+	Content           pgtype.Text
+	MessageType       string
+	ReplyToID         pgtype.UUID
+	CreatedAt         pgtype.Timestamptz
+	Seq               int64
+	SenderDeviceID    pgtype.UUID
+	ClientMessageID   pgtype.Text
+	EditedAt          pgtype.Timestamptz
+	DeletedAt         pgtype.Timestamptz
+	ForwardCount      int32
+	ThreadRootID      pgtype.UUID
+	ThreadReplyCount  int32
+	ThreadLastReplyAt pgtype.Timestamptz
+	Payload           []byte
+}
+
+type MessageEdit struct {
+	ID              pgtype.UUID
+	MessageID       pgtype.UUID
+	PreviousContent pgtype.Text
+	EditedAt        pgtype.Timestamptz
+}
+
+type MessageMention struct {
+	MessageID      pgtype.UUID
 	ConversationID pgtype.UUID
-	SenderID       pgtype.UUID
-	Content        pgtype.Text
-	MessageType    string
-	ReplyToID      pgtype.UUID
+	UserID         pgtype.UUID
 	CreatedAt      pgtype.Timestamptz
 }
 
@@ -69,19 +122,86 @@ type MessageReceipt struct {
 	ReadAt      pgtype.Timestamptz
 }
 
-type User struct {
+type PhoneVerification struct {
 	ID          pgtype.UUID
 	PhoneNumber string
-	DisplayName pgtype.Text
-	About       pgtype.Text
+	CodeHash    string
+	Attempts    int32
+	ExpiresAt   pgtype.Timestamptz
+	VerifiedAt  pgtype.Timestamptz
 	CreatedAt   pgtype.Timestamptz
 }
 
-type UserDevice struct {
-	ID         pgtype.UUID
+type Poll struct {
+	MessageID      pgtype.UUID
+	MultipleChoice bool
+	OptionCount    int32
+	ClosesAt       pgtype.Timestamptz
+	ClosedAt       pgtype.Timestamptz
+	CreatedAt      pgtype.Timestamptz
+}
+
+type PollVote struct {
+	MessageID   pgtype.UUID
+	UserID      pgtype.UUID
+	OptionIndex int32
+	VotedAt     pgtype.Timestamptz
+}
+
+type ScheduledMessage struct {
+	ID             pgtype.UUID
+	ConversationID pgtype.UUID
+	UserID         pgtype.UUID
+	DeviceID       pgtype.UUID
+	Content        pgtype.Text
+	MessageType    string
+	Payload        []byte
+	ReplyToID      pgtype.UUID
+	ThreadRootID   pgtype.UUID
+	SendAt         pgtype.Timestamptz
+	Status         string
+	Attempts       int32
+	ClaimedUntil   pgtype.Timestamptz
+	MessageID      pgtype.UUID
+	LastError      pgtype.Text
+	CreatedAt      pgtype.Timestamptz
+	UpdatedAt      pgtype.Timestamptz
+}
+
+type Session struct {
+	ID               pgtype.UUID
+	UserID           pgtype.UUID
+	DeviceID         pgtype.UUID
+	RefreshTokenHash string
+	ExpiresAt        pgtype.Timestamptz
+	RevokedAt        pgtype.Timestamptz
+	LastUsedAt       pgtype.Timestamptz
+	CreatedAt        pgtype.Timestamptz
+}
+
+type ThreadFollower struct {
+	MessageID  pgtype.UUID
 	UserID     pgtype.UUID
-	DeviceName pgtype.Text
-	DeviceType pgtype.Text
-	PublicKey  string
-	CreatedAt  pgtype.Timestamptz
+	FollowedAt pgtype.Timestamptz
+}
+
+type User struct {
+	ID                 pgtype.UUID
+	PhoneNumber        string
+	DisplayName        pgtype.Text
+	About              pgtype.Text
+	CreatedAt          pgtype.Timestamptz
+	LastSeenAt         pgtype.Timestamptz
+	PresenceVisibility string
+}
+
+type UserDevice struct {
+	ID                pgtype.UUID
+	UserID            pgtype.UUID
+	DeviceName        pgtype.Text
+	DeviceType        pgtype.Text
+	PublicKey         string
+	CreatedAt         pgtype.Timestamptz
+	SyncCheckpoint    int64
+	PresenceExpiresAt pgtype.Timestamptz
 }
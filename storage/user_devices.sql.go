@@ -11,6 +11,34 @@ import (
 	"github.com/jackc/pgx/v5/pgtype"
 )
 
+const advanceUserDeviceSyncCheckpoint = `-- name: AdvanceUserDeviceSyncCheckpoint :one
+UPDATE user_devices
+SET sync_checkpoint = GREATEST(sync_checkpoint, $1::bigint)
+WHERE id = $2
+RETURNING id, user_id, device_name, device_type, public_key, created_at, sync_checkpoint, presence_expires_at
+`
+
+type AdvanceUserDeviceSyncCheckpointParams struct {
+	SyncCheckpoint int64
+	ID             pgtype.UUID
+}
+
+func (q *Queries) AdvanceUserDeviceSyncCheckpoint(ctx context.Context, arg AdvanceUserDeviceSyncCheckpointParams) (UserDevice, error) {
+	row := q.db.QueryRow(ctx, advanceUserDeviceSyncCheckpoint, arg.SyncCheckpoint, arg.ID)
+	var i UserDevice
+	err := row.Scan(
+		&i.ID,
+		&i.UserID,
+		&i.DeviceName,
+		&i.DeviceType,
+		&i.PublicKey,
+		&i.CreatedAt,
+		&i.SyncCheckpoint,
+		&i.PresenceExpiresAt,
+	)
+	return i, err
+}
+
 const countUserDevices = `-- name: CountUserDevices :one
 SELECT COUNT(*) FROM user_devices
 WHERE user_id = $1
@@ -29,7 +57,7 @@ INSERT INTO user_devices (
 ) VALUES (
   $1, $2, $3, $4
 )
-RETURNING id, user_id, device_name, device_type, public_key, created_at
+RETURNING id, user_id, device_name, device_type, public_key, created_at, sync_checkpoint, presence_expires_at
 `
 
 type CreateUserDeviceParams struct {
@@ -54,6 +82,8 @@ func (q *Queries) CreateUserDevice(ctx context.Context, arg CreateUserDevicePara
 		&i.DeviceType,
 		&i.PublicKey,
 		&i.CreatedAt,
+		&i.SyncCheckpoint,
+		&i.PresenceExpiresAt,
 	)
 	return i, err
 }
@@ -78,8 +108,54 @@ func (q *Queries) DeleteUserDevicesByUser(ctx context.Context, userID pgtype.UUI
 	return err
 }
 
+const expireDevicePresence = `-- name: ExpireDevicePresence :many
+WITH expired AS (
+  UPDATE user_devices SET presence_expires_at = NULL
+  WHERE presence_expires_at <= NOW()
+  RETURNING user_id
+)
+SELECT DISTINCT u.id, u.last_seen_at
+FROM expired e
+JOIN users u ON u.id = e.user_id
+WHERE NOT EXISTS (
+  SELECT 1 FROM user_devices d
+  WHERE d.user_id = e.user_id AND d.presence_expires_at > NOW()
+)
+`
+
+type ExpireDevicePresenceRow struct {
+	ID         pgtype.UUID
+	LastSeenAt pgtype.Timestamptz
+}
+
+// Ends the presence leases that were not renewed in time, such as those of
+// devices whose server went away, and returns the users this left with no
+// device online.
+func (q *Queries) ExpireDevicePresence(ctx context.Context) ([]ExpireDevicePresenceRow, error) {
+	rows, err := q.db.Query(ctx, expireDevicePresence)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []ExpireDevicePresenceRow
+	for rows.Next() {
+		var i ExpireDevicePresenceRow
+		if err := rows.Scan(
+			&i.ID,
+			&i.LastSeenAt,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
 const getUserDevice = `-- name: GetUserDevice :one
-SELECT id, user_id, device_name, device_type, public_key, created_at FROM user_devices
+SELECT id, user_id, device_name, device_type, public_key, created_at, sync_checkpoint, presence_expires_at FROM user_devices
 WHERE id = $1 LIMIT 1
 `
 
@@ -93,12 +169,14 @@ func (q *Queries) GetUserDevice(ctx context.Context, id pgtype.UUID) (UserDevice
 		&i.DeviceType,
 		&i.PublicKey,
 		&i.CreatedAt,
+		&i.SyncCheckpoint,
+		&i.PresenceExpiresAt,
 	)
 	return i, err
 }
 
 const getUserDeviceByUserAndKey = `-- name: GetUserDeviceByUserAndKey :one
-SELECT id, user_id, device_name, device_type, public_key, created_at FROM user_devices
+SELECT id, user_id, device_name, device_type, public_key, created_at, sync_checkpoint, presence_expires_at FROM user_devices
 WHERE user_id = $1 AND public_key = $2 LIMIT 1
 `
 
@@ -117,26 +195,30 @@ func (q *Queries) GetUserDeviceByUserAndKey(ctx context.Context, arg GetUserDevi
 		&i.DeviceType,
 		&i.PublicKey,
 		&i.CreatedAt,
+		&i.SyncCheckpoint,
+		&i.PresenceExpiresAt,
 	)
 	return i, err
 }
 
 const listAllUserDevices = `-- name: ListAllUserDevices :many
-SELECT ud.id, ud.user_id, ud.device_name, ud.device_type, ud.public_key, ud.created_at, u.phone_number, u.display_name
+SELECT ud.id, ud.user_id, ud.device_name, ud.device_type, ud.public_key, ud.created_at, ud.sync_checkpoint, ud.presence_expires_at, u.phone_number, u.display_name
 FROM user_devices ud
 JOIN users u ON ud.user_id = u.id
 ORDER BY ud.created_at DESC
 `
 
 type ListAllUserDevicesRow struct {
-	ID          pgtype.UUID
-	UserID      pgtype.UUID
-	DeviceName  pgtype.Text
-	DeviceType  pgtype.Text
-	PublicKey   string
-	CreatedAt   pgtype.Timestamptz
-	PhoneNumber string
-	DisplayName pgtype.Text
+	ID                pgtype.UUID
+	UserID            pgtype.UUID
+	DeviceName        pgtype.Text
+	DeviceType        pgtype.Text
+	PublicKey         string
+	CreatedAt         pgtype.Timestamptz
+	SyncCheckpoint    int64
+	PresenceExpiresAt pgtype.Timestamptz
+	PhoneNumber       string
+	DisplayName       pgtype.Text
 }
 
 func (q *Queries) ListAllUserDevices(ctx context.Context) ([]ListAllUserDevicesRow, error) {
@@ -155,6 +237,8 @@ func (q *Queries) ListAllUserDevices(ctx context.Context) ([]ListAllUserDevicesR
 			&i.DeviceType,
 			&i.PublicKey,
 			&i.CreatedAt,
+			&i.SyncCheckpoint,
+			&i.PresenceExpiresAt,
 			&i.PhoneNumber,
 			&i.DisplayName,
 		); err != nil {
@@ -169,7 +253,7 @@ func (q *Queries) ListAllUserDevices(ctx context.Context) ([]ListAllUserDevicesR
 }
 
 const listUserDevices = `-- name: ListUserDevices :many
-SELECT id, user_id, device_name, device_type, public_key, created_at FROM user_devices
+SELECT id, user_id, device_name, device_type, public_key, created_at, sync_checkpoint, presence_expires_at FROM user_devices
 WHERE user_id = $1
 ORDER BY created_at DESC
 `
@@ -190,6 +274,8 @@ func (q *Queries) ListUserDevices(ctx context.Context, userID pgtype.UUID) ([]Us
 			&i.DeviceType,
 			&i.PublicKey,
 			&i.CreatedAt,
+			&i.SyncCheckpoint,
+			&i.PresenceExpiresAt,
 		); err != nil {
 			return nil, err
 		}
@@ -201,12 +287,77 @@ func (q *Queries) ListUserDevices(ctx context.Context, userID pgtype.UUID) ([]Us
 	return items, nil
 }
 
+const markDeviceOffline = `-- name: MarkDeviceOffline :one
+WITH device AS (
+  UPDATE user_devices SET presence_expires_at = NULL
+  WHERE id = $1 AND user_id = $2
+)
+UPDATE users u
+SET last_seen_at = NOW()
+WHERE u.id = $2
+RETURNING u.last_seen_at, EXISTS (
+  SELECT 1 FROM user_devices d
+  WHERE d.user_id = u.id AND d.id != $1 AND d.presence_expires_at > NOW()
+) AS online
+`
+
+type MarkDeviceOfflineParams struct {
+	DeviceID pgtype.UUID
+	UserID   pgtype.UUID
+}
+
+type MarkDeviceOfflineRow struct {
+	LastSeenAt pgtype.Timestamptz
+	Online     bool
+}
+
+// Ends the device's presence lease and records the user as last seen now.
+// Reports whether another of the user's devices is still online.
+func (q *Queries) MarkDeviceOffline(ctx context.Context, arg MarkDeviceOfflineParams) (MarkDeviceOfflineRow, error) {
+	row := q.db.QueryRow(ctx, markDeviceOffline, arg.DeviceID, arg.UserID)
+	var i MarkDeviceOfflineRow
+	err := row.Scan(
+		&i.LastSeenAt,
+		&i.Online,
+	)
+	return i, err
+}
+
+const markDeviceOnline = `-- name: MarkDeviceOnline :one
+WITH seen AS (
+  UPDATE users SET last_seen_at = NOW()
+  WHERE id = $1
+)
+UPDATE user_devices
+SET presence_expires_at = NOW() + make_interval(secs => $2::float8)
+WHERE id = $3 AND user_id = $1
+RETURNING EXISTS (
+  SELECT 1 FROM user_devices d
+  WHERE d.user_id = $1 AND d.presence_expires_at > NOW()
+) AS was_online
+`
+
+type MarkDeviceOnlineParams struct {
+	UserID     pgtype.UUID
+	TtlSeconds float64
+	DeviceID   pgtype.UUID
+}
+
+// Extends the device's presence lease and records the user as seen. Reports
+// whether any of the user's devices was online beforehand.
+func (q *Queries) MarkDeviceOnline(ctx context.Context, arg MarkDeviceOnlineParams) (bool, error) {
+	row := q.db.QueryRow(ctx, markDeviceOnline, arg.UserID, arg.TtlSeconds, arg.DeviceID)
+	var was_online bool
+	err := row.Scan(&was_online)
+	return was_online, err
+}
+
 const updateUserDevice = `-- name: UpdateUserDevice :one
 UPDATE user_devices
 SET device_name = $2,
     device_type = $3
 WHERE id = $1
-RETURNING id, user_id, device_name, device_type, public_key, created_at
+RETURNING id, user_id, device_name, device_type, public_key, created_at, sync_checkpoint, presence_expires_at
 `
 
 type UpdateUserDeviceParams struct {
@@ -225,6 +376,8 @@ func (q *Queries) UpdateUserDevice(ctx context.Context, arg UpdateUserDevicePara
 		&i.DeviceType,
 		&i.PublicKey,
 		&i.CreatedAt,
+		&i.SyncCheckpoint,
+		&i.PresenceExpiresAt,
 	)
 	return i, err
 }
@@ -17,7 +17,7 @@ INSERT INTO users (
 ) VALUES (
   $1, $2, $3
 )
-RETURNING id, phone_number, display_name, about, created_at
+RETURNING id, phone_number, display_name, about, created_at, last_seen_at, presence_visibility
 `
 
 type CreateUserParams struct {
@@ -35,6 +35,8 @@ func (q *Queries) CreateUser(ctx context.Context, arg CreateUserParams) (User, e
 		&i.DisplayName,
 		&i.About,
 		&i.CreatedAt,
+		&i.LastSeenAt,
+		&i.PresenceVisibility,
 	)
 	return i, err
 }
@@ -49,8 +51,20 @@ func (q *Queries) DeleteUser(ctx context.Context, id pgtype.UUID) error {
 	return err
 }
 
+const getPresenceVisibility = `-- name: GetPresenceVisibility :one
+SELECT presence_visibility FROM users
+WHERE id = $1
+`
+
+func (q *Queries) GetPresenceVisibility(ctx context.Context, id pgtype.UUID) (string, error) {
+	row := q.db.QueryRow(ctx, getPresenceVisibility, id)
+	var presence_visibility string
+	err := row.Scan(&presence_visibility)
+	return presence_visibility, err
+}
+
 const getUser = `-- name: GetUser :one
-SELECT id, phone_number, display_name, about, created_at FROM users
+SELECT id, phone_number, display_name, about, created_at, last_seen_at, presence_visibility FROM users
 WHERE id = $1 LIMIT 1
 `
 
@@ -63,12 +77,14 @@ func (q *Queries) GetUser(ctx context.Context, id pgtype.UUID) (User, error) {
 		&i.DisplayName,
 		&i.About,
 		&i.CreatedAt,
+		&i.LastSeenAt,
+		&i.PresenceVisibility,
 	)
 	return i, err
 }
 
 const getUserByPhoneNumber = `-- name: GetUserByPhoneNumber :one
-SELECT id, phone_number, display_name, about, created_at FROM users
+SELECT id, phone_number, display_name, about, created_at, last_seen_at, presence_visibility FROM users
 WHERE phone_number = $1 LIMIT 1
 `
 
@@ -81,12 +97,14 @@ func (q *Queries) GetUserByPhoneNumber(ctx context.Context, phoneNumber string)
 		&i.DisplayName,
 		&i.About,
 		&i.CreatedAt,
+		&i.LastSeenAt,
+		&i.PresenceVisibility,
 	)
 	return i, err
 }
 
 const listUsers = `-- name: ListUsers :many
-SELECT id, phone_number, display_name, about, created_at FROM users
+SELECT id, phone_number, display_name, about, created_at, last_seen_at, presence_visibility FROM users
 ORDER BY display_name, phone_number
 `
 
@@ -105,6 +123,67 @@ func (q *Queries) ListUsers(ctx context.Context) ([]User, error) {
 			&i.DisplayName,
 			&i.About,
 			&i.CreatedAt,
+			&i.LastSeenAt,
+			&i.PresenceVisibility,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const listVisiblePresence = `-- name: ListVisiblePresence :many
+SELECT u.id, u.last_seen_at, EXISTS (
+  SELECT 1 FROM user_devices d
+  WHERE d.user_id = u.id AND d.presence_expires_at > NOW()
+) AS online
+FROM users u
+WHERE u.id = ANY($1::uuid[])
+  AND (NOT $2::bool OR EXISTS (
+    SELECT 1 FROM contacts c
+    WHERE c.user_id = $3::uuid AND c.contact_id = u.id
+  ))
+  AND (u.id = $3::uuid
+    OR u.presence_visibility = 'everyone'
+    OR (u.presence_visibility = 'contacts' AND EXISTS (
+      SELECT 1 FROM contacts c
+      WHERE c.user_id = u.id AND c.contact_id = $3::uuid
+    )))
+`
+
+type ListVisiblePresenceParams struct {
+	UserIds      []pgtype.UUID
+	ContactsOnly bool
+	ViewerID     pgtype.UUID
+}
+
+type ListVisiblePresenceRow struct {
+	ID         pgtype.UUID
+	LastSeenAt pgtype.Timestamptz
+	Online     bool
+}
+
+// Returns the presence of those of the given users whose visibility lets the
+// viewer see it: 'everyone', or 'contacts' when they have the viewer as a
+// contact. With contacts_only, users the viewer does not have as contacts
+// are left out too. Users can always see their own presence.
+func (q *Queries) ListVisiblePresence(ctx context.Context, arg ListVisiblePresenceParams) ([]ListVisiblePresenceRow, error) {
+	rows, err := q.db.Query(ctx, listVisiblePresence, arg.UserIds, arg.ContactsOnly, arg.ViewerID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []ListVisiblePresenceRow
+	for rows.Next() {
+		var i ListVisiblePresenceRow
+		if err := rows.Scan(
+			&i.ID,
+			&i.LastSeenAt,
+			&i.Online,
 		); err != nil {
 			return nil, err
 		}
@@ -117,7 +196,7 @@ func (q *Queries) ListUsers(ctx context.Context) ([]User, error) {
 }
 
 const searchUsersByDisplayName = `-- name: SearchUsersByDisplayName :many
-SELECT id, phone_number, display_name, about, created_at FROM users
+SELECT id, phone_number, display_name, about, created_at, last_seen_at, presence_visibility FROM users
 WHERE display_name ILIKE '%' || $1 || '%'
 ORDER BY display_name
 LIMIT 20
@@ -138,6 +217,8 @@ func (q *Queries) SearchUsersByDisplayName(ctx context.Context, dollar_1 pgtype.
 			&i.DisplayName,
 			&i.About,
 			&i.CreatedAt,
+			&i.LastSeenAt,
+			&i.PresenceVisibility,
 		); err != nil {
 			return nil, err
 		}
@@ -150,7 +231,7 @@ func (q *Queries) SearchUsersByDisplayName(ctx context.Context, dollar_1 pgtype.
 }
 
 const searchUsersByPhone = `-- name: SearchUsersByPhone :many
-SELECT id, phone_number, display_name, about, created_at FROM users
+SELECT id, phone_number, display_name, about, created_at, last_seen_at, presence_visibility FROM users
 WHERE phone_number LIKE $1 || '%'
 ORDER BY phone_number
 LIMIT 20
@@ -171,6 +252,8 @@ func (q *Queries) SearchUsersByPhone(ctx context.Context, dollar_1 pgtype.Text)
 			&i.DisplayName,
 			&i.About,
 			&i.CreatedAt,
+			&i.LastSeenAt,
+			&i.PresenceVisibility,
 		); err != nil {
 			return nil, err
 		}
@@ -182,13 +265,32 @@ func (q *Queries) SearchUsersByPhone(ctx context.Context, dollar_1 pgtype.Text)
 	return items, nil
 }
 
+const updatePresenceVisibility = `-- name: UpdatePresenceVisibility :one
+UPDATE users
+SET presence_visibility = $2
+WHERE id = $1
+RETURNING presence_visibility
+`
+
+type UpdatePresenceVisibilityParams struct {
+	ID                 pgtype.UUID
+	PresenceVisibility string
+}
+
+func (q *Queries) UpdatePresenceVisibility(ctx context.Context, arg UpdatePresenceVisibilityParams) (string, error) {
+	row := q.db.QueryRow(ctx, updatePresenceVisibility, arg.ID, arg.PresenceVisibility)
+	var presence_visibility string
+	err := row.Scan(&presence_visibility)
+	return presence_visibility, err
+}
+
 const updateUser = `-- name: UpdateUser :one
 UPDATE users
 SET phone_number = $2,
     display_name = $3,
     about = $4
 WHERE id = $1
-RETURNING id, phone_number, display_name, about, created_at
+RETURNING id, phone_number, display_name, about, created_at, last_seen_at, presence_visibility
 `
 
 type UpdateUserParams struct {
@@ -212,6 +314,8 @@ func (q *Queries) UpdateUser(ctx context.Context, arg UpdateUserParams) (User, e
 		&i.DisplayName,
 		&i.About,
 		&i.CreatedAt,
+		&i.LastSeenAt,
+		&i.PresenceVisibility,
 	)
 	return i, err
 }
@@ -220,7 +324,7 @@ const updateUserAbout = `-- name: UpdateUserAbout :one
 UPDATE users
 SET about = $2
 WHERE id = $1
-RETURNING id, phone_number, display_name, about, created_at
+RETURNING id, phone_number, display_name, about, created_at, last_seen_at, presence_visibility
 `
 
 type UpdateUserAboutParams struct {
@@ -237,6 +341,8 @@ func (q *Queries) UpdateUserAbout(ctx context.Context, arg UpdateUserAboutParams
 		&i.DisplayName,
 		&i.About,
 		&i.CreatedAt,
+		&i.LastSeenAt,
+		&i.PresenceVisibility,
 	)
 	return i, err
 }
@@ -245,7 +351,7 @@ const updateUserDisplayName = `-- name: UpdateUserDisplayName :one
 UPDATE users
 SET display_name = $2
 WHERE id = $1
-RETURNING id, phone_number, display_name, about, created_at
+RETURNING id, phone_number, display_name, about, created_at, last_seen_at, presence_visibility
 `
 
 type UpdateUserDisplayNameParams struct {
@@ -262,6 +368,8 @@ func (q *Queries) UpdateUserDisplayName(ctx context.Context, arg UpdateUserDispl
 		&i.DisplayName,
 		&i.About,
 		&i.CreatedAt,
+		&i.LastSeenAt,
+		&i.PresenceVisibility,
 	)
 	return i, err
 }